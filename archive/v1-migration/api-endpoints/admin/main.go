@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"fmt"
 	"io"
 	"math"
 	"net/http"
@@ -160,11 +161,27 @@ func (r *Resolvers) Home(c *core.RequestEvent) error {
 	return lib.Render(c, admindashboard.Home(admin, dashboardData, page, totalPages))
 }
 
+// Analytics shows every company's activity between ?from= and ?to= (default
+// the last 30 days). ?format=json returns the raw report.
 func (r *Resolvers) Analytics(c *core.RequestEvent) error {
 	adminID := c.Get("adminID")
 	admin, err := r.helper.FetchAdminById(adminID.(string))
 	if err != nil {
 		return c.Redirect(http.StatusFound, "/admin-login")
 	}
-	return lib.Render(c, admindashboard.Analytics(admin))
+
+	days, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	report, err := r.helper.FetchPlatformAnalytics(days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to build analytics: %w", err))
+	}
+
+	if c.Request.URL.Query().Get("format") == "json" {
+		return c.JSON(http.StatusOK, report)
+	}
+	return lib.Render(c, admindashboard.Analytics(admin, report))
 }
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type createPeriodRequest struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type periodReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *Resolvers) ListPeriods(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	if companyID == "" {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("company ID is missing"))
+	}
+
+	periods, err := r.helper.FetchAccountingPeriods(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch periods: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, periods)
+}
+
+func (r *Resolvers) CreatePeriod(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	if companyID == "" {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("company ID is missing"))
+	}
+
+	var body createPeriodRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode period data: %w", err))
+	}
+	start, err := time.Parse(time.DateOnly, body.StartDate)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid start_date: %w", err))
+	}
+	end, err := time.Parse(time.DateOnly, body.EndDate)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid end_date: %w", err))
+	}
+
+	period, err := r.helper.CreateAccountingPeriod(companyID, body.Name, start, end)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to create period: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, period)
+}
+
+func (r *Resolvers) ClosePeriod(c *core.RequestEvent) error {
+	adminID := c.Get("adminID").(string)
+	periodID := c.Request.PathValue("periodID")
+
+	var body periodReasonRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	period, err := r.helper.CloseAccountingPeriod(periodID, adminID, body.Reason)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to close period: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, period)
+}
+
+func (r *Resolvers) ReopenPeriod(c *core.RequestEvent) error {
+	adminID := c.Get("adminID").(string)
+	periodID := c.Request.PathValue("periodID")
+
+	var body periodReasonRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	period, err := r.helper.ReopenAccountingPeriod(periodID, adminID, body.Reason)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to reopen period: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, period)
+}
@@ -0,0 +1,57 @@
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/kisinga/dukahub/views/pages/dashboard"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Aging shows the receivables and payables aging of the company's partners
+// as of ?as_of= (default today). ?format=json or csv returns the raw report.
+func (r *Resolvers) Aging(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	asOf := time.Now().UTC()
+	if v := query.Get("as_of"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid as_of date: %w", err))
+		}
+		asOf = t
+	}
+
+	report, err := r.helper.AgingReport(companyID, asOf)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to build aging report: %w", err))
+	}
+
+	switch query.Get("format") {
+	case "json":
+		return c.JSON(http.StatusOK, report)
+	case "csv":
+		buf := new(bytes.Buffer)
+		if err := lib.WriteAgingCSV(buf, report); err != nil {
+			return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to write aging report: %w", err))
+		}
+		c.Response.Header().Set("Content-Type", "text/csv")
+		c.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "aging-"+report.AsOf.Format("20060102")+".csv"))
+		c.Response.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		_, err := io.Copy(c.Response, buf)
+		return err
+	}
+
+	data, err := r.helper.FetchDashboardData(userID, companyID)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+	return lib.Render(c, dashboard.Aging(data, report))
+}
@@ -0,0 +1,136 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SalespeoplePerformance returns each salesperson's sales, discounts,
+// returns, voids and commission between ?from= and ?to= (default the last
+// 30 days).
+func (r *Resolvers) SalespeoplePerformance(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	days, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	report, err := r.helper.SalespeopleReport(companyID, days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build salespeople report: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// CommissionRules lists the company's commission rules.
+func (r *Resolvers) CommissionRules(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	rules, err := r.helper.FetchCommissionRules(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to fetch commission rules: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+// SaveCommissionRule creates a commission rule or, under a {ruleID}, changes
+// it.
+func (r *Resolvers) SaveCommissionRule(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body lib.CommissionRuleSettings
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode commission rule: %w", err))
+	}
+
+	rule, err := r.helper.SaveCommissionRule(companyID, userID, c.Request.PathValue("ruleID"), body)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to save commission rule: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, rule)
+}
+
+// DeleteCommissionRule removes a commission rule.
+func (r *Resolvers) DeleteCommissionRule(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	if err := r.helper.DeleteCommissionRule(companyID, userID, c.Request.PathValue("ruleID")); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to delete commission rule: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CommissionStatements lists the issued statements, optionally of one
+// ?status= (payable or paid). With ?preview=1 it instead works out, without
+// issuing them, the statements between ?from= and ?to= (default the last 30
+// days).
+func (r *Resolvers) CommissionStatements(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	if query.Get("preview") == "" {
+		statements, err := r.helper.FetchCommissionStatements(companyID, query.Get("status"))
+		if err != nil {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to fetch commission statements: %w", err))
+		}
+		return c.JSON(http.StatusOK, statements)
+	}
+
+	days, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+	statements, err := r.helper.CommissionStatements(companyID, days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to work out commission: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, statements)
+}
+
+// IssueCommissionStatements issues the payable statements between ?from=
+// and ?to=, both required.
+func (r *Resolvers) IssueCommissionStatements(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	query := c.Request.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("from and to dates are required"))
+	}
+	days, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	statements, err := r.helper.IssueCommissionStatements(companyID, userID, days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to issue commission statements: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, statements)
+}
+
+// PayCommissionStatement marks an issued statement as paid.
+func (r *Resolvers) PayCommissionStatement(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	statement, err := r.helper.PayCommissionStatement(companyID, userID, c.Request.PathValue("statementID"))
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to pay commission statement: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, statement)
+}
@@ -0,0 +1,36 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type creditOverrideRequest struct {
+	Partner string  `json:"partner"`
+	Amount  float64 `json:"amount"`
+	Reason  string  `json:"reason"`
+}
+
+// ApproveCreditOverride lets the signed-in manager approve one sale over a
+// customer's credit limit. The returned override id is sent with the sale as
+// its credit_override.
+func (r *Resolvers) ApproveCreditOverride(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body creditOverrideRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode override data: %w", err))
+	}
+
+	override, err := r.helper.ApproveCreditOverride(companyID, body.Partner, userID, body.Amount, body.Reason)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusForbidden, fmt.Errorf("failed to approve credit override: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, override)
+}
@@ -0,0 +1,35 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FXGains lists the realised exchange gains and losses on the company's
+// invoices dated in ?from=&to=, in the base currency.
+func (r *Resolvers) FXGains(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	dateRange, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	base, err := r.helper.BaseCurrency(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, err)
+	}
+	gains, total, err := r.helper.FXGainsReport(companyID, dateRange)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch exchange gains: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"base_currency": base,
+		"invoices":      gains,
+		"total":         total,
+	})
+}
@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DailyLogExport downloads the ?log= (financial or inventory) spreadsheet of
+// the ?month= (YYYY-MM, this month by default) in the layout the bookkeepers
+// used before Dukahub.
+func (r *Resolvers) DailyLogExport(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	log := lib.DailyLog(query.Get("log"))
+	if log == "" {
+		log = lib.DailyLogFinancial
+	}
+	if log != lib.DailyLogFinancial && log != lib.DailyLogInventory {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown log %q", log))
+	}
+	month, err := lib.ParseMonth(query.Get("month"), time.Now().UTC())
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	buf, err := r.helper.ExportDailyLog(companyID, log, month)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to export daily log: %w", err))
+	}
+
+	c.Response.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", log.FileName(month)))
+	c.Response.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+
+	if _, err := io.Copy(c.Response, buf); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/kisinga/dukahub/views/pages/dashboard"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DigestSubscription returns the user's digest subscription to the company,
+// or null if they have none.
+func (r *Resolvers) DigestSubscription(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	subscription, err := r.helper.FetchDigestSubscription(userID, companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to fetch digest subscription: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, subscription)
+}
+
+// UpdateDigestSubscription subscribes the user to the company's daily or
+// weekly digest, or changes or pauses their subscription.
+func (r *Resolvers) UpdateDigestSubscription(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body lib.DigestSettings
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode digest subscription: %w", err))
+	}
+
+	subscription, err := r.helper.SubscribeToDigest(userID, companyID, body)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to update digest subscription: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, subscription)
+}
+
+// DigestPreview shows the company's ?frequency= (daily or weekly, default
+// daily) digest as it would be emailed now.
+func (r *Resolvers) DigestPreview(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	frequency := c.Request.URL.Query().Get("frequency")
+	if frequency == "" {
+		frequency = "daily"
+	}
+	digest, err := r.helper.BuildDigest(companyID, frequency, time.Now())
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build digest: %w", err))
+	}
+
+	return lib.Render(c, dashboard.DigestEmail(digest))
+}
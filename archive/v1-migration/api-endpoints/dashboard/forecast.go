@@ -0,0 +1,63 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DemandForecast forecasts each inventory line's daily sales for the next
+// ?horizon= days (default 14, at most 365) from ?history= days (default 84,
+// at most 730) of sales, and suggests reorder points covering ?lead_time=
+// days (default 7, at most 365), optionally for one ?product=.
+func (r *Resolvers) DemandForecast(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	q := lib.ForecastQuery{ProductID: query.Get("product")}
+	for name, value := range map[string]*int{"history": &q.History, "horizon": &q.Horizon, "lead_time": &q.LeadTime} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid %s %q", name, v))
+		}
+		*value = n
+	}
+
+	forecast, err := r.helper.ForecastDemand(companyID, q, time.Now())
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to forecast demand: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, forecast)
+}
+
+// ApplyReorderPoints sets the forecast's suggested reorder points on the
+// inventory lines in the body, or on all lines when it lists none.
+func (r *Resolvers) ApplyReorderPoints(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body struct {
+		lib.ForecastQuery
+		InventoryIDs []string `json:"inventory_ids"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode reorder points: %w", err))
+	}
+
+	lines, err := r.helper.ApplyReorderPoints(companyID, userID, body.ForecastQuery, body.InventoryIDs)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to apply reorder points: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, lines)
+}
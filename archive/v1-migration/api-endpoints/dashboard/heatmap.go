@@ -0,0 +1,28 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SalesHeatmap returns the company's sales by local weekday and hour between
+// ?from= and ?to= (default the last four weeks), for all branches or only
+// ?branch=.
+func (r *Resolvers) SalesHeatmap(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	days, err := lib.ParseDateRange(c, 28)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	heatmap, err := r.helper.SalesHeatmap(companyID, c.Request.URL.Query().Get("branch"), days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build sales heatmap: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, heatmap)
+}
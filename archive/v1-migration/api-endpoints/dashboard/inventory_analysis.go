@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ABCClassification ranks the company's products into A, B and C by their
+// ?basis= (revenue or margin, default revenue) between ?from= and ?to=
+// (default the last 90 days).
+func (r *Resolvers) ABCClassification(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	days, err := lib.ParseDateRange(c, 90)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	report, err := r.helper.ABCClassification(companyID, days, c.Request.URL.Query().Get("basis"))
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to classify products: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// DeadStock lists the stock that has not sold in ?days= (default 90) days
+// as of ?as_of= (default now) and the capital tied up in it.
+func (r *Resolvers) DeadStock(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	days := 90
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid days %q", v))
+		}
+		days = n
+	}
+	asOf := time.Now().UTC()
+	if v := query.Get("as_of"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid as_of date: %w", err))
+		}
+		asOf = t.AddDate(0, 0, 1)
+	}
+
+	report, err := r.helper.DeadStock(companyID, days, asOf)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to find dead stock: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/kisinga/dukahub/views/pages/dashboard"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// InvoiceDocument shows a printable invoice; ?format=pdf downloads it.
+func (r *Resolvers) InvoiceDocument(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	invoiceID := c.Request.PathValue("invoiceID")
+
+	doc, err := r.helper.InvoiceDocument(companyID, invoiceID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build invoice: %w", err))
+	}
+
+	if c.Request.URL.Query().Get("format") != "pdf" {
+		return lib.Render(c, dashboard.InvoiceDocument(doc))
+	}
+
+	buf := new(bytes.Buffer)
+	if err := lib.WriteInvoicePDF(buf, doc); err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to write invoice: %w", err))
+	}
+	c.Response.Header().Set("Content-Type", "application/pdf")
+	c.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Number+".pdf"))
+	c.Response.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, err = io.Copy(c.Response, buf)
+	return err
+}
+
+type sendInvoiceRequest struct {
+	To []string `json:"to"`
+}
+
+// SendInvoice emails the invoice, with its PDF attached, to the addresses
+// in the request.
+func (r *Resolvers) SendInvoice(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	invoiceID := c.Request.PathValue("invoiceID")
+
+	var body sendInvoiceRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode recipients: %w", err))
+	}
+
+	doc, err := r.helper.InvoiceDocument(companyID, invoiceID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build invoice: %w", err))
+	}
+	render := func(doc *lib.InvoiceDocument) (string, error) {
+		buf := new(bytes.Buffer)
+		err := dashboard.InvoiceDocument(doc).Render(context.Background(), buf)
+		return buf.String(), err
+	}
+	if err := r.helper.EmailInvoice(doc, body.To, render); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "sent"})
+}
@@ -0,0 +1,95 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var ledgerContentTypes = map[lib.LedgerFormat]string{
+	lib.LedgerFormatJournal:    "text/csv",
+	lib.LedgerFormatQuickBooks: "text/plain",
+	lib.LedgerFormatXero:       "text/csv",
+}
+
+// LedgerExport downloads the company's transactions for ?from=&to= as a
+// journal in the ?format= (journal, iif or xero) of the accountant's software.
+func (r *Resolvers) LedgerExport(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	format := lib.LedgerFormat(c.Request.URL.Query().Get("format"))
+	if format == "" {
+		format = lib.LedgerFormatJournal
+	}
+	contentType, ok := ledgerContentTypes[format]
+	if !ok {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown format %q", format))
+	}
+
+	dateRange, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	buf, err := r.helper.ExportLedger(companyID, dateRange, format)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to export ledger: %w", err))
+	}
+
+	c.Response.Header().Set("Content-Type", contentType)
+	c.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", format.FileName(dateRange)))
+	c.Response.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+
+	if _, err := io.Copy(c.Response, buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+// LedgerMappings lists the company's ledger account mappings.
+func (r *Resolvers) LedgerMappings(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	mappings, err := r.helper.FetchLedgerMappings(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to fetch ledger mappings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, mappings)
+}
+
+// SaveLedgerMapping creates a ledger account mapping or, under a
+// {mappingID}, changes it.
+func (r *Resolvers) SaveLedgerMapping(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body lib.LedgerMappingSettings
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode ledger mapping: %w", err))
+	}
+
+	mapping, err := r.helper.SaveLedgerMapping(companyID, userID, c.Request.PathValue("mappingID"), body)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to save ledger mapping: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, mapping)
+}
+
+// DeleteLedgerMapping removes a ledger account mapping.
+func (r *Resolvers) DeleteLedgerMapping(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	if err := r.helper.DeleteLedgerMapping(companyID, userID, c.Request.PathValue("mappingID")); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to delete ledger mapping: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
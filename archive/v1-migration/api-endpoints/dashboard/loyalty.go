@@ -0,0 +1,41 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// LoyaltyAccount returns a customer's loyalty points and ledger.
+func (r *Resolvers) LoyaltyAccount(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	partnerID := c.Request.PathValue("partnerID")
+
+	account, err := r.helper.LoyaltyAccount(companyID, partnerID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to fetch loyalty points: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
+
+// UpdateLoyaltyProgram sets up or changes the company's loyalty program.
+func (r *Resolvers) UpdateLoyaltyProgram(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body lib.LoyaltyProgramSettings
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode loyalty program: %w", err))
+	}
+
+	program, err := r.helper.ConfigureLoyaltyProgram(companyID, userID, body)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to update loyalty program: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, program)
+}
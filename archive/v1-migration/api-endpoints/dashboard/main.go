@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/kisinga/dukahub/lib"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 type Resolvers struct {
@@ -35,6 +37,18 @@ func (r *Resolvers) AuthCheck(e *core.RequestEvent) error {
 	return e.Next()
 }
 
+// CompanyCheck makes sure the logged in user is a member of the company in
+// the route. It must run after AuthCheck.
+func (r *Resolvers) CompanyCheck(e *core.RequestEvent) error {
+	userID, _ := e.Get("userID").(string)
+	ok, err := r.helper.UserHasCompany(userID, e.Request.PathValue("companyID"))
+	if err != nil || !ok {
+		return e.Redirect(http.StatusFound, "/dashboard/")
+	}
+
+	return e.Next()
+}
+
 func (r *Resolvers) Root(e *core.RequestEvent) error {
 	cookie, err := e.Request.Cookie("pb_users_auth")
 	if err != nil {
@@ -57,3 +71,15 @@ func (r *Resolvers) Root(e *core.RequestEvent) error {
 	}
 	return e.Redirect(307, fmt.Sprintf("/dashboard/%s", companies[0]))
 }
+
+// parseOptionalDate parses a YYYY-MM-DD body field, leaving it zero if empty.
+func parseOptionalDate(v string) (types.DateTime, error) {
+	if v == "" {
+		return types.DateTime{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return types.DateTime{}, err
+	}
+	return types.ParseDateTime(t)
+}
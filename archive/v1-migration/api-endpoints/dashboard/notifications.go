@@ -0,0 +1,40 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Notifications lists the company's outbox, optionally filtered by ?status=.
+func (r *Resolvers) Notifications(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	status := c.Request.URL.Query().Get("status")
+	limit, _ := strconv.Atoi(c.Request.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	notifications, err := r.helper.FetchNotifications(companyID, status, limit)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch notifications: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+// RetryNotification queues a failed notification to be sent again.
+func (r *Resolvers) RetryNotification(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	notificationID := c.Request.PathValue("notificationID")
+
+	notification, err := r.helper.RetryNotification(companyID, notificationID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to retry notification: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, notification)
+}
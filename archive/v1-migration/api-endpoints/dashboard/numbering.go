@@ -0,0 +1,48 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type documentSequenceRequest struct {
+	Prefix      string `json:"prefix"`
+	ResetYearly bool   `json:"reset_yearly"`
+	Padding     int    `json:"padding"`
+}
+
+// DocumentSequences lists the company's document numbering.
+func (r *Resolvers) DocumentSequences(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	sequences, err := r.helper.FetchDocumentSequences(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch document numbering: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, sequences)
+}
+
+// UpdateDocumentSequence changes the prefix, yearly reset and padding of one
+// document type's numbering.
+func (r *Resolvers) UpdateDocumentSequence(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+	docType := c.Request.PathValue("documentType")
+
+	var body documentSequenceRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode numbering data: %w", err))
+	}
+
+	seq, err := r.helper.ConfigureDocumentSequence(companyID, userID, docType, body.Prefix, body.ResetYearly, body.Padding)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to update document numbering: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, seq)
+}
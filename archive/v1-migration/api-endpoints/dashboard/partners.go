@@ -0,0 +1,60 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DuplicatePartners lists pairs of partners that are probably the same.
+func (r *Resolvers) DuplicatePartners(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	matches, err := r.helper.FindDuplicatePartners(companyID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to find duplicate partners: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, matches)
+}
+
+// MatchPartner lists existing partners matching ?name= and ?phone=, for
+// checking before a new partner is created.
+func (r *Resolvers) MatchPartner(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	matches, err := r.helper.MatchPartner(companyID, query.Get("name"), query.Get("phone"))
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to match partner: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, matches)
+}
+
+type mergePartnersRequest struct {
+	Duplicates []string `json:"duplicates"`
+}
+
+// MergePartners folds the duplicates listed in the request into the partner
+// in the path.
+func (r *Resolvers) MergePartners(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+	partnerID := c.Request.PathValue("partnerID")
+
+	var body mergePartnersRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode merge data: %w", err))
+	}
+
+	merge, err := r.helper.MergePartners(companyID, partnerID, body.Duplicates, userID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to merge partners: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, merge)
+}
@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type paymentRequest struct {
+	Partner     string                  `json:"partner"`
+	Account     string                  `json:"account"`
+	Type        string                  `json:"type"`
+	Amount      float64                 `json:"amount"`
+	Date        string                  `json:"date"`
+	Allocations []lib.PaymentAllocation `json:"allocations"`
+}
+
+// CreatePayment records a customer receipt (type "sale") or supplier payment
+// (type "purchase") and allocates it to the partner's invoices.
+func (r *Resolvers) CreatePayment(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body paymentRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode payment data: %w", err))
+	}
+
+	req := lib.PaymentRequest{
+		CompanyID:   companyID,
+		PartnerID:   body.Partner,
+		AccountID:   body.Account,
+		Amount:      body.Amount,
+		AuthorID:    userID,
+		Allocations: body.Allocations,
+	}
+	switch body.Type {
+	case "", "sale":
+		req.InvoiceType = models.Sale
+	case "purchase":
+		req.InvoiceType = models.Purchase
+	default:
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown payment type %q", body.Type))
+	}
+	date, err := parseOptionalDate(body.Date)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid date: %w", err))
+	}
+	req.Date = date
+
+	payment, err := r.helper.AllocatePayment(req)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to record payment: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, payment)
+}
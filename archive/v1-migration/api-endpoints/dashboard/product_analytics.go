@@ -0,0 +1,57 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ProductAnalytics ranks the company's products in the ?period= (day, week
+// or month, default week) containing ?date= (default today), compared with
+// the period containing ?compare= or else the one before. ?category=, ?sort=
+// (revenue, units_sold or profit) and ?limit= narrow the ranking.
+func (r *Resolvers) ProductAnalytics(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	query := c.Request.URL.Query()
+
+	q := lib.ProductAnalyticsQuery{
+		PeriodType: query.Get("period"),
+		Category:   query.Get("category"),
+		SortBy:     query.Get("sort"),
+	}
+	if q.PeriodType == "" {
+		q.PeriodType = "week"
+	}
+	if v := query.Get("date"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid date: %w", err))
+		}
+		q.Date = t
+	}
+	if v := query.Get("compare"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid compare date: %w", err))
+		}
+		q.CompareDate = t
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+		}
+		q.Limit = limit
+	}
+
+	report, err := r.helper.QueryProductAnalytics(companyID, q)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to rank products: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
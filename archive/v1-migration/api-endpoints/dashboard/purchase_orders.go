@@ -0,0 +1,107 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type purchaseOrderRequest struct {
+	Supplier     string                       `json:"supplier"`
+	Currency     string                       `json:"currency"`
+	ExpectedDate string                       `json:"expected_date"`
+	Notes        string                       `json:"notes"`
+	Lines        []lib.PurchaseOrderLineInput `json:"lines"`
+}
+
+type goodsReceiptRequest struct {
+	Date  string                      `json:"date"`
+	Notes string                      `json:"notes"`
+	Lines []lib.GoodsReceiptLineInput `json:"lines"`
+}
+
+func (r *Resolvers) CreatePurchaseOrder(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body purchaseOrderRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode purchase order: %w", err))
+	}
+	expected, err := parseOptionalDate(body.ExpectedDate)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid expected_date: %w", err))
+	}
+
+	order, err := r.helper.CreatePurchaseOrder(lib.PurchaseOrderRequest{
+		CompanyID:    companyID,
+		SupplierID:   body.Supplier,
+		UserID:       userID,
+		Currency:     body.Currency,
+		ExpectedDate: expected,
+		Notes:        body.Notes,
+		Lines:        body.Lines,
+	})
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to create purchase order: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, order)
+}
+
+func (r *Resolvers) GetPurchaseOrder(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	orderID := c.Request.PathValue("orderID")
+
+	order, err := r.helper.FetchPurchaseOrder(companyID, orderID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusNotFound, err)
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (r *Resolvers) CancelPurchaseOrder(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	orderID := c.Request.PathValue("orderID")
+
+	order, err := r.helper.CancelPurchaseOrder(companyID, orderID)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to cancel purchase order: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+// ReceiveGoods records a (possibly partial) delivery against a purchase order.
+func (r *Resolvers) ReceiveGoods(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+	orderID := c.Request.PathValue("orderID")
+
+	var body goodsReceiptRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode goods receipt: %w", err))
+	}
+	date, err := parseOptionalDate(body.Date)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid date: %w", err))
+	}
+
+	receipt, err := r.helper.ReceiveGoods(lib.GoodsReceiptRequest{
+		CompanyID:       companyID,
+		PurchaseOrderID: orderID,
+		UserID:          userID,
+		Date:            date,
+		Notes:           body.Notes,
+		Lines:           body.Lines,
+	})
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to receive goods: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, receipt)
+}
@@ -0,0 +1,54 @@
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/kisinga/dukahub/views/pages/dashboard"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// PartnerStatement shows a partner's statement for ?from=&to= (default the
+// last 90 days). ?format=pdf or csv downloads it instead.
+func (r *Resolvers) PartnerStatement(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+	partnerID := c.Request.PathValue("partnerID")
+
+	dateRange, err := lib.ParseDateRange(c, 90)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	st, err := r.helper.PartnerStatement(companyID, partnerID, dateRange)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to build statement: %w", err))
+	}
+
+	buf := new(bytes.Buffer)
+	var contentType, ext string
+	switch c.Request.URL.Query().Get("format") {
+	case "pdf":
+		contentType, ext = "application/pdf", "pdf"
+		err = lib.WriteStatementPDF(buf, st)
+	case "csv":
+		contentType, ext = "text/csv", "csv"
+		err = lib.WriteStatementCSV(buf, st)
+	default:
+		return lib.Render(c, dashboard.Statement(st))
+	}
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to write statement: %w", err))
+	}
+
+	fileName := fmt.Sprintf("statement-%s-%s.%s", partnerID, st.LastDay().Format(time.DateOnly), ext)
+	c.Response.Header().Set("Content-Type", contentType)
+	c.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Response.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, err = io.Copy(c.Response, buf)
+	return err
+}
@@ -0,0 +1,27 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DailySummaries returns the company's daily summaries between ?from= and
+// ?to= (default the last 30 days).
+func (r *Resolvers) DailySummaries(c *core.RequestEvent) error {
+	companyID := c.Request.PathValue("companyID")
+
+	days, err := lib.ParseDateRange(c, 30)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, err)
+	}
+
+	summaries, err := r.helper.FetchDailySummaries(companyID, days)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch daily summaries: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, summaries)
+}
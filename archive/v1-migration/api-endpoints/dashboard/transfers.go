@@ -0,0 +1,53 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type transferRequest struct {
+	FromAccount string  `json:"from_account"`
+	ToAccount   string  `json:"to_account"`
+	Amount      float64 `json:"amount"`
+	ToAmount    float64 `json:"to_amount"`
+	Fee         float64 `json:"fee"`
+	Date        string  `json:"date"`
+	Notes       string  `json:"notes"`
+}
+
+func (r *Resolvers) CreateTransfer(c *core.RequestEvent) error {
+	userID := c.Get("userID").(string)
+	companyID := c.Request.PathValue("companyID")
+
+	var body transferRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to decode transfer data: %w", err))
+	}
+
+	req := lib.TransferRequest{
+		CompanyID:     companyID,
+		FromAccountID: body.FromAccount,
+		ToAccountID:   body.ToAccount,
+		Amount:        body.Amount,
+		ToAmount:      body.ToAmount,
+		Fee:           body.Fee,
+		Notes:         body.Notes,
+		AuthorID:      userID,
+	}
+	date, err := parseOptionalDate(body.Date)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("invalid date: %w", err))
+	}
+	req.Date = date
+
+	transfer, err := r.helper.TransferBetweenAccounts(req)
+	if err != nil {
+		return lib.ReturnJSONError(c, http.StatusBadRequest, fmt.Errorf("failed to transfer: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, transfer)
+}
@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ErrPeriodClosed is returned (wrapped) whenever a record would be created,
+// edited or deleted inside a closed accounting period.
+var ErrPeriodClosed = errors.New("accounting period is closed")
+
+// periodLockedCollections maps every collection that is locked by a closed
+// accounting period to the field holding the record's business date.
+// Expenses have no date of their own, so the creation date is used.
+var periodLockedCollections = map[string]string{
+	"sales_transactions": "transaction_date",
+	"purchases":          "date",
+	"expenses":           "created",
+	"transactions":       "date",
+}
+
+// BindPeriodLocks registers the record hooks that reject creates, updates,
+// soft-deletes and deletes dated inside a closed accounting period.
+func (helper *DbHelper) BindPeriodLocks() {
+	collections := make([]string, 0, len(periodLockedCollections))
+	for name := range periodLockedCollections {
+		collections = append(collections, name)
+	}
+
+	helper.pb.OnRecordCreate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := checkPeriodOpen(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	helper.pb.OnRecordUpdate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		// the record may neither be edited where it was nor moved into a closed period
+		if err := checkPeriodOpen(e.App, e.Record.Original()); err != nil {
+			return err
+		}
+		if err := checkPeriodOpen(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	helper.pb.OnRecordDelete(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := checkPeriodOpen(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+}
+
+func checkPeriodOpen(app core.App, record *core.Record) error {
+	field, ok := periodLockedCollections[record.Collection().Name]
+	if !ok {
+		return nil
+	}
+	date := record.GetDateTime(field)
+	if date.IsZero() {
+		// new records without a date yet are stamped "now" on save
+		date = types.NowDateTime()
+	}
+	period, err := findClosedPeriod(app, record.GetString("company"), date.Time())
+	if err != nil {
+		return err
+	}
+	if period != nil {
+		return fmt.Errorf("%w: %s is dated %s, inside %q", ErrPeriodClosed,
+			record.Collection().Name, date.Time().Format(time.DateOnly), period.Name())
+	}
+	return nil
+}
+
+// findClosedPeriod returns the closed period of the company that contains
+// the given date or nil if the date is open for editing.
+func findClosedPeriod(app core.App, companyID string, date time.Time) (*models.AccountingPeriods, error) {
+	if companyID == "" {
+		return nil, nil
+	}
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.AccountingPeriods](),
+		"company = {:company} && status = 'closed'",
+		"start_date",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		period, err := models.WrapRecord[models.AccountingPeriods](record)
+		if err != nil {
+			return nil, err
+		}
+		if periodContains(period, date) {
+			return period, nil
+		}
+	}
+	return nil, nil
+}
+
+// periodContains treats both period bounds as whole days, so a period
+// ending on the 31st covers everything until midnight of the 1st.
+func periodContains(period *models.AccountingPeriods, date time.Time) bool {
+	start := startOfDay(period.StartDate().Time())
+	end := startOfDay(period.EndDate().Time()).AddDate(0, 0, 1)
+	return !date.Before(start) && date.Before(end)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (helper *DbHelper) FetchAccountingPeriods(companyID string) ([]*models.AccountingPeriods, error) {
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.AccountingPeriods](),
+		"company = {:company}",
+		"-start_date",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]*models.AccountingPeriods, len(records))
+	for i, record := range records {
+		period, err := models.WrapRecord[models.AccountingPeriods](record)
+		if err != nil {
+			return nil, err
+		}
+		periods[i] = period
+	}
+	return periods, nil
+}
+
+// CreateAccountingPeriod opens a new period for the company. Periods of the
+// same company may not overlap.
+func (helper *DbHelper) CreateAccountingPeriod(companyID, name string, start, end time.Time) (*models.AccountingPeriods, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("period end %s is before its start %s", end.Format(time.DateOnly), start.Format(time.DateOnly))
+	}
+
+	existing, err := helper.FetchAccountingPeriods(companyID)
+	if err != nil {
+		return nil, err
+	}
+	for _, period := range existing {
+		if periodContains(period, start) || periodContains(period, end) ||
+			(start.Before(period.StartDate().Time()) && end.After(period.EndDate().Time())) {
+			return nil, fmt.Errorf("period overlaps with %q", period.Name())
+		}
+	}
+
+	period, err := models.NewProxy[models.AccountingPeriods](helper.pb)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s - %s", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	}
+	startDate, err := types.ParseDateTime(startOfDay(start))
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := types.ParseDateTime(startOfDay(end))
+	if err != nil {
+		return nil, err
+	}
+	period.Set("company", companyID)
+	period.SetName(name)
+	period.SetStartDate(startDate)
+	period.SetEndDate(endDate)
+	period.SetStatus(models.PeriodOpen)
+
+	if err := helper.pb.Save(period); err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// CloseAccountingPeriod locks the period against edits. Only admins may close
+// a period and a reason must be recorded.
+func (helper *DbHelper) CloseAccountingPeriod(periodID, adminID, reason string) (*models.AccountingPeriods, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to close a period")
+	}
+	period, err := helper.fetchAccountingPeriod(periodID)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status() == models.PeriodClosed {
+		return nil, fmt.Errorf("period %q is already closed", period.Name())
+	}
+	period.SetStatus(models.PeriodClosed)
+	period.SetClosedAt(types.NowDateTime())
+	period.Set("closed_by", adminID)
+	period.SetCloseReason(reason)
+	if err := helper.pb.Save(period); err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// ReopenAccountingPeriod unlocks a closed period. Only admins may reopen a
+// period and a reason must be recorded.
+func (helper *DbHelper) ReopenAccountingPeriod(periodID, adminID, reason string) (*models.AccountingPeriods, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to reopen a period")
+	}
+	period, err := helper.fetchAccountingPeriod(periodID)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status() != models.PeriodClosed {
+		return nil, fmt.Errorf("period %q is not closed", period.Name())
+	}
+	period.SetStatus(models.PeriodOpen)
+	period.SetReopenedAt(types.NowDateTime())
+	period.Set("reopened_by", adminID)
+	period.SetReopenReason(reason)
+	if err := helper.pb.Save(period); err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+func (helper *DbHelper) fetchAccountingPeriod(periodID string) (*models.AccountingPeriods, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.AccountingPeriods](), periodID)
+	if err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.AccountingPeriods](record)
+}
@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+)
+
+// AgingBuckets splits open balances by how long their invoice is overdue.
+type AgingBuckets struct {
+	Current    float64 `json:"current"`
+	Days30     float64 `json:"days_30"`
+	Days60     float64 `json:"days_60"`
+	Days90Plus float64 `json:"days_90_plus"`
+	Total      float64 `json:"total"`
+}
+
+func (b *AgingBuckets) add(days int, amount float64) {
+	switch {
+	case days <= 30:
+		b.Current = roundMoney(b.Current + amount)
+	case days <= 60:
+		b.Days30 = roundMoney(b.Days30 + amount)
+	case days <= 90:
+		b.Days60 = roundMoney(b.Days60 + amount)
+	default:
+		b.Days90Plus = roundMoney(b.Days90Plus + amount)
+	}
+	b.Total = roundMoney(b.Total + amount)
+}
+
+func (b *AgingBuckets) merge(other AgingBuckets) {
+	b.Current = roundMoney(b.Current + other.Current)
+	b.Days30 = roundMoney(b.Days30 + other.Days30)
+	b.Days60 = roundMoney(b.Days60 + other.Days60)
+	b.Days90Plus = roundMoney(b.Days90Plus + other.Days90Plus)
+	b.Total = roundMoney(b.Total + other.Total)
+}
+
+// AgingRow is one partner's open receivables and payables.
+//
+// Balance is the receivables less the payables less the partner's credit and
+// should equal the stored PartnerBalance; a mismatch means the partner needs
+// a recompute or has invoices dated after the report date.
+type AgingRow struct {
+	PartnerID      string       `json:"partner_id"`
+	Name           string       `json:"name"`
+	Phone          string       `json:"phone"`
+	Receivable     AgingBuckets `json:"receivable"`
+	Payable        AgingBuckets `json:"payable"`
+	Credit         float64      `json:"credit"`
+	Balance        float64      `json:"balance"`
+	PartnerBalance float64      `json:"partner_balance"`
+	Reconciled     bool         `json:"reconciled"`
+	OldestDays     int          `json:"oldest_days"`
+}
+
+// AgingReport holds the aging of every partner with an open balance, in the
+// company's base currency.
+type AgingReport struct {
+	AsOf         time.Time    `json:"as_of"`
+	Currency     string       `json:"currency"`
+	Rows         []AgingRow   `json:"rows"`
+	Receivable   AgingBuckets `json:"receivable"`
+	Payable      AgingBuckets `json:"payable"`
+	Unreconciled int          `json:"unreconciled"`
+}
+
+// AgingReport ages the open sale (receivable) and purchase (payable) invoice
+// balances of every partner as of the given day, from their due date or,
+// without one, their date; balances not yet due count as current. Unpaid
+// remainders of sales on credit not yet billed on an invoice are aged as
+// receivables from the sale date.
+//
+// Rows are ordered for the weekly collection calls: most overdue receivables
+// first, then by the amount owed.
+func (helper *DbHelper) AgingReport(companyID string, asOf time.Time) (*AgingReport, error) {
+	asOf = startOfDay(asOf)
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	partners, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Partners](),
+		"company = {:company}",
+		"name",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"company = {:company} && bal > 0 && date < {:to}",
+		"date",
+		0,
+		0,
+		dbx.Params{"company": companyID, "to": formatDate(asOf.AddDate(0, 0, 1))},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && customer != '' && remaining_balance > 0 && transaction_date < {:to}",
+		"transaction_date",
+		0,
+		0,
+		dbx.Params{"company": companyID, "to": formatDate(asOf.AddDate(0, 0, 1))},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]*AgingRow, len(partners))
+	rowFor := func(partnerID string) *AgingRow {
+		row, ok := rows[partnerID]
+		if !ok {
+			row = &AgingRow{PartnerID: partnerID}
+			rows[partnerID] = row
+		}
+		return row
+	}
+	for _, invoice := range invoices {
+		row := rowFor(invoice.GetString("partner"))
+		// invoices with terms age from the day they fall due
+		due := invoice.GetDateTime("due_date")
+		if due.IsZero() {
+			due = invoice.GetDateTime("date")
+		}
+		days := int(asOf.Sub(startOfDay(due.Time())).Hours() / 24)
+		if invoice.GetString("type") == "purchase" {
+			row.Payable.add(days, BaseAmount(invoice, "bal"))
+		} else {
+			row.Receivable.add(days, BaseAmount(invoice, "bal"))
+		}
+		row.OldestDays = max(row.OldestDays, days)
+	}
+	billed, err := billedSales(helper.pb, companyID, "", asOf.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	for _, sale := range sales {
+		if billed[sale.Id] != nil {
+			// aged with the invoice billing it
+			continue
+		}
+		row := rowFor(sale.GetString("customer"))
+		days := int(asOf.Sub(startOfDay(sale.GetDateTime("transaction_date").Time())).Hours() / 24)
+		row.Receivable.add(days, BaseAmount(sale, "remaining_balance"))
+		row.OldestDays = max(row.OldestDays, days)
+	}
+
+	report := &AgingReport{AsOf: asOf, Currency: currency, Rows: make([]AgingRow, 0, len(rows))}
+	for _, partner := range partners {
+		row, ok := rows[partner.Id]
+		if !ok {
+			if partner.GetFloat("credit") == 0 && partner.GetFloat("balance") == 0 {
+				continue
+			}
+			row = &AgingRow{PartnerID: partner.Id}
+		}
+		row.Name = partner.GetString("name")
+		row.Phone = partner.GetString("phone")
+		row.Credit = partner.GetFloat("credit")
+		row.Balance = roundMoney(row.Receivable.Total - row.Payable.Total - row.Credit)
+		row.PartnerBalance = partner.GetFloat("balance")
+		row.Reconciled = row.Balance == roundMoney(row.PartnerBalance)
+		if !row.Reconciled {
+			report.Unreconciled++
+		}
+		report.Receivable.merge(row.Receivable)
+		report.Payable.merge(row.Payable)
+		report.Rows = append(report.Rows, *row)
+	}
+
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i].Receivable, report.Rows[j].Receivable
+		if a.Days90Plus != b.Days90Plus {
+			return a.Days90Plus > b.Days90Plus
+		}
+		return a.Total > b.Total
+	})
+	return report, nil
+}
+
+// WriteAgingCSV writes one row per partner with both receivable and payable
+// buckets.
+func WriteAgingCSV(w io.Writer, report *AgingReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"Partner", "Phone",
+		"Receivable 0-30", "Receivable 31-60", "Receivable 61-90", "Receivable 90+", "Receivable Total",
+		"Payable 0-30", "Payable 31-60", "Payable 61-90", "Payable 90+", "Payable Total",
+		"Credit", "Balance", "Partner Balance", "Reconciled",
+	})
+	for _, row := range report.Rows {
+		reconciled := "yes"
+		if !row.Reconciled {
+			reconciled = "no"
+		}
+		cw.Write([]string{
+			row.Name, row.Phone,
+			formatAmount(row.Receivable.Current), formatAmount(row.Receivable.Days30), formatAmount(row.Receivable.Days60),
+			formatAmount(row.Receivable.Days90Plus), formatAmount(row.Receivable.Total),
+			formatAmount(row.Payable.Current), formatAmount(row.Payable.Days30), formatAmount(row.Payable.Days60),
+			formatAmount(row.Payable.Days90Plus), formatAmount(row.Payable.Total),
+			formatAmount(row.Credit), formatAmount(row.Balance), formatAmount(row.PartnerBalance), reconciled,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
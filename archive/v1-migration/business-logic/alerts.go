@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// paymentReminderInterval is how often a customer is reminded of an invoice
+// while it stays overdue.
+const paymentReminderInterval = 7 * 24 * time.Hour
+
+// BindAlerts queues the messages the outbox sends about day-to-day trading:
+// an SMS receipt for every sale to a customer, a low-stock alert to the
+// company when an inventory line falls to its reorder point, and a weekly
+// payment reminder for each overdue sale invoice.
+func (helper *DbHelper) BindAlerts() {
+	helper.pb.OnRecordCreate(models.CName[models.SalesTransactions]()).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return queueReceipt(e.App, e.Record)
+	})
+
+	helper.pb.OnRecordUpdate(models.CName[models.Inventory]()).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return queueLowStockAlert(e.App, e.Record)
+	})
+
+	helper.pb.Cron().MustAdd("paymentReminders", "0 6 * * *", func() {
+		if _, err := helper.SendPaymentReminders(time.Now()); err != nil {
+			helper.Logger.Printf("Error sending payment reminders: %v", err)
+		}
+	})
+}
+
+// validPhone reports whether a phone number can take an SMS.
+func validPhone(phone string) bool {
+	return len(normalizePhone(phone)) >= 9
+}
+
+// queueReceipt texts the customer of a new sale its number, total and what
+// is left to pay. Returns and sales without a customer get no receipt.
+func queueReceipt(app core.App, sale *core.Record) error {
+	if sale.GetString("customer") == "" || sale.GetString("transaction_type") == "return" {
+		return nil
+	}
+	customer, err := app.FindRecordById(models.CName[models.Partners](), sale.GetString("customer"))
+	if err != nil {
+		return err
+	}
+	if !validPhone(customer.GetString("phone")) {
+		return nil
+	}
+	company, err := app.FindRecordById(models.CName[models.Companies](), sale.GetString("company"))
+	if err != nil {
+		return err
+	}
+
+	currency := sale.GetString("currency")
+	body := fmt.Sprintf("%s: thank you for your purchase. Sale %s of %s %s on %s.",
+		company.GetString("name"),
+		sale.GetString("number"),
+		currency,
+		formatAmount(sale.GetFloat("total_amount")),
+		sale.GetDateTime("transaction_date").Time().In(companyLocation(company)).Format("02 Jan 2006"),
+	)
+	if balance := sale.GetFloat("remaining_balance"); balance > 0 {
+		body += fmt.Sprintf(" Balance due: %s %s.", currency, formatAmount(balance))
+	}
+	_, err = queueNotification(app, Notification{
+		Company:     company.Id,
+		Channel:     "sms",
+		Recipient:   customer.GetString("phone"),
+		Body:        body,
+		Kind:        "receipt",
+		ReferenceID: sale.Id,
+	})
+	return err
+}
+
+// queueLowStockAlert tells the company, by SMS to its phone and by email to
+// its contact person, when an inventory line has just fallen to or below its
+// reorder point. A line already low is not reported again until restocked.
+func queueLowStockAlert(app core.App, inventory *core.Record) error {
+	reorderPoint := inventory.GetFloat("reorder_point")
+	if reorderPoint <= 0 {
+		return nil
+	}
+	quantity := inventory.GetFloat("current_quantity")
+	if quantity > reorderPoint || inventory.Original().GetFloat("current_quantity") <= reorderPoint {
+		return nil
+	}
+	company, err := app.FindRecordById(models.CName[models.Companies](), inventory.GetString("company"))
+	if err != nil {
+		return err
+	}
+	product, err := app.FindRecordById(models.CName[models.Products](), inventory.GetString("product"))
+	if err != nil {
+		return err
+	}
+	name := product.GetString("name")
+	if skuID := inventory.GetString("sku"); skuID != "" {
+		if sku, err := app.FindRecordById(models.CName[models.Skus](), skuID); err == nil {
+			name += " (" + sku.GetString("name") + ")"
+		}
+	}
+
+	subject := fmt.Sprintf("Low stock: %s", name)
+	body := fmt.Sprintf("%s: %s is down to %g, at or below its reorder point of %g.", company.GetString("name"), name, quantity, reorderPoint)
+	recipients := map[string]string{}
+	if phone := company.GetString("phone"); validPhone(phone) {
+		recipients["sms"] = phone
+	}
+	if email := company.GetString("contact_person_email"); email != "" {
+		recipients["email"] = email
+	}
+	for channel, recipient := range recipients {
+		if _, err := queueNotification(app, Notification{
+			Company:     company.Id,
+			Channel:     channel,
+			Recipient:   recipient,
+			Subject:     subject,
+			Body:        body,
+			Kind:        "low_stock",
+			ReferenceID: inventory.Id,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendPaymentReminders texts the customer of every sale invoice overdue at
+// now, at most once per paymentReminderInterval, and returns how many
+// reminders were queued.
+func (helper *DbHelper) SendPaymentReminders(now time.Time) (int, error) {
+	invoices, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"type = 'sale' && bal > 0 && due_date != '' && due_date < {:today}",
+		"due_date",
+		0,
+		0,
+		dbx.Params{"today": formatDate(startOfDay(now))},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if errs := helper.pb.ExpandRecords(invoices, []string{"partner", "company"}, nil); len(errs) > 0 {
+		return 0, fmt.Errorf("failed to load invoice customers: %v", errs)
+	}
+
+	sent := 0
+	for _, invoice := range invoices {
+		partner, company := invoice.ExpandedOne("partner"), invoice.ExpandedOne("company")
+		if partner == nil || company == nil || !validPhone(partner.GetString("phone")) {
+			continue
+		}
+		reminded, err := helper.pb.CountRecords(
+			models.CName[models.Notifications](),
+			dbx.HashExp{"kind": "payment_reminder", "reference_id": invoice.Id},
+			dbx.NewExp("created > {:since}", dbx.Params{"since": formatDate(now.Add(-paymentReminderInterval))}),
+		)
+		if err != nil {
+			return sent, err
+		}
+		if reminded > 0 {
+			continue
+		}
+
+		number := invoice.GetString("number")
+		if number == "" {
+			number = invoice.Id
+		}
+		body := fmt.Sprintf("%s: invoice %s was due on %s and %s %s is outstanding. Please pay at your earliest convenience.",
+			company.GetString("name"),
+			number,
+			invoice.GetDateTime("due_date").Time().In(companyLocation(company)).Format("02 Jan 2006"),
+			invoice.GetString("currency"),
+			formatAmount(invoice.GetFloat("bal")),
+		)
+		if _, err := queueNotification(helper.pb, Notification{
+			Company:     company.Id,
+			Channel:     "sms",
+			Recipient:   partner.GetString("phone"),
+			Body:        body,
+			Kind:        "payment_reminder",
+			ReferenceID: invoice.Id,
+		}); err != nil {
+			helper.Logger.Printf("Error queueing a payment reminder for invoice %s: %v", invoice.Id, err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,22 @@
+package lib
+
+import (
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// writeAuditLog records an action taken on a company's data. details is
+// stored as JSON and should hold enough to explain or undo the action.
+func writeAuditLog(app core.App, companyID, userID, action, collection, recordID string, details any) error {
+	entry, err := models.NewProxy[models.AuditLogs](app)
+	if err != nil {
+		return err
+	}
+	entry.Set("company", companyID)
+	entry.Set("user", userID)
+	entry.SetAction(action)
+	entry.SetRecordCollection(collection)
+	entry.SetRecordId(recordID)
+	entry.Set("details", details)
+	return app.Save(entry)
+}
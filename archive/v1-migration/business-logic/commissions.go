@@ -0,0 +1,461 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// commissionRules are a company's active rules, by what they apply to. A
+// sale line earns under the rule for its product, else the rule for one of
+// its product's categories, else the company-wide rule.
+type commissionRules struct {
+	products   map[string]*core.Record
+	categories map[string]*core.Record
+	fallback   *core.Record
+}
+
+func loadCommissionRules(app core.App, companyID string) (*commissionRules, error) {
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.CommissionRules](),
+		"company = {:company} && active = true",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	rules := &commissionRules{products: map[string]*core.Record{}, categories: map[string]*core.Record{}}
+	for _, record := range records {
+		// the oldest rule wins when two apply to the same thing
+		switch {
+		case record.GetString("product") != "":
+			if rules.products[record.GetString("product")] == nil {
+				rules.products[record.GetString("product")] = record
+			}
+		case record.GetString("category") != "":
+			if rules.categories[record.GetString("category")] == nil {
+				rules.categories[record.GetString("category")] = record
+			}
+		case rules.fallback == nil:
+			rules.fallback = record
+		}
+	}
+	return rules, nil
+}
+
+func (r *commissionRules) empty() bool {
+	return len(r.products) == 0 && len(r.categories) == 0 && r.fallback == nil
+}
+
+func (r *commissionRules) ruleFor(product *core.Record) *core.Record {
+	if rule := r.products[product.Id]; rule != nil {
+		return rule
+	}
+	for _, category := range product.GetStringSlice("category") {
+		if rule := r.categories[category]; rule != nil {
+			return rule
+		}
+	}
+	return r.fallback
+}
+
+// CommissionLine is what one rule earned a salesperson over the period.
+// Returned items count negative.
+type CommissionLine struct {
+	RuleID     string  `json:"rule_id"`
+	Rule       string  `json:"rule"`
+	Type       string  `json:"type"`
+	Rate       float64 `json:"rate"`
+	Units      float64 `json:"units"`
+	Revenue    float64 `json:"revenue"`
+	Commission float64 `json:"commission"`
+}
+
+// CommissionStatement is the commission a salesperson earned over a period,
+// in the base currency. ID and Status are set once the statement is issued.
+type CommissionStatement struct {
+	ID            string           `json:"id,omitempty"`
+	SalespersonID string           `json:"salesperson_id"`
+	Name          string           `json:"name"`
+	From          time.Time        `json:"from"`
+	To            time.Time        `json:"to"`
+	Sales         int              `json:"sales"`
+	Revenue       float64          `json:"revenue"`
+	Commission    float64          `json:"commission"`
+	Status        string           `json:"status,omitempty"`
+	PaidAt        *time.Time       `json:"paid_at,omitempty"`
+	Lines         []CommissionLine `json:"lines"`
+}
+
+// computeCommissions works out the commission each salesperson earned on the
+// sale lines dated in the range.
+func computeCommissions(app core.App, companyID string, days DateRange) (map[string]*CommissionStatement, error) {
+	statements := map[string]*CommissionStatement{}
+	rules, err := loadCommissionRules(app, companyID)
+	if err != nil || rules.empty() {
+		return statements, err
+	}
+
+	params := days.Params()
+	params["company"] = companyID
+	sales, err := app.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && salesperson != '' && transaction_date >= {:from} && transaction_date < {:to}",
+		"transaction_date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := app.ExpandRecords(sales, []string{"sales_details", "sales_details.product"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load sale lines: %v", errs)
+	}
+
+	lines := map[string]map[string]*CommissionLine{}
+	for _, sale := range sales {
+		userID := sale.GetString("salesperson")
+		statement := statements[userID]
+		if statement == nil {
+			statement = &CommissionStatement{SalespersonID: userID, From: days.From, To: days.To}
+			statements[userID] = statement
+			lines[userID] = map[string]*CommissionLine{}
+		}
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		} else {
+			statement.Sales++
+		}
+		rate := sale.GetFloat("exchange_rate")
+		if rate <= 0 {
+			rate = 1
+		}
+		for _, detail := range sale.ExpandedAll("sales_details") {
+			product := detail.ExpandedOne("product")
+			if product == nil {
+				continue
+			}
+			rule := rules.ruleFor(product)
+			if rule == nil {
+				continue
+			}
+			line := lines[userID][rule.Id]
+			if line == nil {
+				line = &CommissionLine{
+					RuleID: rule.Id,
+					Rule:   rule.GetString("name"),
+					Type:   rule.GetString("type"),
+					Rate:   rule.GetFloat("rate"),
+				}
+				lines[userID][rule.Id] = line
+			}
+			units := sign * math.Abs(detail.GetFloat("quantity"))
+			revenue := units * detail.GetFloat("unit_price") * rate
+			line.Units += units
+			line.Revenue += revenue
+			if line.Type == "flat" {
+				line.Commission += units * line.Rate
+			} else {
+				line.Commission += revenue * line.Rate / 100
+			}
+		}
+	}
+
+	for userID, statement := range statements {
+		statement.Lines = make([]CommissionLine, 0, len(lines[userID]))
+		for _, line := range lines[userID] {
+			line.Units = roundMoney(line.Units)
+			line.Revenue = roundMoney(line.Revenue)
+			line.Commission = roundMoney(line.Commission)
+			statement.Revenue += line.Revenue
+			statement.Commission += line.Commission
+			statement.Lines = append(statement.Lines, *line)
+		}
+		sort.Slice(statement.Lines, func(i, j int) bool {
+			return statement.Lines[i].Commission > statement.Lines[j].Commission
+		})
+		statement.Revenue = roundMoney(statement.Revenue)
+		statement.Commission = roundMoney(statement.Commission)
+	}
+	return statements, nil
+}
+
+// CommissionStatements works out, without issuing them, the statements of
+// every salesperson who earned commission on the days of the range.
+func (helper *DbHelper) CommissionStatements(companyID string, days DateRange) ([]CommissionStatement, error) {
+	computed, err := computeCommissions(helper.pb, companyID, days)
+	if err != nil {
+		return nil, err
+	}
+	return helper.commissionStatementList(computed)
+}
+
+func (helper *DbHelper) commissionStatementList(computed map[string]*CommissionStatement) ([]CommissionStatement, error) {
+	ids := make([]string, 0, len(computed))
+	for id := range computed {
+		ids = append(ids, id)
+	}
+	users, err := helper.pb.FindRecordsByIds(models.CName[models.Users](), ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		computed[user.Id].Name = userDisplayName(user)
+	}
+	statements := make([]CommissionStatement, 0, len(computed))
+	for _, statement := range computed {
+		statements = append(statements, *statement)
+	}
+	sort.Slice(statements, func(i, j int) bool {
+		return statements[i].Name < statements[j].Name
+	})
+	return statements, nil
+}
+
+// IssueCommissionStatements saves the statements of the range as payable.
+// Only managers may issue statements, and a salesperson's statements may not
+// cover the same day twice.
+func (helper *DbHelper) IssueCommissionStatements(companyID, userID string, days DateRange) ([]CommissionStatement, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	var statements []CommissionStatement
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		computed, err := computeCommissions(txApp, companyID, days)
+		if err != nil {
+			return err
+		}
+		for salespersonID, statement := range computed {
+			if statement.Commission == 0 {
+				delete(computed, salespersonID)
+				continue
+			}
+			overlapping, err := txApp.CountRecords(
+				models.CName[models.CommissionStatements](),
+				dbx.NewExp("company = {:company} AND salesperson = {:salesperson} AND period_start < {:to} AND period_end >= {:from}",
+					dbx.Params{"company": companyID, "salesperson": salespersonID, "from": formatDate(days.From), "to": formatDate(days.To)}),
+			)
+			if err != nil {
+				return err
+			}
+			if overlapping > 0 {
+				return fmt.Errorf("salesperson %s already has a statement covering part of %s - %s", salespersonID,
+					days.From.Format(time.DateOnly), days.To.AddDate(0, 0, -1).Format(time.DateOnly))
+			}
+
+			record, err := models.NewProxy[models.CommissionStatements](txApp)
+			if err != nil {
+				return err
+			}
+			record.Set("company", companyID)
+			record.Set("salesperson", salespersonID)
+			record.Set("period_start", days.From)
+			record.Set("period_end", days.To.AddDate(0, 0, -1))
+			record.Set("sales", statement.Sales)
+			record.Set("revenue", statement.Revenue)
+			record.Set("commission", statement.Commission)
+			record.Set("lines", statement.Lines)
+			record.SetStatus(models.CommissionPayable)
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+			statement.ID = record.Id
+			statement.Status = record.GetString("status")
+		}
+		statements, err = helper.commissionStatementList(computed)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// PayCommissionStatement marks an issued statement as paid. Only managers
+// may pay commission.
+func (helper *DbHelper) PayCommissionStatement(companyID, userID, statementID string) (*CommissionStatement, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	record, err := helper.pb.FindRecordById(models.CName[models.CommissionStatements](), statementID)
+	if err != nil || record.GetString("company") != companyID {
+		return nil, fmt.Errorf("commission statement %s not found", statementID)
+	}
+	statement, err := models.WrapRecord[models.CommissionStatements](record)
+	if err != nil {
+		return nil, err
+	}
+	if statement.Status() == models.CommissionPaid {
+		return nil, fmt.Errorf("commission statement %s is already paid", statementID)
+	}
+	statement.SetStatus(models.CommissionPaid)
+	record.Set("paid_at", types.NowDateTime())
+	record.Set("paid_by", userID)
+	if err := helper.pb.Save(record); err != nil {
+		return nil, err
+	}
+	helper.pb.ExpandRecord(record, []string{"salesperson"}, nil)
+	return commissionStatementFromRecord(record), nil
+}
+
+// FetchCommissionStatements returns the company's issued statements, newest
+// period first, optionally only those with the given status.
+func (helper *DbHelper) FetchCommissionStatements(companyID, status string) ([]CommissionStatement, error) {
+	filter := "company = {:company}"
+	if status != "" {
+		filter += " && status = {:status}"
+	}
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.CommissionStatements](),
+		filter,
+		"-period_start",
+		0,
+		0,
+		dbx.Params{"company": companyID, "status": status},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(records, []string{"salesperson"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load salespeople: %v", errs)
+	}
+	statements := make([]CommissionStatement, len(records))
+	for i, record := range records {
+		statements[i] = *commissionStatementFromRecord(record)
+	}
+	return statements, nil
+}
+
+func commissionStatementFromRecord(record *core.Record) *CommissionStatement {
+	statement := &CommissionStatement{
+		ID:            record.Id,
+		SalespersonID: record.GetString("salesperson"),
+		From:          record.GetDateTime("period_start").Time(),
+		To:            record.GetDateTime("period_end").Time().AddDate(0, 0, 1),
+		Sales:         record.GetInt("sales"),
+		Revenue:       record.GetFloat("revenue"),
+		Commission:    record.GetFloat("commission"),
+		Status:        record.GetString("status"),
+		Lines:         []CommissionLine{},
+	}
+	if user := record.ExpandedOne("salesperson"); user != nil {
+		statement.Name = userDisplayName(user)
+	}
+	if paid := record.GetDateTime("paid_at"); !paid.IsZero() {
+		t := paid.Time()
+		statement.PaidAt = &t
+	}
+	_ = json.Unmarshal([]byte(record.GetString("lines")), &statement.Lines)
+	return statement
+}
+
+// CommissionRuleSettings describe a commission rule. A percentage rule pays
+// Rate percent of the line revenue; a flat rule pays Rate per item sold. A
+// rule names a product, a category or neither, applying company-wide.
+type CommissionRuleSettings struct {
+	Name     string  `json:"name"`
+	Product  string  `json:"product"`
+	Category string  `json:"category"`
+	Type     string  `json:"type"`
+	Rate     float64 `json:"rate"`
+	Active   bool    `json:"active"`
+}
+
+// SaveCommissionRule creates a commission rule, or changes it when ruleID is
+// set. Only managers may change the rules.
+func (helper *DbHelper) SaveCommissionRule(companyID, userID, ruleID string, settings CommissionRuleSettings) (*models.CommissionRules, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	if settings.Type != "percentage" && settings.Type != "flat" {
+		return nil, fmt.Errorf("unknown commission type %q, expected percentage or flat", settings.Type)
+	}
+	if settings.Rate < 0 || (settings.Type == "percentage" && settings.Rate > 100) {
+		return nil, fmt.Errorf("invalid commission rate %v", settings.Rate)
+	}
+	if settings.Product != "" && settings.Category != "" {
+		return nil, fmt.Errorf("a commission rule applies to a product or a category, not both")
+	}
+	if settings.Product != "" {
+		product, err := helper.pb.FindRecordById(models.CName[models.Products](), settings.Product)
+		if err != nil || product.GetString("company") != companyID {
+			return nil, fmt.Errorf("product %s not found", settings.Product)
+		}
+	}
+
+	var record *core.Record
+	if ruleID != "" {
+		existing, err := helper.pb.FindRecordById(models.CName[models.CommissionRules](), ruleID)
+		if err != nil || existing.GetString("company") != companyID {
+			return nil, fmt.Errorf("commission rule %s not found", ruleID)
+		}
+		record = existing
+	} else {
+		proxy, err := models.NewProxy[models.CommissionRules](helper.pb)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Set("company", companyID)
+		record = proxy.Record
+	}
+	record.Set("name", strings.TrimSpace(settings.Name))
+	record.Set("product", settings.Product)
+	record.Set("category", settings.Category)
+	record.Set("type", settings.Type)
+	record.Set("rate", settings.Rate)
+	record.Set("active", settings.Active)
+	if err := helper.pb.Save(record); err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.CommissionRules](record)
+}
+
+// DeleteCommissionRule removes a commission rule. Statements already issued
+// keep the commission they were issued with.
+func (helper *DbHelper) DeleteCommissionRule(companyID, userID, ruleID string) error {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return err
+	}
+	record, err := helper.pb.FindRecordById(models.CName[models.CommissionRules](), ruleID)
+	if err != nil || record.GetString("company") != companyID {
+		return fmt.Errorf("commission rule %s not found", ruleID)
+	}
+	return helper.pb.Delete(record)
+}
+
+// FetchCommissionRules returns all of the company's commission rules.
+func (helper *DbHelper) FetchCommissionRules(companyID string) ([]*models.CommissionRules, error) {
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.CommissionRules](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*models.CommissionRules, len(records))
+	for i, record := range records {
+		rule, err := models.WrapRecord[models.CommissionRules](record)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+	}
+	return rules, nil
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+)
+
+// companyStatsTTL is how long the home page figures are served from memory
+// before they are worked out again.
+const companyStatsTTL = time.Minute
+
+// topProductsOnHome is the number of best sellers shown on the home page.
+const topProductsOnHome = 5
+
+// CompanyStats returns the company's home page figures, worked out at most
+// once a minute.
+func (helper *DbHelper) CompanyStats(companyID string) (*models.CompanyStats, error) {
+	if stats, ok := helper.stats.GetOk(companyID); ok && time.Since(stats.GeneratedAt) < companyStatsTTL {
+		return stats, nil
+	}
+	stats, err := helper.computeCompanyStats(companyID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	helper.stats.Set(companyID, stats)
+	return stats, nil
+}
+
+func (helper *DbHelper) computeCompanyStats(companyID string, now time.Time) (*models.CompanyStats, error) {
+	company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return nil, fmt.Errorf("company %s not found: %w", companyID, err)
+	}
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.CompanyStats{
+		CompanyID:   companyID,
+		Currency:    currency,
+		TopProducts: []models.ProductStat{},
+		Accounts:    []models.AccountBalance{},
+		GeneratedAt: now,
+	}
+
+	// today and this week start at the company's local midnight
+	now = now.In(companyLocation(company))
+	today := startOfDay(now)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+
+	day, err := computeSummary(helper.pb, companyID, DateRange{From: today, To: today.AddDate(0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	stats.TodaySales = day.TotalSales
+	stats.TodayPurchases = day.TotalPurchases
+	stats.TodayExpenses = day.TotalExpenses
+
+	week, err := computeSummary(helper.pb, companyID, DateRange{From: monday, To: now})
+	if err != nil {
+		return nil, err
+	}
+	lastWeek, err := computeSummary(helper.pb, companyID, DateRange{From: monday.AddDate(0, 0, -7), To: now.AddDate(0, 0, -7)})
+	if err != nil {
+		return nil, err
+	}
+	stats.WeekToDate = week.TotalSales
+	stats.LastWeekToDate = lastWeek.TotalSales
+	stats.WeekChange = percentChange(week.TotalSales, lastWeek.TotalSales)
+	for i, p := range week.TopProducts {
+		if i == topProductsOnHome {
+			break
+		}
+		stats.TopProducts = append(stats.TopProducts, models.ProductStat{
+			ProductID: p.Product,
+			Name:      p.Name,
+			Quantity:  p.Quantity,
+			Revenue:   p.Revenue,
+		})
+	}
+
+	lowStock, err := findLowStock(helper.pb, companyID)
+	if err != nil {
+		return nil, err
+	}
+	stats.LowStock = len(lowStock)
+
+	aging, err := helper.AgingReport(companyID, stats.GeneratedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+	stats.OpenReceivables = aging.Receivable.Total
+
+	accounts, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.CompanyAccounts](),
+		"company = {:company}",
+		"name",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		balance := models.AccountBalance{
+			AccountID: account.Id,
+			Name:      account.GetString("name"),
+			Currency:  account.GetString("currency"),
+			Balance:   account.GetFloat("bal"),
+		}
+		if balance.Currency == "" {
+			balance.Currency = currency
+		}
+		stats.Accounts = append(stats.Accounts, balance)
+
+		rate, err := FindExchangeRate(helper.pb, companyID, balance.Currency, now)
+		if err != nil {
+			helper.Logger.Printf("Leaving account %s out of the cash balance: %v", account.Id, err)
+			continue
+		}
+		stats.CashBalance += balance.Balance * rate
+	}
+	stats.CashBalance = roundMoney(stats.CashBalance)
+	return stats, nil
+}
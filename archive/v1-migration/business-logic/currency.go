@@ -0,0 +1,274 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DefaultBaseCurrency is used for companies that have not set a base
+// currency of their own.
+const DefaultBaseCurrency = "KES"
+
+// currencyDateFields maps every collection carrying a document currency to
+// the field holding the date its exchange rate is taken from.
+var currencyDateFields = map[string]string{
+	"sales_transactions": "transaction_date",
+	"purchases":          "date",
+	"invoices":           "date",
+	"transactions":       "date",
+}
+
+// BindCurrencyStamps registers the record hooks that stamp the currency and
+// exchange rate on documents and keep transaction amounts in the company's
+// base currency.
+//
+// A transaction in a foreign currency keeps the entered figures in
+// original_amount and original_tax_amount and their base-currency
+// equivalents in amount and tax_amount, so every
+// report summing amount stays in the base currency. Invoices additionally
+// get their realised exchange gain or loss recomputed from their payments.
+func (helper *DbHelper) BindCurrencyStamps() {
+	collections := make([]string, 0, len(currencyDateFields))
+	for name := range currencyDateFields {
+		collections = append(collections, name)
+	}
+
+	helper.pb.OnRecordCreate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := stampCurrency(e.App, e.Record, true); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	helper.pb.OnRecordUpdate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := stampCurrency(e.App, e.Record, false); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+}
+
+func stampCurrency(app core.App, record *core.Record, isNew bool) error {
+	companyID := record.GetString("company")
+	if companyID == "" {
+		return nil
+	}
+	base, err := companyBaseCurrency(app, companyID)
+	if err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(record.GetString("currency")))
+	if currency == "" && record.Collection().Name == "transactions" {
+		// amounts booked against an account are in the account's currency
+		if accountID := record.GetString("account"); accountID != "" {
+			if account, err := app.FindRecordById(models.CName[models.CompanyAccounts](), accountID); err == nil {
+				currency = account.GetString("currency")
+			}
+		}
+	}
+	if currency == "" {
+		currency = base
+	}
+	record.Set("currency", currency)
+
+	rate := record.GetFloat("exchange_rate")
+	if currency == base {
+		rate = 1
+	} else if rate <= 0 {
+		date := record.GetDateTime(currencyDateFields[record.Collection().Name])
+		if date.IsZero() {
+			date = types.NowDateTime()
+		}
+		rate, err = FindExchangeRate(app, companyID, currency, date.Time())
+		if err != nil {
+			return err
+		}
+	}
+	record.Set("exchange_rate", rate)
+
+	switch record.Collection().Name {
+	case "transactions":
+		switch {
+		case currency == base:
+			record.Set("original_amount", record.GetFloat("amount"))
+			record.Set("original_tax_amount", record.GetFloat("tax_amount"))
+		case isNew && record.GetFloat("original_amount") == 0:
+			// the caller entered the amounts in the transaction currency
+			record.Set("original_amount", record.GetFloat("amount"))
+			record.Set("original_tax_amount", record.GetFloat("tax_amount"))
+			record.Set("amount", roundMoney(record.GetFloat("amount")*rate))
+			record.Set("tax_amount", roundMoney(record.GetFloat("tax_amount")*rate))
+		default:
+			// an edit to a base-currency figure is kept as entered and
+			// converted back into the transaction currency; the others
+			// follow the transaction currency figures at the current rate
+			original := record.Original()
+			for _, f := range [][2]string{{"amount", "original_amount"}, {"tax_amount", "original_tax_amount"}} {
+				converted, entered := f[0], f[1]
+				if !isNew && record.GetFloat(converted) != original.GetFloat(converted) && record.GetFloat(entered) == original.GetFloat(entered) {
+					record.Set(entered, roundMoney(record.GetFloat(converted)/rate))
+					continue
+				}
+				if entered == "original_tax_amount" && record.GetFloat(entered) == 0 && record.GetFloat(converted) != 0 {
+					// saved before the original tax was kept: take it back
+					// out of the base-currency tax at the rate it was booked at
+					if bookedRate := original.GetFloat("exchange_rate"); bookedRate > 0 {
+						record.Set(entered, roundMoney(original.GetFloat(converted)/bookedRate))
+					}
+				}
+				record.Set(converted, roundMoney(record.GetFloat(entered)*rate))
+			}
+		}
+	case "invoices":
+		fx, err := realisedFX(app, record)
+		if err != nil {
+			return err
+		}
+		record.Set("realised_fx", fx)
+	}
+	return nil
+}
+
+// realisedFX is the exchange gain (positive) or loss (negative) realised by
+// settling a foreign-currency invoice at rates other than the invoice rate.
+// Receiving more base currency than booked on a sale is a gain; paying more
+// than booked on a purchase is a loss.
+func realisedFX(app core.App, invoice *core.Record) (float64, error) {
+	ids := invoice.GetStringSlice("transactions")
+	invoiceRate := invoice.GetFloat("exchange_rate")
+	if len(ids) == 0 || invoiceRate == 1 {
+		return 0, nil
+	}
+	payments, err := app.FindRecordsByIds(models.CName[models.Transactions](), ids)
+	if err != nil {
+		return 0, err
+	}
+	var fx float64
+	for _, payment := range payments {
+		if payment.GetString("currency") != invoice.GetString("currency") {
+			continue
+		}
+		fx += payment.GetFloat("original_amount") * (payment.GetFloat("exchange_rate") - invoiceRate)
+	}
+	if invoice.GetString("type") == "purchase" {
+		fx = -fx
+	}
+	return roundMoney(fx), nil
+}
+
+func companyBaseCurrency(app core.App, companyID string) (string, error) {
+	company, err := app.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return "", fmt.Errorf("company %s not found: %w", companyID, err)
+	}
+	if base := company.GetString("base_currency"); base != "" {
+		return base, nil
+	}
+	return DefaultBaseCurrency, nil
+}
+
+// BaseCurrency returns the currency the company reports in.
+func (helper *DbHelper) BaseCurrency(companyID string) (string, error) {
+	return companyBaseCurrency(helper.pb, companyID)
+}
+
+// FindExchangeRate returns how many units of the company's base currency one
+// unit of currency was worth on date, using the latest rate effective on or
+// before it.
+func FindExchangeRate(app core.App, companyID, currency string, date time.Time) (float64, error) {
+	base, err := companyBaseCurrency(app, companyID)
+	if err != nil {
+		return 0, err
+	}
+	if currency == "" || currency == base {
+		return 1, nil
+	}
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.ExchangeRates](),
+		"company = {:company} && currency = {:currency} && effective_date <= {:date}",
+		"-effective_date",
+		1,
+		0,
+		dbx.Params{"company": companyID, "currency": currency, "date": formatDate(date)},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 || records[0].GetFloat("rate") <= 0 {
+		return 0, fmt.Errorf("no %s exchange rate effective on %s", currency, date.Format(time.DateOnly))
+	}
+	return records[0].GetFloat("rate"), nil
+}
+
+// ConvertToBase converts an amount in currency to the company's base
+// currency at the rate effective on date, returning the rate used.
+func (helper *DbHelper) ConvertToBase(companyID, currency string, amount float64, date time.Time) (float64, float64, error) {
+	rate, err := FindExchangeRate(helper.pb, companyID, currency, date)
+	if err != nil {
+		return 0, 0, err
+	}
+	return roundMoney(amount * rate), rate, nil
+}
+
+// BaseAmount returns a document amount field converted to the base currency
+// at the document's stamped exchange rate. Documents saved before currencies
+// were introduced have no rate and are already in the base currency.
+func BaseAmount(record *core.Record, field string) float64 {
+	rate := record.GetFloat("exchange_rate")
+	if rate <= 0 {
+		rate = 1
+	}
+	return roundMoney(record.GetFloat(field) * rate)
+}
+
+// FXGain is the realised exchange gain or loss on one invoice.
+type FXGain struct {
+	InvoiceID    string    `json:"invoice_id"`
+	Date         time.Time `json:"date"`
+	Type         string    `json:"type"`
+	Currency     string    `json:"currency"`
+	Amount       float64   `json:"amount"`
+	InvoiceRate  float64   `json:"invoice_rate"`
+	RealisedGain float64   `json:"realised_gain"`
+}
+
+// FXGainsReport lists the realised exchange gains and losses, in the base
+// currency, on the company's invoices dated in the range.
+func (helper *DbHelper) FXGainsReport(companyID string, r DateRange) ([]FXGain, float64, error) {
+	params := r.Params()
+	params["company"] = companyID
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"company = {:company} && date >= {:from} && date < {:to} && realised_fx != 0",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	gains := make([]FXGain, 0, len(records))
+	var total float64
+	for _, record := range records {
+		gains = append(gains, FXGain{
+			InvoiceID:    record.Id,
+			Date:         record.GetDateTime("date").Time(),
+			Type:         record.GetString("type"),
+			Currency:     record.GetString("currency"),
+			Amount:       record.GetFloat("amount"),
+			InvoiceRate:  record.GetFloat("exchange_rate"),
+			RealisedGain: record.GetFloat("realised_fx"),
+		})
+		total += record.GetFloat("realised_fx")
+	}
+	return gains, roundMoney(total), nil
+}
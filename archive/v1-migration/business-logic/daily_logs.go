@@ -0,0 +1,408 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DailyLog is one of the monthly spreadsheets the bookkeepers kept before
+// Dukahub, which can be exported in their original layout.
+type DailyLog string
+
+const (
+	DailyLogFinancial DailyLog = "financial"
+	DailyLogInventory DailyLog = "inventory"
+)
+
+// The header rows of the logs, as the bookkeepers' spreadsheets have them.
+var (
+	financialLogHeader = []string{"Date", "Opening Balance Cash", "Sales Cash", "Closing Balance Cash", "Opening Balance M-Pesa", "Sales M-Pesa", "Closing Balance M-Pesa"}
+	inventoryLogHeader = []string{"Date", "Product", "Opening Stock", "Quantity Received", "Quantity Sold Retail", "Quantity Sold Wholesale", "Closing Stock (Manual)"}
+)
+
+// FileName returns the download name of the log for the month, as the
+// bookkeepers named their spreadsheets.
+func (l DailyLog) FileName(month time.Time) string {
+	name := "Daily_Financial_Log"
+	if l == DailyLogInventory {
+		name = "Daily_Inventory_and_Sales_Log"
+	}
+	return fmt.Sprintf("%s_%s.xlsx", name, month.Format("January_2006"))
+}
+
+// ParseMonth reads a YYYY-MM month, defaulting to the month of now when
+// empty, and returns its first day.
+func ParseMonth(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	month, err := time.Parse("2006-01", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", v)
+	}
+	return month, nil
+}
+
+// ExportDailyLog renders the company's log of the month, which starts on
+// month, as an .xlsx workbook.
+func (helper *DbHelper) ExportDailyLog(companyID string, log DailyLog, month time.Time) (*bytes.Buffer, error) {
+	days := DateRange{From: month, To: month.AddDate(0, 1, 0)}
+	sheet := xlsxSheet{Name: month.Format("January 2006")}
+
+	var err error
+	switch log {
+	case DailyLogFinancial:
+		sheet.Header = financialLogHeader
+		sheet.Rows, err = helper.financialLogRows(companyID, days)
+	case DailyLogInventory:
+		sheet.Header = inventoryLogHeader
+		sheet.Rows, err = helper.inventoryLogRows(companyID, days)
+	default:
+		err = fmt.Errorf("unknown daily log %q, expected financial or inventory", log)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeXLSX(buf, sheet, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// dayIndex returns the zero-based day of the range that t falls on.
+func dayIndex(days DateRange, t time.Time) int {
+	return int(t.UTC().Sub(days.From).Hours() / 24)
+}
+
+// balances sums opening and closing balances per day, remembering which
+// days had any so that the others are left blank rather than shown as 0.
+type balances struct {
+	opening, closing []float64
+	counted          []bool
+}
+
+func newBalances(n int) *balances {
+	return &balances{opening: make([]float64, n), closing: make([]float64, n), counted: make([]bool, n)}
+}
+
+func (b *balances) add(day int, opening, closing float64) {
+	b.opening[day] += opening
+	b.closing[day] += closing
+	b.counted[day] = true
+}
+
+func (b *balances) cells(day int) (any, any) {
+	if !b.counted[day] {
+		return nil, nil
+	}
+	return roundMoney(b.opening[day]), roundMoney(b.closing[day])
+}
+
+// Legacy log columns the company accounts are reported under.
+const (
+	logAccountCash  = "cash"
+	logAccountMpesa = "mpesa"
+)
+
+// logAccountColumn returns which columns of the financial log an account's
+// balances go under, going by its type, then its name. Accounts that are
+// neither cash nor M-Pesa are left out.
+func logAccountColumn(account *core.Record) string {
+	names := []string{account.GetString("name")}
+	if accountType := account.ExpandedOne("type"); accountType != nil {
+		names = append([]string{accountType.GetString("name")}, names...)
+	}
+	for _, name := range names {
+		name = strings.NewReplacer("-", "", " ", "", "_", "").Replace(strings.ToLower(name))
+		switch {
+		case strings.Contains(name, "mpesa"), strings.Contains(name, "mobile"):
+			return logAccountMpesa
+		case strings.Contains(name, "cash"):
+			return logAccountCash
+		}
+	}
+	return ""
+}
+
+// financialLogRows returns a row per day of the cash and M-Pesa balances
+// from the daily account counts, and the sales paid in cash and by mobile
+// money, net of returns.
+func (helper *DbHelper) financialLogRows(companyID string, days DateRange) ([][]any, error) {
+	params := days.Params()
+	params["company"] = companyID
+	n := days.Days()
+
+	accounts, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.CompanyAccounts](),
+		"company = {:company}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(accounts, []string{"type"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load account types: %v", errs)
+	}
+	columns := map[string]string{}
+	for _, account := range accounts {
+		columns[account.Id] = logAccountColumn(account)
+	}
+
+	counts, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.DailyAccounts](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	bals := map[string]*balances{logAccountCash: newBalances(n), logAccountMpesa: newBalances(n)}
+	for _, count := range counts {
+		b := bals[columns[count.GetString("account")]]
+		if b == nil {
+			continue
+		}
+		b.add(dayIndex(days, count.GetDateTime("date").Time()), count.GetFloat("opening_bal"), count.GetFloat("closing_bal"))
+	}
+
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to} && (payment_method = 'cash' || payment_method = 'mobile_money')",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	takings := map[string][]float64{logAccountCash: make([]float64, n), logAccountMpesa: make([]float64, n)}
+	for _, sale := range sales {
+		column := logAccountCash
+		if sale.GetString("payment_method") == "mobile_money" {
+			column = logAccountMpesa
+		}
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		}
+		takings[column][dayIndex(days, sale.GetDateTime("transaction_date").Time())] += sign * math.Abs(BaseAmount(sale, "total_amount"))
+	}
+
+	rows := make([][]any, 0, n)
+	for day := 0; day < n; day++ {
+		cashOpening, cashClosing := bals[logAccountCash].cells(day)
+		mpesaOpening, mpesaClosing := bals[logAccountMpesa].cells(day)
+		rows = append(rows, []any{
+			days.From.AddDate(0, 0, day),
+			cashOpening,
+			roundMoney(takings[logAccountCash][day]),
+			cashClosing,
+			mpesaOpening,
+			roundMoney(takings[logAccountMpesa][day]),
+			mpesaClosing,
+		})
+	}
+	return rows, nil
+}
+
+// inventoryLogRows returns a row per day per product, in the order the
+// products were added, of the stock counted, received and sold. Sale lines
+// priced below the inventory retail price count as wholesale; returns are
+// taken off what was sold. Imported legacy sales keep their columns.
+func (helper *DbHelper) inventoryLogRows(companyID string, days DateRange) ([][]any, error) {
+	params := days.Params()
+	params["company"] = companyID
+	n := days.Days()
+
+	products, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Products](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type productDays struct {
+		stock             *balances
+		received          []float64
+		retail, wholesale []float64
+	}
+	figures := map[string]*productDays{}
+	for _, product := range products {
+		figures[product.Id] = &productDays{
+			stock:     newBalances(n),
+			received:  make([]float64, n),
+			retail:    make([]float64, n),
+			wholesale: make([]float64, n),
+		}
+	}
+
+	takes, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.DailyStockTakes](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, take := range takes {
+		if f := figures[take.GetString("product")]; f != nil {
+			f.stock.add(dayIndex(days, take.GetDateTime("date").Time()), take.GetFloat("opening_bal"), take.GetFloat("closing_bal"))
+		}
+	}
+
+	purchases, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Purchases](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, purchase := range purchases {
+		if f := figures[purchase.GetString("product")]; f != nil {
+			f.received[dayIndex(days, purchase.GetDateTime("date").Time())] += purchase.GetFloat("quantity")
+		}
+	}
+
+	prices, err := retailPrices(helper.pb, companyID)
+	if err != nil {
+		return nil, err
+	}
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(sales, []string{"sales_details"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load sale lines: %v", errs)
+	}
+	for _, sale := range sales {
+		day := dayIndex(days, sale.GetDateTime("transaction_date").Time())
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		}
+		rate := sale.GetFloat("exchange_rate")
+		if rate <= 0 {
+			rate = 1
+		}
+		for _, line := range sale.ExpandedAll("sales_details") {
+			productID := line.GetString("product")
+			f := figures[productID]
+			if f == nil {
+				continue
+			}
+			units := sign * math.Abs(line.GetFloat("quantity"))
+			retail, ok := prices[productID][line.GetString("sku")]
+			if !ok {
+				retail = prices[productID][""]
+			}
+			if retail > 0 && line.GetFloat("unit_price")*rate < retail {
+				f.wholesale[day] += units
+			} else {
+				f.retail[day] += units
+			}
+		}
+	}
+
+	// sales imported from the legacy log only exist as stock movements
+	imported, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.InventoryTransactions](),
+		"company = {:company} && reference_type = {:reference} && (reason_code = 'sale' || reason_code = 'return') && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID, "reference": legacyImportReference, "from": params["from"], "to": params["to"]},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, movement := range imported {
+		f := figures[movement.GetString("product")]
+		if f == nil {
+			continue
+		}
+		day := dayIndex(days, movement.GetDateTime("transaction_date").Time())
+		if movement.GetString("reference_id") == legacyWholesale {
+			f.wholesale[day] -= movement.GetFloat("quantity_change")
+		} else {
+			f.retail[day] -= movement.GetFloat("quantity_change")
+		}
+	}
+
+	rows := make([][]any, 0, n*len(products))
+	for day := 0; day < n; day++ {
+		date := days.From.AddDate(0, 0, day)
+		for _, product := range products {
+			f := figures[product.Id]
+			opening, closing := f.stock.cells(day)
+			rows = append(rows, []any{
+				date,
+				product.GetString("name"),
+				opening,
+				f.received[day],
+				f.retail[day],
+				f.wholesale[day],
+				closing,
+			})
+		}
+	}
+	return rows, nil
+}
+
+// retailPrices returns the inventory retail price of each product and SKU of
+// the company. The "" SKU holds a product's price whatever the SKU.
+func retailPrices(app core.App, companyID string) (map[string]map[string]float64, error) {
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.Inventory](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	prices := map[string]map[string]float64{}
+	for _, record := range records {
+		product := record.GetString("product")
+		if prices[product] == nil {
+			prices[product] = map[string]float64{"": record.GetFloat("retail_price")}
+		}
+		prices[product][record.GetString("sku")] = record.GetFloat("retail_price")
+	}
+	return prices, nil
+}
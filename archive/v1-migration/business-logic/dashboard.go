@@ -15,7 +15,7 @@ func (helper *DbHelper) FetchDashboardData(userID string, companyID string) (*mo
 		// Fetch company stats
 		return &models.DashboardData{
 			User:          user,
-			CompanyStats:  []models.CompanyStats{},
+			CompanyStats:  helper.companyStatsList(user.Company()[0].Id),
 			Activecompany: user.Company()[0],
 		}, nil
 	}
@@ -33,7 +33,7 @@ func (helper *DbHelper) FetchDashboardData(userID string, companyID string) (*mo
 		if company.Id == companyID {
 			return &models.DashboardData{
 				User:          user,
-				CompanyStats:  []models.CompanyStats{},
+				CompanyStats:  helper.companyStatsList(companyID),
 				Activecompany: company,
 				Model:         model,
 			}, nil
@@ -41,3 +41,14 @@ func (helper *DbHelper) FetchDashboardData(userID string, companyID string) (*mo
 	}
 	return nil, fmt.Errorf("Company not found")
 }
+
+// companyStatsList returns the active company's figures; a failure leaves
+// the home page without numbers rather than failing the page.
+func (helper *DbHelper) companyStatsList(companyID string) []models.CompanyStats {
+	stats, err := helper.CompanyStats(companyID)
+	if err != nil {
+		helper.Logger.Println("Error fetching company stats: ", err)
+		return []models.CompanyStats{}
+	}
+	return []models.CompanyStats{*stats}
+}
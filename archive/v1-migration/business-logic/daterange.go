@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DateRange is a half-open [From, To) range of whole days.
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// NewDateRange builds a range covering the days from..to, both inclusive.
+func NewDateRange(from, to time.Time) DateRange {
+	return DateRange{From: startOfDay(from), To: startOfDay(to).AddDate(0, 0, 1)}
+}
+
+// ParseDateRange reads the optional "from" and "to" (YYYY-MM-DD, inclusive)
+// query parameters. Without them the range covers the last defaultDays days.
+func ParseDateRange(c *core.RequestEvent, defaultDays int) (DateRange, error) {
+	query := c.Request.URL.Query()
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid to date: %w", err)
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-defaultDays)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid from date: %w", err)
+		}
+		from = t
+	}
+	if to.Before(from) {
+		return DateRange{}, fmt.Errorf("from date is after to date")
+	}
+	return NewDateRange(from, to), nil
+}
+
+// Contains reports whether t falls inside the range.
+func (r DateRange) Contains(t time.Time) bool {
+	return !t.Before(r.From) && t.Before(r.To)
+}
+
+// Days returns the number of whole days covered by the range.
+func (r DateRange) Days() int {
+	return int(r.To.Sub(r.From).Hours() / 24)
+}
+
+// Params returns the range bounds formatted like PocketBase stores dates,
+// for use as {:from} and {:to} in record filters.
+func (r DateRange) Params() dbx.Params {
+	return dbx.Params{"from": formatDate(r.From), "to": formatDate(r.To)}
+}
+
+func formatDate(t time.Time) string {
+	d, _ := types.ParseDateTime(t)
+	return d.String()
+}
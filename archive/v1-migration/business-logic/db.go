@@ -2,20 +2,35 @@ package lib
 
 import (
 	"log"
+	"sync"
 
+	"github.com/kisinga/dukahub/models"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/store"
 )
 
 type DbHelper struct {
 	pb     *pocketbase.PocketBase
 	Logger *log.Logger
+	// MailSender overrides the mailer chosen by Mailer when set.
+	MailSender mailer.Mailer
+	// SMSSender overrides the SMS provider chosen by SMS when set.
+	SMSSender SMSSender
+
+	dispatching sync.Mutex
+	// renderDigest renders digest emails, set by BindDigests.
+	renderDigest DigestRenderer
+	// stats caches the home page figures by company.
+	stats *store.Store[string, *models.CompanyStats]
 }
 
 func NewDbHelper(pb *pocketbase.PocketBase, logger *log.Logger) *DbHelper {
 	return &DbHelper{
 		pb:     pb,
 		Logger: logger,
+		stats:  store.New[string, *models.CompanyStats](nil),
 	}
 }
 
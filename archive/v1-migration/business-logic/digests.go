@@ -0,0 +1,331 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Digests go out once the company's local clock passes digestHour; weekly
+// digests go out on Mondays and cover the week before.
+const digestHour = 7
+
+// digestListLimit caps the low-stock and overdue lists of a digest.
+const digestListLimit = 10
+
+// LowStockItem is an inventory line at or below its reorder point.
+type LowStockItem struct {
+	Product      string  `json:"product"`
+	SKU          string  `json:"sku"`
+	Quantity     float64 `json:"quantity"`
+	ReorderPoint float64 `json:"reorder_point"`
+}
+
+// OverdueReceivable is a sale invoice still owed after its due date.
+type OverdueReceivable struct {
+	InvoiceID string    `json:"invoice_id"`
+	Number    string    `json:"number"`
+	Partner   string    `json:"partner"`
+	DueDate   time.Time `json:"due_date"`
+	DaysLate  int       `json:"days_late"`
+	Balance   float64   `json:"balance"`
+}
+
+// Digest is the business summary emailed to a company's subscribers: the
+// figures of yesterday, or of the last seven days for a weekly digest, and
+// what needs attention now. Amounts are in the base currency.
+type Digest struct {
+	CompanyID     string              `json:"company_id"`
+	CompanyName   string              `json:"company_name"`
+	Currency      string              `json:"currency"`
+	Frequency     string              `json:"frequency"`
+	From          time.Time           `json:"from"`
+	To            time.Time           `json:"to"`
+	Summary       *DaySummary         `json:"summary"`
+	LowStockCount int                 `json:"low_stock_count"`
+	LowStock      []LowStockItem      `json:"low_stock"`
+	OverdueCount  int                 `json:"overdue_count"`
+	OverdueTotal  float64             `json:"overdue_total"`
+	Overdue       []OverdueReceivable `json:"overdue"`
+}
+
+// Title is the digest's email subject.
+func (d *Digest) Title() string {
+	if d.Frequency == "weekly" {
+		return fmt.Sprintf("%s: week of %s", d.CompanyName, d.From.Format("2 Jan 2006"))
+	}
+	return fmt.Sprintf("%s: %s", d.CompanyName, d.From.Format("Mon 2 Jan 2006"))
+}
+
+// DigestRenderer renders a digest as an HTML email body.
+type DigestRenderer func(*Digest) (string, error)
+
+// BuildDigest gathers the company's daily or weekly digest as of now, in the
+// company's timezone.
+func (helper *DbHelper) BuildDigest(companyID, frequency string, now time.Time) (*Digest, error) {
+	if frequency != "daily" && frequency != "weekly" {
+		return nil, fmt.Errorf("unknown digest frequency %q, expected daily or weekly", frequency)
+	}
+	company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return nil, fmt.Errorf("company %s not found: %w", companyID, err)
+	}
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := startOfDay(now.In(companyLocation(company)))
+	digest := &Digest{
+		CompanyID:   companyID,
+		CompanyName: company.GetString("name"),
+		Currency:    currency,
+		Frequency:   frequency,
+		From:        today.AddDate(0, 0, -1),
+		To:          today,
+		LowStock:    []LowStockItem{},
+		Overdue:     []OverdueReceivable{},
+	}
+	if frequency == "weekly" {
+		digest.From = today.AddDate(0, 0, -7)
+	}
+	if digest.Summary, err = computeSummary(helper.pb, companyID, DateRange{From: digest.From, To: digest.To}); err != nil {
+		return nil, err
+	}
+
+	inventory, err := findLowStock(helper.pb, companyID)
+	if err != nil {
+		return nil, err
+	}
+	digest.LowStockCount = len(inventory)
+	if len(inventory) > digestListLimit {
+		inventory = inventory[:digestListLimit]
+	}
+	if errs := helper.pb.ExpandRecords(inventory, []string{"product", "sku"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load inventory products: %v", errs)
+	}
+	for _, record := range inventory {
+		item := LowStockItem{Quantity: record.GetFloat("current_quantity"), ReorderPoint: record.GetFloat("reorder_point")}
+		if product := record.ExpandedOne("product"); product != nil {
+			item.Product = product.GetString("name")
+		}
+		if sku := record.ExpandedOne("sku"); sku != nil {
+			item.SKU = sku.GetString("name")
+		}
+		digest.LowStock = append(digest.LowStock, item)
+	}
+
+	invoices, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"company = {:company} && type = 'sale' && bal > 0 && due_date != '' && due_date < {:today}",
+		"due_date",
+		0,
+		0,
+		dbx.Params{"company": companyID, "today": formatDate(today)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(invoices, []string{"partner"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load invoice partners: %v", errs)
+	}
+	for _, invoice := range invoices {
+		balance := BaseAmount(invoice, "bal")
+		digest.OverdueCount++
+		digest.OverdueTotal += balance
+		if len(digest.Overdue) == digestListLimit {
+			continue
+		}
+		due := invoice.GetDateTime("due_date").Time()
+		row := OverdueReceivable{
+			InvoiceID: invoice.Id,
+			Number:    invoice.GetString("number"),
+			DueDate:   due,
+			DaysLate:  int(today.Sub(startOfDay(due.In(today.Location()))).Hours() / 24),
+			Balance:   balance,
+		}
+		if partner := invoice.ExpandedOne("partner"); partner != nil {
+			row.Partner = partner.GetString("name")
+		}
+		digest.Overdue = append(digest.Overdue, row)
+	}
+	digest.OverdueTotal = roundMoney(digest.OverdueTotal)
+	return digest, nil
+}
+
+// BindDigests schedules the hourly run that queues the digests that have
+// fallen due, rendering them with render.
+func (helper *DbHelper) BindDigests(render DigestRenderer) {
+	helper.renderDigest = render
+	helper.pb.Cron().MustAdd("digests", "5 * * * *", func() {
+		if _, err := helper.SendDueDigests(time.Now()); err != nil {
+			helper.Logger.Printf("Error sending digests: %v", err)
+		}
+	})
+}
+
+// digestDue reports whether a subscription's digest should go out at local,
+// given when it last went out.
+func digestDue(frequency string, local time.Time, lastSent time.Time) bool {
+	if local.Hour() < digestHour {
+		return false
+	}
+	if frequency == "weekly" && local.Weekday() != time.Monday {
+		return false
+	}
+	return lastSent.IsZero() || startOfDay(lastSent.In(local.Location())).Before(startOfDay(local))
+}
+
+// SendDueDigests queues an email for every active subscription whose digest
+// is due at now and returns how many were queued. Delivery, retries and the
+// choice of mailer are left to the notification outbox.
+func (helper *DbHelper) SendDueDigests(now time.Time) (int, error) {
+	if helper.renderDigest == nil {
+		return 0, fmt.Errorf("digests are not bound to a renderer")
+	}
+	subscriptions, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.DigestSubscriptions](),
+		"active = true",
+		"company",
+		0,
+		0,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if errs := helper.pb.ExpandRecords(subscriptions, []string{"user", "company"}, nil); len(errs) > 0 {
+		return 0, fmt.Errorf("failed to load digest subscribers: %v", errs)
+	}
+
+	// subscribers of the same company and frequency share one digest
+	digests := map[string]*Digest{}
+	sent := 0
+	for _, subscription := range subscriptions {
+		company := subscription.ExpandedOne("company")
+		if company == nil {
+			continue
+		}
+		frequency := subscription.GetString("frequency")
+		if !digestDue(frequency, now.In(companyLocation(company)), subscription.GetDateTime("last_sent_at").Time()) {
+			continue
+		}
+		key := company.Id + "/" + frequency
+		if digests[key] == nil {
+			digest, err := helper.BuildDigest(company.Id, frequency, now)
+			if err != nil {
+				helper.Logger.Printf("Error building the %s digest of %s: %v", frequency, company.Id, err)
+				continue
+			}
+			digests[key] = digest
+		}
+		if err := helper.queueDigest(subscription, digests[key], now); err != nil {
+			helper.Logger.Printf("Error queueing digest %s: %v", subscription.Id, err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (helper *DbHelper) queueDigest(subscription *core.Record, digest *Digest, now time.Time) error {
+	recipient := subscription.GetString("email")
+	if recipient == "" {
+		if user := subscription.ExpandedOne("user"); user != nil {
+			recipient = user.Email()
+		}
+	}
+	html, err := helper.renderDigest(digest)
+	if err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	return helper.pb.RunInTransaction(func(txApp core.App) error {
+		_, err := queueNotification(txApp, Notification{
+			Company:     digest.CompanyID,
+			Channel:     "email",
+			Recipient:   recipient,
+			Subject:     digest.Title(),
+			Body:        html,
+			HTML:        true,
+			Kind:        "digest",
+			ReferenceID: subscription.Id,
+		})
+		if err != nil {
+			return err
+		}
+		subscription.Set("last_sent_at", now)
+		return txApp.Save(subscription)
+	})
+}
+
+// DigestSettings are a user's digest preferences for one company. Email
+// overrides the address of the user's account.
+type DigestSettings struct {
+	Frequency string `json:"frequency"`
+	Email     string `json:"email"`
+	Active    bool   `json:"active"`
+}
+
+// FetchDigestSubscription returns the user's digest subscription to the
+// company, or nil if they have none.
+func (helper *DbHelper) FetchDigestSubscription(userID, companyID string) (*models.DigestSubscriptions, error) {
+	record, err := helper.pb.FindFirstRecordByFilter(
+		models.CName[models.DigestSubscriptions](),
+		"user = {:user} && company = {:company}",
+		dbx.Params{"user": userID, "company": companyID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.DigestSubscriptions](record)
+}
+
+// SubscribeToDigest sets the user's digest preferences for a company they
+// belong to.
+func (helper *DbHelper) SubscribeToDigest(userID, companyID string, settings DigestSettings) (*models.DigestSubscriptions, error) {
+	if settings.Frequency != "daily" && settings.Frequency != "weekly" {
+		return nil, fmt.Errorf("unknown digest frequency %q, expected daily or weekly", settings.Frequency)
+	}
+	settings.Email = strings.TrimSpace(settings.Email)
+	if settings.Email != "" {
+		if _, err := mail.ParseAddress(settings.Email); err != nil {
+			return nil, fmt.Errorf("%q is not an email address", settings.Email)
+		}
+	}
+	user, err := helper.pb.FindRecordById(models.CName[models.Users](), userID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(user.GetStringSlice("company"), companyID) {
+		return nil, fmt.Errorf("you are not a member of this company")
+	}
+
+	subscription, err := helper.FetchDigestSubscription(userID, companyID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		if subscription, err = models.NewProxy[models.DigestSubscriptions](helper.pb); err != nil {
+			return nil, err
+		}
+		subscription.Set("user", userID)
+		subscription.Set("company", companyID)
+	}
+	subscription.Set("frequency", settings.Frequency)
+	subscription.SetEmail(settings.Email)
+	subscription.SetActive(settings.Active)
+	if err := helper.pb.Save(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
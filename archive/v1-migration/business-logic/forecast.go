@@ -0,0 +1,452 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Forecast defaults: twelve weeks of history, two weeks ahead, a week's lead
+// time and stock for 95% of lead times.
+const (
+	defaultForecastHistory  = 84
+	defaultForecastHorizon  = 14
+	defaultForecastLeadTime = 7
+	forecastServiceZ        = 1.65
+)
+
+// The longest history, horizon and lead time a forecast takes, in days. A
+// series of the history is kept per line.
+const (
+	maxForecastHistory  = 730
+	maxForecastHorizon  = 365
+	maxForecastLeadTime = 365
+)
+
+// forecastWarmUp is the number of days a model starts from, a week, or two
+// when it learns a weekly pattern. Only the forecasts after it are scored.
+const forecastWarmUp = 7
+
+// Smoothing constants tried when fitting a line's model; the pair with the
+// smallest one-day-ahead error wins.
+var (
+	forecastAlphas = []float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.7}
+	forecastGammas = []float64{0, 0.05, 0.1, 0.2, 0.3}
+)
+
+// ForecastQuery sets what a demand forecast looks at: the days of history to
+// learn from, the days to forecast, the days a reorder takes to arrive, and
+// optionally a single product.
+type ForecastQuery struct {
+	History   int    `json:"history"`
+	Horizon   int    `json:"horizon"`
+	LeadTime  int    `json:"lead_time"`
+	ProductID string `json:"product_id"`
+}
+
+func (q *ForecastQuery) defaults() error {
+	if q.History == 0 {
+		q.History = defaultForecastHistory
+	}
+	if q.Horizon == 0 {
+		q.Horizon = defaultForecastHorizon
+	}
+	if q.LeadTime == 0 {
+		q.LeadTime = defaultForecastLeadTime
+	}
+	if q.History < 0 || q.Horizon < 0 || q.LeadTime < 0 {
+		return fmt.Errorf("history, horizon and lead time must be positive")
+	}
+	if q.History > maxForecastHistory || q.Horizon > maxForecastHorizon || q.LeadTime > maxForecastLeadTime {
+		return fmt.Errorf("history is limited to %d days, horizon and lead time to %d", maxForecastHistory, maxForecastHorizon)
+	}
+	return nil
+}
+
+// DayForecast is the units expected to sell on a day.
+type DayForecast struct {
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+}
+
+// ForecastError scores a model's one-day-ahead forecasts over the history
+// after its warm-up, each made from the days before only: the mean absolute
+// and root mean squared error in units, the bias (positive when it forecasts
+// too much) and the absolute error as a share of demand, which is nil when
+// nothing sold.
+type ForecastError struct {
+	Days int      `json:"days"`
+	MAE  float64  `json:"mae"`
+	RMSE float64  `json:"rmse"`
+	Bias float64  `json:"bias"`
+	WAPE *float64 `json:"wape"`
+}
+
+// SkuForecast is the demand forecast of one inventory line and the reorder
+// point it suggests.
+type SkuForecast struct {
+	InventoryID           string        `json:"inventory_id"`
+	ProductID             string        `json:"product_id"`
+	Product               string        `json:"product"`
+	SKU                   string        `json:"sku"`
+	CurrentQuantity       float64       `json:"current_quantity"`
+	ReorderPoint          float64       `json:"reorder_point"`
+	SuggestedReorderPoint float64       `json:"suggested_reorder_point"`
+	HistoryDays           int           `json:"history_days"`
+	Demand                float64       `json:"demand"`
+	Seasonal              bool          `json:"seasonal"`
+	Alpha                 float64       `json:"alpha"`
+	Gamma                 float64       `json:"gamma"`
+	Weekdays              []float64     `json:"weekdays"`
+	Forecast              []DayForecast `json:"forecast"`
+	ForecastTotal         float64       `json:"forecast_total"`
+	Error                 ForecastError `json:"error"`
+}
+
+// DemandForecast is the forecast of every inventory line of a company.
+type DemandForecast struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Horizon  int           `json:"horizon"`
+	LeadTime int           `json:"lead_time"`
+	Lines    []SkuForecast `json:"lines"`
+}
+
+// smoothingModel is simple exponential smoothing with an additive
+// day-of-week pattern: the forecast of a day is the level plus the season of
+// its weekday, and each day's error moves the level by alpha and the
+// weekday's season by gamma of what is left.
+type smoothingModel struct {
+	alpha, gamma float64
+	level        float64
+	season       [7]float64
+}
+
+// fitSmoothing starts the model from the warm-up days of the daily demand,
+// which starts on first, then runs it over the rest and returns it with its
+// one-day-ahead errors there.
+func fitSmoothing(demand []float64, first time.Weekday, alpha, gamma float64, seasonal bool) (smoothingModel, []float64) {
+	m := smoothingModel{alpha: alpha, gamma: gamma}
+	warmUp := forecastWarmUp
+	if seasonal {
+		warmUp *= 2
+	}
+	warmUp = min(warmUp, len(demand))
+	if warmUp == 0 {
+		return m, nil
+	}
+
+	// the level starts at the warm-up's mean and each weekday at how far its
+	// average sits from it
+	var sum float64
+	var weekday [7]float64
+	var count [7]int
+	for i, d := range demand[:warmUp] {
+		sum += d
+		w := (int(first) + i) % 7
+		weekday[w] += d
+		count[w]++
+	}
+	m.level = sum / float64(warmUp)
+	if seasonal {
+		for w := range weekday {
+			if count[w] > 0 {
+				m.season[w] = weekday[w]/float64(count[w]) - m.level
+			}
+		}
+	}
+
+	var errs []float64
+	for i := warmUp; i < len(demand); i++ {
+		w := (int(first) + i) % 7
+		e := demand[i] - max(m.level+m.season[w], 0)
+		errs = append(errs, -e)
+		m.level += alpha * e
+		if seasonal {
+			m.season[w] += gamma * (1 - alpha) * e
+		}
+	}
+	return m, errs
+}
+
+// predict returns the demand forecast of day.
+func (m smoothingModel) predict(day time.Time) float64 {
+	return max(m.level+m.season[day.Weekday()], 0)
+}
+
+func scoreForecast(errs, demand []float64) ForecastError {
+	score := ForecastError{Days: len(errs)}
+	if len(errs) == 0 {
+		return score
+	}
+	var abs, sq, bias, actual float64
+	for _, e := range errs {
+		abs += math.Abs(e)
+		sq += e * e
+		bias += e
+	}
+	for _, d := range demand[len(demand)-len(errs):] {
+		actual += d
+	}
+	n := float64(len(errs))
+	score.MAE = math.Round(abs/n*100) / 100
+	score.RMSE = math.Round(math.Sqrt(sq/n)*100) / 100
+	score.Bias = math.Round(bias/n*100) / 100
+	if actual > 0 {
+		wape := math.Round(abs/actual*1000) / 10
+		score.WAPE = &wape
+	}
+	return score
+}
+
+// forecastLine fits the smoothing constants that forecast the line's history
+// best and forecasts the horizon from today.
+func forecastLine(line *SkuForecast, demand []float64, first, today time.Time, q ForecastQuery) {
+	// a weekly pattern needs two weeks to start from and one to be scored on
+	seasonal := len(demand) >= 3*forecastWarmUp
+	gammas := forecastGammas
+	if !seasonal {
+		gammas = []float64{0}
+	}
+
+	var best smoothingModel
+	var bestErrs []float64
+	bestSSE := math.Inf(1)
+	for _, alpha := range forecastAlphas {
+		for _, gamma := range gammas {
+			m, errs := fitSmoothing(demand, first.Weekday(), alpha, gamma, seasonal)
+			var sse float64
+			for _, e := range errs {
+				sse += e * e
+			}
+			if sse < bestSSE {
+				best, bestErrs, bestSSE = m, errs, sse
+			}
+		}
+	}
+
+	line.HistoryDays = len(demand)
+	line.Seasonal = seasonal
+	line.Alpha = best.alpha
+	line.Gamma = best.gamma
+	line.Error = scoreForecast(bestErrs, demand)
+	line.Weekdays = make([]float64, 7)
+	for i := range line.Weekdays {
+		// Monday first
+		line.Weekdays[i] = math.Round(best.predict(today.AddDate(0, 0, (i+1-int(today.Weekday())+7)%7))*100) / 100
+	}
+	for _, d := range demand {
+		line.Demand += d
+	}
+
+	line.Forecast = make([]DayForecast, 0, q.Horizon)
+	for i := 0; i < q.Horizon; i++ {
+		day := today.AddDate(0, 0, i)
+		quantity := math.Round(best.predict(day)*100) / 100
+		line.Forecast = append(line.Forecast, DayForecast{Date: day, Quantity: quantity})
+		line.ForecastTotal += quantity
+	}
+	line.ForecastTotal = math.Round(line.ForecastTotal*100) / 100
+
+	// enough for the lead time's forecast demand, plus safety stock for the
+	// forecast being wrong over as many days
+	var leadDemand float64
+	for i := 0; i < q.LeadTime; i++ {
+		leadDemand += best.predict(today.AddDate(0, 0, i))
+	}
+	safety := forecastServiceZ * line.Error.RMSE * math.Sqrt(float64(q.LeadTime))
+	line.SuggestedReorderPoint = math.Ceil(leadDemand + safety)
+}
+
+// ForecastDemand forecasts the daily units sold of each of the company's
+// inventory lines from the lines of their sales and returns, and the sale
+// and return movements of imported legacy logs, and suggests a reorder point
+// covering the lead time. Days are the company's local days; history starts
+// no earlier than the line was stocked or first moved.
+func (helper *DbHelper) ForecastDemand(companyID string, q ForecastQuery, now time.Time) (*DemandForecast, error) {
+	if err := q.defaults(); err != nil {
+		return nil, err
+	}
+	company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return nil, fmt.Errorf("company %s not found: %w", companyID, err)
+	}
+	today := startOfDay(now.In(companyLocation(company)))
+	from := today.AddDate(0, 0, -q.History)
+
+	filter := "company = {:company}"
+	params := dbx.Params{"company": companyID, "from": formatDate(from), "to": formatDate(today), "product": q.ProductID}
+	if q.ProductID != "" {
+		filter += " && product = {:product}"
+	}
+	inventory, err := helper.pb.FindRecordsByFilter(models.CName[models.Inventory](), filter, "created", 0, 0, params)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(inventory, []string{"product", "sku"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load inventory products: %v", errs)
+	}
+
+	demand := map[string][]float64{}
+	firstMoved := map[string]time.Time{}
+	add := func(productID, skuID string, date time.Time, units float64) {
+		key := productID + "/" + skuID
+		if demand[key] == nil {
+			demand[key] = make([]float64, q.History)
+		}
+		if first, ok := firstMoved[key]; !ok || date.Before(first) {
+			firstMoved[key] = date
+		}
+		day := startOfDay(date.In(today.Location()))
+		if i := int(math.Round(day.Sub(from).Hours() / 24)); i >= 0 && i < q.History {
+			demand[key][i] += units
+		}
+	}
+
+	// sales at the till are counted from their lines, returns taking back
+	// what they bring back
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to}",
+		"transaction_date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(sales, []string{"sales_details"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load sale lines: %v", errs)
+	}
+	for _, sale := range sales {
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		}
+		for _, line := range sale.ExpandedAll("sales_details") {
+			if q.ProductID != "" && line.GetString("product") != q.ProductID {
+				continue
+			}
+			add(line.GetString("product"), line.GetString("sku"), sale.GetDateTime("transaction_date").Time(), sign*math.Abs(line.GetFloat("quantity")))
+		}
+	}
+
+	// sales imported from the legacy log only exist as stock movements;
+	// sales take stock out and returns put it back, so demand is the
+	// opposite of the change
+	params["reference"] = legacyImportReference
+	movements, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.InventoryTransactions](),
+		filter+" && reference_type = {:reference} && (reason_code = 'sale' || reason_code = 'return') && transaction_date >= {:from} && transaction_date < {:to}",
+		"transaction_date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, movement := range movements {
+		add(movement.GetString("product"), movement.GetString("sku"), movement.GetDateTime("transaction_date").Time(), -movement.GetFloat("quantity_change"))
+	}
+
+	report := &DemandForecast{From: from, To: today, Horizon: q.Horizon, LeadTime: q.LeadTime, Lines: []SkuForecast{}}
+	for _, record := range inventory {
+		line := SkuForecast{
+			InventoryID:     record.Id,
+			ProductID:       record.GetString("product"),
+			CurrentQuantity: record.GetFloat("current_quantity"),
+			ReorderPoint:    record.GetFloat("reorder_point"),
+		}
+		if product := record.ExpandedOne("product"); product != nil {
+			line.Product = product.GetString("name")
+		}
+		if sku := record.ExpandedOne("sku"); sku != nil {
+			line.SKU = sku.GetString("name")
+		}
+
+		key := line.ProductID + "/" + record.GetString("sku")
+		series := demand[key]
+		if series == nil {
+			series = make([]float64, q.History)
+		}
+		start := record.GetDateTime("created").Time()
+		if moved, ok := firstMoved[key]; ok && moved.Before(start) {
+			start = moved
+		}
+		skip := 0
+		if start = startOfDay(start.In(today.Location())); start.After(from) {
+			skip = min(int(math.Round(start.Sub(from).Hours()/24)), q.History)
+		}
+		for i := range series {
+			series[i] = max(series[i], 0)
+		}
+		forecastLine(&line, series[skip:], from.AddDate(0, 0, skip), today, q)
+		report.Lines = append(report.Lines, line)
+	}
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return report.Lines[i].ForecastTotal > report.Lines[j].ForecastTotal
+	})
+	return report, nil
+}
+
+// ApplyReorderPoints sets the suggested reorder points of the forecast on
+// the given inventory lines, or on all of them when none are given, and
+// returns the lines changed. Lines with no sales recorded are left alone.
+// Only managers may change reorder points.
+func (helper *DbHelper) ApplyReorderPoints(companyID, userID string, q ForecastQuery, inventoryIDs []string) ([]SkuForecast, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	forecast, err := helper.ForecastDemand(companyID, q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []SkuForecast{}
+	err = helper.pb.RunInTransaction(func(txApp core.App) error {
+		for _, line := range forecast.Lines {
+			if len(inventoryIDs) > 0 && !slices.Contains(inventoryIDs, line.InventoryID) {
+				continue
+			}
+			// without recorded sales there is nothing to suggest from, and
+			// a reorder point of zero would never flag the line as low
+			if line.Demand == 0 || line.Error.Days == 0 {
+				continue
+			}
+			if line.SuggestedReorderPoint == line.ReorderPoint {
+				continue
+			}
+			record, err := txApp.FindRecordById(models.CName[models.Inventory](), line.InventoryID)
+			if err != nil {
+				return err
+			}
+			record.Set("reorder_point", line.SuggestedReorderPoint)
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+			err = writeAuditLog(txApp, companyID, userID, "inventory.reorder_point", models.CName[models.Inventory](), record.Id, map[string]any{
+				"from":      line.ReorderPoint,
+				"to":        line.SuggestedReorderPoint,
+				"lead_time": forecast.LeadTime,
+				"rmse":      line.Error.RMSE,
+			})
+			if err != nil {
+				return err
+			}
+			applied = append(applied, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultTimezone is used for companies that have not set a timezone of
+// their own.
+const DefaultTimezone = "Africa/Nairobi"
+
+// companyLocation returns the company's timezone, falling back to
+// DefaultTimezone when it is unset or unknown.
+func companyLocation(company *core.Record) *time.Location {
+	name := company.GetString("timezone")
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// HeatmapCell is the sales made in one local hour of one weekday over the
+// range. Revenue is in the base currency.
+type HeatmapCell struct {
+	Weekday       string  `json:"weekday"`
+	Hour          int     `json:"hour"`
+	Count         int     `json:"count"`
+	Revenue       float64 `json:"revenue"`
+	AverageBasket float64 `json:"average_basket"`
+}
+
+// SalesHeatmap buckets a company's sales by local weekday and hour. Cells
+// holds one row per weekday, Monday first, of 24 hours each. Returns are
+// left out, so the figures show when customers buy.
+type SalesHeatmap struct {
+	CompanyID string          `json:"company_id"`
+	Branches  []string        `json:"branches"`
+	Timezone  string          `json:"timezone"`
+	Currency  string          `json:"currency"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Count     int             `json:"count"`
+	Revenue   float64         `json:"revenue"`
+	Peak      *HeatmapCell    `json:"peak"`
+	Cells     [][]HeatmapCell `json:"cells"`
+}
+
+// heatmapWeekdays are the heatmap rows in order.
+var heatmapWeekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// SalesHeatmap buckets the sales of the company and its branches, or of one
+// branch, made on the days of the range, by the local hour and weekday of
+// the branch that made them.
+func (helper *DbHelper) SalesHeatmap(companyID, branchID string, days DateRange) (*SalesHeatmap, error) {
+	company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return nil, fmt.Errorf("company %s not found: %w", companyID, err)
+	}
+
+	branches := []*core.Record{company}
+	if branchID != "" && branchID != companyID {
+		branch, err := helper.pb.FindRecordById(models.CName[models.Companies](), branchID)
+		if err != nil || branch.GetString("parent_company") != companyID {
+			return nil, fmt.Errorf("%s is not a branch of the company", branchID)
+		}
+		branches = []*core.Record{branch}
+	} else if branchID == "" {
+		children, err := helper.pb.FindRecordsByFilter(
+			models.CName[models.Companies](),
+			"parent_company = {:company}",
+			"name",
+			0,
+			0,
+			dbx.Params{"company": companyID},
+		)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, children...)
+	}
+
+	currency := company.GetString("base_currency")
+	if currency == "" {
+		currency = DefaultBaseCurrency
+	}
+	heatmap := &SalesHeatmap{
+		CompanyID: companyID,
+		Timezone:  companyLocation(branches[0]).String(),
+		Currency:  currency,
+		From:      days.From,
+		To:        days.To,
+		Cells:     make([][]HeatmapCell, len(heatmapWeekdays)),
+	}
+	row := map[time.Weekday]int{}
+	for i, weekday := range heatmapWeekdays {
+		row[weekday] = i
+		heatmap.Cells[i] = make([]HeatmapCell, 24)
+		for hour := range heatmap.Cells[i] {
+			heatmap.Cells[i][hour] = HeatmapCell{Weekday: weekday.String(), Hour: hour}
+		}
+	}
+
+	// a local day may start up to a day either side of the UTC one
+	params := dbx.Params{
+		"from": formatDate(days.From.AddDate(0, 0, -1)),
+		"to":   formatDate(days.To.AddDate(0, 0, 1)),
+	}
+	var match []string
+	locations := map[string]*time.Location{}
+	for i, branch := range branches {
+		heatmap.Branches = append(heatmap.Branches, branch.Id)
+		locations[branch.Id] = companyLocation(branch)
+		key := fmt.Sprintf("c%d", i)
+		params[key] = branch.Id
+		match = append(match, "company = {:"+key+"}")
+	}
+
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"("+strings.Join(match, " || ")+") && transaction_type != 'return' && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := days.From.Format(time.DateOnly), days.To.AddDate(0, 0, -1).Format(time.DateOnly)
+	for _, sale := range sales {
+		local := sale.GetDateTime("transaction_date").Time().In(locations[sale.GetString("company")])
+		if day := local.Format(time.DateOnly); day < first || day > last {
+			continue
+		}
+		cell := &heatmap.Cells[row[local.Weekday()]][local.Hour()]
+		amount := BaseAmount(sale, "total_amount")
+		cell.Count++
+		cell.Revenue += amount
+		heatmap.Count++
+		heatmap.Revenue += amount
+	}
+
+	for i := range heatmap.Cells {
+		for hour := range heatmap.Cells[i] {
+			cell := &heatmap.Cells[i][hour]
+			cell.Revenue = roundMoney(cell.Revenue)
+			if cell.Count > 0 {
+				cell.AverageBasket = roundMoney(cell.Revenue / float64(cell.Count))
+			}
+			if cell.Count > 0 && (heatmap.Peak == nil || cell.Revenue > heatmap.Peak.Revenue) {
+				heatmap.Peak = cell
+			}
+		}
+	}
+	heatmap.Revenue = roundMoney(heatmap.Revenue)
+	return heatmap, nil
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// InventoryMovement is one change to the stock of a product (and SKU, if it
+// has several) with the document that caused it.
+type InventoryMovement struct {
+	CompanyID     string
+	ProductID     string
+	SkuID         string
+	Change        float64
+	Reason        models.ReasonCodeSelectType
+	ReferenceType string
+	ReferenceID   string
+	UserID        string
+	Date          types.DateTime
+	// UnitCost, in the base currency, updates the weighted average cost
+	// price of incoming stock. Zero leaves the cost price unchanged.
+	UnitCost float64
+}
+
+// recordInventoryMovement applies a movement to the inventory row of the
+// product, creating the row on first receipt, and logs it to
+// inventory_transactions with the resulting quantity.
+func recordInventoryMovement(app core.App, m InventoryMovement) (*models.InventoryTransactions, error) {
+	if m.Change == 0 {
+		return nil, fmt.Errorf("inventory movement for product %s has no quantity", m.ProductID)
+	}
+	if m.Date.IsZero() {
+		m.Date = types.NowDateTime()
+	}
+
+	inventory, err := findInventory(app, m.CompanyID, m.ProductID, m.SkuID)
+	if err != nil {
+		return nil, err
+	}
+	if inventory == nil {
+		if inventory, err = models.NewProxy[models.Inventory](app); err != nil {
+			return nil, err
+		}
+		inventory.Set("company", m.CompanyID)
+		inventory.Set("product", m.ProductID)
+		inventory.Set("sku", m.SkuID)
+	}
+
+	before := inventory.CurrentQuantity()
+	after := before + m.Change
+	if m.UnitCost > 0 && m.Change > 0 {
+		// stock bought at different prices is valued at the weighted average
+		value := max(before, 0)*inventory.CostPrice() + m.Change*m.UnitCost
+		inventory.SetCostPrice(roundMoney(value / (max(before, 0) + m.Change)))
+	}
+	inventory.SetCurrentQuantity(after)
+	if err := app.Save(inventory); err != nil {
+		return nil, err
+	}
+
+	movement, err := models.NewProxy[models.InventoryTransactions](app)
+	if err != nil {
+		return nil, err
+	}
+	movement.Set("company", m.CompanyID)
+	movement.Set("product", m.ProductID)
+	movement.Set("sku", m.SkuID)
+	movement.SetQuantityChange(m.Change)
+	movement.SetQuantityAfter(after)
+	movement.SetTransactionDate(m.Date)
+	movement.SetReasonCode(m.Reason)
+	movement.SetReferenceType(m.ReferenceType)
+	movement.SetReferenceId(m.ReferenceID)
+	movement.Set("user", m.UserID)
+	if err := app.Save(movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func findInventory(app core.App, companyID, productID, skuID string) (*models.Inventory, error) {
+	filter := "company = {:company} && product = {:product} && sku = {:sku}"
+	if skuID == "" {
+		// an empty placeholder does not match an unset relation
+		filter = "company = {:company} && product = {:product} && sku = ''"
+	}
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.Inventory](),
+		filter,
+		"",
+		1,
+		0,
+		dbx.Params{"company": companyID, "product": productID, "sku": skuID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return models.WrapRecord[models.Inventory](records[0])
+}
+
+// findLowStock returns the company's inventory lines at or below their
+// reorder point, lowest stock first. Lines without a reorder point are never
+// low.
+func findLowStock(app core.App, companyID string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(
+		models.CName[models.Inventory](),
+		"company = {:company} && reorder_point > 0 && current_quantity <= reorder_point",
+		"current_quantity",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+}
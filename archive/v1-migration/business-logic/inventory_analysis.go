@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Products making up the first 80% of the contribution are class A, those
+// making up the next 15% class B and the rest class C.
+const (
+	abcClassAShare = 80
+	abcClassBShare = 95
+)
+
+// ABCProduct is a product's contribution to the company's sales over the
+// range and the class it ranks into. Share and CumulativeShare are
+// percentages of the total contribution.
+type ABCProduct struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	ProductFigures
+	Contribution    float64 `json:"contribution"`
+	Share           float64 `json:"share"`
+	CumulativeShare float64 `json:"cumulative_share"`
+	Class           string  `json:"class"`
+}
+
+// ABCReport ranks every product of the company into A, B and C by revenue or
+// margin contribution, in the base currency.
+type ABCReport struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Basis    string         `json:"basis"`
+	Currency string         `json:"currency"`
+	Total    float64        `json:"total"`
+	Classes  map[string]int `json:"classes"`
+	Products []ABCProduct   `json:"products"`
+}
+
+// ABCClassification ranks the company's products by their revenue or, with
+// basis "margin", their profit over the range. Products that sold nothing or
+// lost money are class C.
+func (helper *DbHelper) ABCClassification(companyID string, days DateRange, basis string) (*ABCReport, error) {
+	if basis == "" {
+		basis = "revenue"
+	}
+	if basis != "revenue" && basis != "margin" {
+		return nil, fmt.Errorf("unknown basis %q, expected revenue or margin", basis)
+	}
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+	figures, err := computeProductFigures(helper.pb, companyID, days.From, days.To, nil)
+	if err != nil {
+		return nil, err
+	}
+	products, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Products](),
+		"company = {:company}",
+		"name",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ABCReport{
+		From:     days.From,
+		To:       days.To,
+		Basis:    basis,
+		Currency: currency,
+		Classes:  map[string]int{"A": 0, "B": 0, "C": 0},
+		Products: make([]ABCProduct, 0, len(products)),
+	}
+	for _, product := range products {
+		row := ABCProduct{ProductID: product.Id, Name: product.GetString("name")}
+		if f := figures[product.Id]; f != nil {
+			row.ProductFigures = *f
+		}
+		row.Contribution = row.Revenue
+		if basis == "margin" {
+			row.Contribution = row.Profit
+		}
+		if row.Contribution > 0 {
+			report.Total += row.Contribution
+		}
+		report.Products = append(report.Products, row)
+	}
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		return report.Products[i].Contribution > report.Products[j].Contribution
+	})
+
+	report.Total = roundMoney(report.Total)
+	var cumulative float64
+	for i := range report.Products {
+		row := &report.Products[i]
+		row.Class = "C"
+		if row.Contribution > 0 && report.Total > 0 {
+			// a product is A while the products before it make up less than 80%
+			before := cumulative
+			cumulative += row.Contribution
+			row.Share = roundMoney(row.Contribution / report.Total * 100)
+			row.CumulativeShare = roundMoney(cumulative / report.Total * 100)
+			switch {
+			case before/report.Total*100 < abcClassAShare:
+				row.Class = "A"
+			case before/report.Total*100 < abcClassBShare:
+				row.Class = "B"
+			}
+		}
+		report.Classes[row.Class]++
+	}
+	return report, nil
+}
+
+// DeadStockItem is an inventory line still holding stock that has not sold
+// for the report's number of days. Value is the quantity at cost.
+type DeadStockItem struct {
+	InventoryID string     `json:"inventory_id"`
+	ProductID   string     `json:"product_id"`
+	Product     string     `json:"product"`
+	SKU         string     `json:"sku"`
+	Quantity    float64    `json:"quantity"`
+	CostPrice   float64    `json:"cost_price"`
+	Value       float64    `json:"value"`
+	LastSale    *time.Time `json:"last_sale"`
+	DaysIdle    int        `json:"days_idle"`
+}
+
+// DeadStockReport lists the company's dead stock, longest idle first, and
+// the capital tied up in it.
+type DeadStockReport struct {
+	AsOf     time.Time       `json:"as_of"`
+	Days     int             `json:"days"`
+	Currency string          `json:"currency"`
+	Value    float64         `json:"value"`
+	Items    []DeadStockItem `json:"items"`
+}
+
+// DeadStock flags the inventory lines with stock on hand that have not sold
+// in the days before asOf. Lines that never sold count as idle since they
+// were stocked, so new stock is not flagged before its time.
+func (helper *DbHelper) DeadStock(companyID string, days int, asOf time.Time) (*DeadStockReport, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("days must be positive")
+	}
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sold []struct {
+		Product  string `db:"product"`
+		Sku      string `db:"sku"`
+		LastSale string `db:"last_sale"`
+	}
+	err = helper.pb.DB().NewQuery(`SELECT d.product AS product, d.sku AS sku, MAX(s.transaction_date) AS last_sale
+		FROM sales_transactions s, json_each(CASE WHEN json_valid(s.sales_details) THEN s.sales_details ELSE json_array(s.sales_details) END) j
+		JOIN sales_details d ON d.id = j.value
+		WHERE s.company = {:company} AND s.transaction_type != 'return' AND d.quantity != 0 AND s.transaction_date < {:as_of}
+		GROUP BY d.product, d.sku`).Bind(dbx.Params{"company": companyID, "as_of": formatDate(asOf)}).All(&sold)
+	if err != nil {
+		return nil, err
+	}
+	// a line without a SKU sells whenever any SKU of its product sells
+	lastSale := map[string]time.Time{}
+	for _, row := range sold {
+		date, err := types.ParseDateTime(row.LastSale)
+		if err != nil || date.IsZero() {
+			continue
+		}
+		for _, key := range []string{row.Product + "/" + row.Sku, row.Product + "/"} {
+			if date.Time().After(lastSale[key]) {
+				lastSale[key] = date.Time()
+			}
+		}
+	}
+
+	inventory, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Inventory](),
+		"company = {:company} && current_quantity > 0",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(inventory, []string{"product", "sku"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load inventory products: %v", errs)
+	}
+
+	cutoff := asOf.AddDate(0, 0, -days)
+	report := &DeadStockReport{AsOf: asOf, Days: days, Currency: currency, Items: []DeadStockItem{}}
+	for _, record := range inventory {
+		item := DeadStockItem{
+			InventoryID: record.Id,
+			ProductID:   record.GetString("product"),
+			Quantity:    record.GetFloat("current_quantity"),
+			CostPrice:   record.GetFloat("cost_price"),
+		}
+		since := record.GetDateTime("created").Time()
+		if last, ok := lastSale[item.ProductID+"/"+record.GetString("sku")]; ok {
+			since = last
+			item.LastSale = &last
+		}
+		if !since.Before(cutoff) {
+			continue
+		}
+		if product := record.ExpandedOne("product"); product != nil {
+			item.Product = product.GetString("name")
+		}
+		if sku := record.ExpandedOne("sku"); sku != nil {
+			item.SKU = sku.GetString("name")
+		}
+		item.Value = roundMoney(item.Quantity * item.CostPrice)
+		item.DaysIdle = int(asOf.Sub(since).Hours() / 24)
+		report.Value += item.Value
+		report.Items = append(report.Items, item)
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].DaysIdle > report.Items[j].DaysIdle
+	})
+	report.Value = roundMoney(report.Value)
+	return report, nil
+}
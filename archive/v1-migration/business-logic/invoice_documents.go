@@ -0,0 +1,455 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/mail"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// InvoiceLine is one billed item. Prices exclude tax, as at the till, which
+// adds each product's tax on top; TaxRate is a fraction as stored on the
+// product.
+type InvoiceLine struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Net         float64 `json:"net"`
+	TaxRate     float64 `json:"tax_rate"`
+	Tax         float64 `json:"tax"`
+	Total       float64 `json:"total"`
+}
+
+// InvoiceTax is the taxable amount and tax of every line at one rate.
+type InvoiceTax struct {
+	Rate    float64 `json:"rate"`
+	Taxable float64 `json:"taxable"`
+	Tax     float64 `json:"tax"`
+}
+
+// InvoiceDocument is an invoice laid out for printing or sending, in the
+// invoice's own currency.
+type InvoiceDocument struct {
+	Company      *models.Companies `json:"company"`
+	Partner      *models.Partners  `json:"partner"`
+	Invoice      *models.Invoices  `json:"invoice"`
+	Title        string            `json:"title"`
+	Number       string            `json:"number"`
+	Currency     string            `json:"currency"`
+	Date         time.Time         `json:"date"`
+	DueDate      time.Time         `json:"due_date"`
+	PaymentTerms string            `json:"payment_terms"`
+	LogoURL      string            `json:"logo_url"`
+	Lines        []InvoiceLine     `json:"lines"`
+	Taxes        []InvoiceTax      `json:"taxes"`
+	Subtotal     float64           `json:"subtotal"`
+	Tax          float64           `json:"tax"`
+	Total        float64           `json:"total"`
+	Paid         float64           `json:"paid"`
+	BalanceDue   float64           `json:"balance_due"`
+
+	logoName string
+	logo     []byte
+}
+
+// TaxPercent formats a tax rate fraction as a percentage.
+func TaxPercent(rate float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", rate*100), "0"), ".") + "%"
+}
+
+// InvoiceDocument gathers everything printed on an invoice: the company's
+// branding, the partner, the lines of the sales or goods received it bills
+// with their tax, the payment terms and what is still due.
+func (helper *DbHelper) InvoiceDocument(companyID, invoiceID string) (*InvoiceDocument, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.Invoices](), invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("invoice %s not found: %w", invoiceID, err)
+	}
+	if record.GetString("company") != companyID {
+		return nil, fmt.Errorf("invoice %s does not belong to the company", invoiceID)
+	}
+	invoice, err := models.WrapRecord[models.Invoices](record)
+	if err != nil {
+		return nil, err
+	}
+	company, err := helper.FetchCompanyById(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &InvoiceDocument{
+		Company:  company,
+		Invoice:  invoice,
+		Title:    "Tax Invoice",
+		Number:   documentNumber(record),
+		Currency: record.GetString("currency"),
+		Date:     record.GetDateTime("date").Time(),
+		DueDate:  record.GetDateTime("due_date").Time(),
+	}
+	if record.GetString("type") == "purchase" {
+		doc.Title = "Purchase Invoice"
+	}
+	if doc.Currency == "" {
+		if doc.Currency, err = helper.BaseCurrency(companyID); err != nil {
+			return nil, err
+		}
+	}
+
+	if partnerID := record.GetString("partner"); partnerID != "" {
+		partnerRecord, err := helper.pb.FindRecordById(models.CName[models.Partners](), partnerID)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s not found: %w", partnerID, err)
+		}
+		if doc.Partner, err = models.WrapRecord[models.Partners](partnerRecord); err != nil {
+			return nil, err
+		}
+		if days := partnerRecord.GetInt("payment_terms_days"); days > 0 {
+			doc.PaymentTerms = fmt.Sprintf("Payment due within %d days of the invoice date", days)
+		} else {
+			doc.PaymentTerms = "Payment due on receipt"
+		}
+	}
+
+	if record.GetString("type") == "purchase" {
+		doc.Lines, err = helper.receivedInvoiceLines(invoiceID)
+	} else {
+		doc.Lines, err = helper.saleInvoiceLines(record.GetStringSlice("sales"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Lines) == 0 {
+		// invoices raised by hand bill a single amount
+		amount := record.GetFloat("amount")
+		doc.Lines = []InvoiceLine{{Description: "Invoice amount", Quantity: 1, UnitPrice: amount, Net: amount, Total: amount}}
+	}
+
+	taxes := map[float64]*InvoiceTax{}
+	var linesTotal float64
+	for _, line := range doc.Lines {
+		doc.Subtotal = roundMoney(doc.Subtotal + line.Net)
+		doc.Tax = roundMoney(doc.Tax + line.Tax)
+		linesTotal = roundMoney(linesTotal + line.Total)
+		if line.TaxRate == 0 {
+			continue
+		}
+		t, ok := taxes[line.TaxRate]
+		if !ok {
+			t = &InvoiceTax{Rate: line.TaxRate}
+			taxes[line.TaxRate] = t
+		}
+		t.Taxable = roundMoney(t.Taxable + line.Net)
+		t.Tax = roundMoney(t.Tax + line.Tax)
+	}
+
+	// the invoice amount is what is owed; when the billed lines come to
+	// another figure, e.g. only the unpaid part of the sales is invoiced,
+	// the tax is split out of the amount in the lines' proportions and an
+	// adjustment line reconciles the lines with it
+	doc.Total = roundMoney(record.GetFloat("amount"))
+	if linesTotal != doc.Total {
+		factor := 0.0
+		if linesTotal != 0 {
+			factor = doc.Total / linesTotal
+		}
+		var tax float64
+		for _, t := range taxes {
+			t.Taxable = roundMoney(t.Taxable * factor)
+			t.Tax = roundMoney(t.Tax * factor)
+			tax = roundMoney(tax + t.Tax)
+		}
+		adjustment := InvoiceLine{
+			Description: "Adjustment to the invoice amount",
+			Quantity:    1,
+			Net:         roundMoney(doc.Total - tax - doc.Subtotal),
+			Tax:         roundMoney(tax - doc.Tax),
+			Total:       roundMoney(doc.Total - linesTotal),
+		}
+		adjustment.UnitPrice = adjustment.Net
+		doc.Lines = append(doc.Lines, adjustment)
+		doc.Tax = tax
+		doc.Subtotal = roundMoney(doc.Total - tax)
+	}
+	for _, t := range taxes {
+		doc.Taxes = append(doc.Taxes, *t)
+	}
+	sort.Slice(doc.Taxes, func(i, j int) bool { return doc.Taxes[i].Rate > doc.Taxes[j].Rate })
+	doc.BalanceDue = roundMoney(record.GetFloat("bal"))
+	doc.Paid = roundMoney(doc.Total - doc.BalanceDue)
+
+	if company.Logo() != "" {
+		doc.LogoURL = generateImageUrl(models.CName[models.Companies](), company.Id, company.Logo(), ThumnailSize{})
+		doc.logoName, doc.logo = helper.companyLogo(company)
+	}
+	return doc, nil
+}
+
+// formatQuantity drops the decimals of whole quantities.
+func formatQuantity(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func invoiceLine(product *core.Record, sku *core.Record, quantity, unitPrice float64) InvoiceLine {
+	line := InvoiceLine{Quantity: quantity, UnitPrice: unitPrice, Net: roundMoney(quantity * unitPrice)}
+	if product != nil {
+		line.Description = product.GetString("name")
+		line.TaxRate = product.GetFloat("taxRate")
+	}
+	if sku != nil && sku.GetString("name") != "" {
+		line.Description = strings.TrimSpace(line.Description + " (" + sku.GetString("name") + ")")
+	}
+	line.Tax = roundMoney(line.Net * line.TaxRate)
+	line.Total = roundMoney(line.Net + line.Tax)
+	return line
+}
+
+// saleInvoiceLines lists the items of the sales an invoice bills, with any
+// discount given on them.
+func (helper *DbHelper) saleInvoiceLines(saleIDs []string) ([]InvoiceLine, error) {
+	if len(saleIDs) == 0 {
+		return nil, nil
+	}
+	sales, err := helper.pb.FindRecordsByIds(models.CName[models.SalesTransactions](), saleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(sales, []string{"sales_details.product", "sales_details.sku"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to expand sale details: %v", errs)
+	}
+	sort.SliceStable(sales, func(i, j int) bool {
+		return sales[i].GetDateTime("transaction_date").Time().Before(sales[j].GetDateTime("transaction_date").Time())
+	})
+
+	var lines []InvoiceLine
+	for _, sale := range sales {
+		for _, detail := range sale.ExpandedAll("sales_details") {
+			lines = append(lines, invoiceLine(detail.ExpandedOne("product"), detail.ExpandedOne("sku"),
+				detail.GetFloat("quantity"), detail.GetFloat("unit_price")))
+		}
+		if discount := sale.GetFloat("discount_amount"); discount > 0 {
+			lines = append(lines, InvoiceLine{
+				Description: "Discount on " + documentNumber(sale),
+				Quantity:    1,
+				UnitPrice:   -discount,
+				Net:         -discount,
+				Total:       -discount,
+			})
+		}
+	}
+	return lines, nil
+}
+
+// receivedInvoiceLines lists the goods received against a purchase invoice.
+func (helper *DbHelper) receivedInvoiceLines(invoiceID string) ([]InvoiceLine, error) {
+	notes, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.GoodsReceivedNotes](),
+		"invoice = {:invoice}",
+		"date",
+		0,
+		0,
+		dbx.Params{"invoice": invoiceID},
+	)
+	if err != nil || len(notes) == 0 {
+		return nil, err
+	}
+	noteIDs := make([]any, 0, len(notes))
+	for _, note := range notes {
+		noteIDs = append(noteIDs, note.Id)
+	}
+	received, err := helper.pb.FindAllRecords(
+		models.CName[models.GoodsReceivedLines](),
+		dbx.In("goods_received_note", noteIDs...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(received, []string{"product", "sku"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to expand received lines: %v", errs)
+	}
+
+	lines := make([]InvoiceLine, 0, len(received))
+	for _, r := range received {
+		lines = append(lines, invoiceLine(r.ExpandedOne("product"), r.ExpandedOne("sku"),
+			r.GetFloat("quantity"), r.GetFloat("unit_price")))
+	}
+	return lines, nil
+}
+
+// companyLogo reads the company's logo from storage. The PDF core only
+// embeds JPEG, PNG and GIF images; other formats are left out.
+func (helper *DbHelper) companyLogo(company *models.Companies) (string, []byte) {
+	name := company.Logo()
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		return "", nil
+	}
+
+	fsys, err := helper.pb.NewFilesystem()
+	if err != nil {
+		helper.Logger.Printf("Error opening storage for logo of %s: %v", company.Id, err)
+		return "", nil
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(company.BaseFilesPath() + "/" + name)
+	if err != nil {
+		helper.Logger.Printf("Error reading logo of %s: %v", company.Id, err)
+		return "", nil
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		helper.Logger.Printf("Error reading logo of %s: %v", company.Id, err)
+		return "", nil
+	}
+	return name, data
+}
+
+// WriteInvoicePDF renders the invoice as a printable A4 document with the
+// company logo, the lines, the tax breakdown and the balance due.
+func WriteInvoicePDF(w io.Writer, doc *InvoiceDocument) error {
+	pdf := newPDFDocument()
+	if len(doc.logo) > 0 {
+		imageType := strings.TrimPrefix(strings.ToLower(filepath.Ext(doc.logoName)), ".")
+		options := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+		pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(doc.logo))
+		if pdf.Ok() {
+			pdf.ImageOptions("logo", 160, 12, 35, 0, false, options, 0, "")
+		} else {
+			// an unreadable logo should not stop the invoice going out
+			pdf.ClearError()
+		}
+	}
+	pdf.companyHeader(doc.Company)
+
+	pdf.text("B", 14, doc.Title+" "+doc.Number)
+	pdf.text("", 10, "Date: "+doc.Date.Format(time.DateOnly))
+	if !doc.DueDate.IsZero() {
+		pdf.text("", 10, "Due: "+doc.DueDate.Format(time.DateOnly))
+	}
+	if doc.Partner != nil {
+		pdf.Ln(2)
+		pdf.text("B", 10, "Bill to")
+		pdf.text("", 10, doc.Partner.Name())
+		if doc.Partner.Phone() != "" {
+			pdf.text("", 10, doc.Partner.Phone())
+		}
+	}
+	pdf.Ln(3)
+
+	rows := make([][]string, 0, len(doc.Lines))
+	for _, line := range doc.Lines {
+		rows = append(rows, []string{
+			line.Description,
+			formatQuantity(line.Quantity),
+			formatAmount(line.UnitPrice),
+			formatAmount(line.Net),
+			TaxPercent(line.TaxRate),
+			formatAmount(line.Tax),
+			formatAmount(line.Total),
+		})
+	}
+	pdf.table(
+		[]string{"Description", "Qty", "Unit Price", "Net", "Tax %", "Tax", "Total"},
+		rows,
+		[]float64{62, 16, 24, 22, 14, 20, 22},
+		map[int]bool{1: true, 2: true, 3: true, 4: true, 5: true, 6: true},
+	)
+	pdf.Ln(3)
+
+	pdf.amountRow("Subtotal", formatAmount(doc.Subtotal), false)
+	pdf.amountRow("Tax", formatAmount(doc.Tax), false)
+	pdf.amountRow("Total "+doc.Currency, formatAmount(doc.Total), true)
+	if doc.Paid != 0 {
+		pdf.amountRow("Paid", formatAmount(doc.Paid), false)
+	}
+	pdf.amountRow("Balance due "+doc.Currency, formatAmount(doc.BalanceDue), true)
+
+	if len(doc.Taxes) > 0 {
+		pdf.Ln(4)
+		pdf.text("B", 10, "Tax breakdown")
+		taxRows := make([][]string, 0, len(doc.Taxes))
+		for _, t := range doc.Taxes {
+			taxRows = append(taxRows, []string{TaxPercent(t.Rate), formatAmount(t.Taxable), formatAmount(t.Tax)})
+		}
+		pdf.table([]string{"Rate", "Taxable", "Tax"}, taxRows, []float64{20, 30, 30}, map[int]bool{1: true, 2: true})
+	}
+	if doc.PaymentTerms != "" {
+		pdf.Ln(4)
+		pdf.text("I", 9, doc.PaymentTerms)
+	}
+	return pdf.Output(w)
+}
+
+// EmailInvoice sends the invoice to the recipients with the PDF attached.
+// render produces the HTML body; the logo is embedded in the message, so
+// LogoURL points at it while rendering. The invoice is stamped as sent.
+func (helper *DbHelper) EmailInvoice(doc *InvoiceDocument, recipients []string, render func(*InvoiceDocument) (string, error)) error {
+	to := make([]mail.Address, 0, len(recipients))
+	for _, r := range recipients {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		address, err := mail.ParseAddress(r)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+		to = append(to, *address)
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	message := &mailer.Message{
+		From:    helper.mailFrom(doc.Company.Name()),
+		To:      to,
+		Subject: fmt.Sprintf("%s %s from %s", doc.Title, doc.Number, doc.Company.Name()),
+		Text: fmt.Sprintf("Please find attached %s %s dated %s. Balance due: %s %s.",
+			strings.ToLower(doc.Title), doc.Number, doc.Date.Format(time.DateOnly), doc.Currency, formatAmount(doc.BalanceDue)),
+		Attachments: map[string]io.Reader{},
+	}
+
+	pdf := new(bytes.Buffer)
+	if err := WriteInvoicePDF(pdf, doc); err != nil {
+		return fmt.Errorf("failed to write invoice PDF: %w", err)
+	}
+	message.Attachments[doc.Number+".pdf"] = pdf
+
+	logoURL := doc.LogoURL
+	if len(doc.logo) > 0 {
+		doc.LogoURL = "cid:" + doc.logoName
+		message.InlineAttachments = map[string]io.Reader{doc.logoName: bytes.NewReader(doc.logo)}
+	} else {
+		doc.LogoURL = ""
+	}
+	html, err := render(doc)
+	doc.LogoURL = logoURL
+	if err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	message.HTML = html
+
+	if err := helper.Mailer().Send(message); err != nil {
+		return fmt.Errorf("failed to send invoice: %w", err)
+	}
+
+	record, err := helper.pb.FindRecordById(models.CName[models.Invoices](), doc.Invoice.Id)
+	if err != nil {
+		return err
+	}
+	record.Set("sent_at", types.NowDateTime())
+	return helper.pb.Save(record)
+}
@@ -0,0 +1,476 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// BindInvoiceLifecycle registers the record hooks that keep invoice balances,
+// statuses and partner balances derived from the linked payments.
+//
+// An invoice's bal is its amount less the payment transactions linked to it
+// and its status follows the balance. Editing or deleting a payment updates
+// every invoice it is linked to.
+func (helper *DbHelper) BindInvoiceLifecycle() {
+	invoices := models.CName[models.Invoices]()
+
+	helper.pb.OnRecordCreate(invoices).BindFunc(func(e *core.RecordEvent) error {
+		if err := recomputeInvoice(e.App, e.Record); err != nil {
+			return err
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("partner"))
+	})
+
+	helper.pb.OnRecordUpdate(invoices).BindFunc(func(e *core.RecordEvent) error {
+		if err := recomputeInvoice(e.App, e.Record); err != nil {
+			return err
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if previous := e.Record.Original().GetString("partner"); previous != e.Record.GetString("partner") {
+			if err := recomputePartnerBalance(e.App, previous); err != nil {
+				return err
+			}
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("partner"))
+	})
+
+	helper.pb.OnRecordDelete(invoices).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("partner"))
+	})
+
+	transactions := models.CName[models.Transactions]()
+
+	// an edited payment may now settle more or less of its invoices
+	helper.pb.OnRecordUpdate(transactions).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		linked, err := linkedInvoices(e.App, e.Record.Id)
+		if err != nil {
+			return err
+		}
+		for _, invoice := range linked {
+			if err := e.App.Save(invoice); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// a deleted payment is unlinked from its invoices, which are then
+	// recomputed without it
+	helper.pb.OnRecordDelete(transactions).BindFunc(func(e *core.RecordEvent) error {
+		linked, err := linkedInvoices(e.App, e.Record.Id)
+		if err != nil {
+			return err
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		for _, invoice := range linked {
+			// the relation cleanup may already have saved the invoice
+			invoice, err := e.App.FindRecordById(invoices, invoice.Id)
+			if err != nil {
+				return err
+			}
+			invoice.Set("transactions", slices.DeleteFunc(invoice.GetStringSlice("transactions"), func(id string) bool {
+				return id == e.Record.Id
+			}))
+			if err := e.App.Save(invoice); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// linkedInvoices returns the invoices a payment transaction is linked to.
+func linkedInvoices(app core.App, transactionID string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"transactions ~ {:id}",
+		"",
+		0,
+		0,
+		dbx.Params{"id": transactionID},
+	)
+}
+
+// recomputeInvoice derives bal and status from the linked payments. Payments
+// count in the invoice currency; a payment in another currency is converted
+// through its base-currency amount.
+func recomputeInvoice(app core.App, invoice *core.Record) error {
+	var paid float64
+	if ids := invoice.GetStringSlice("transactions"); len(ids) > 0 {
+		payments, err := app.FindRecordsByIds(models.CName[models.Transactions](), ids)
+		if err != nil {
+			return err
+		}
+		for _, payment := range payments {
+			paid += invoicePaidAmount(invoice, payment)
+		}
+	}
+
+	bal := max(roundMoney(invoice.GetFloat("amount")-paid), 0)
+	invoice.Set("bal", bal)
+	switch {
+	case bal == 0:
+		invoice.Set("status", "paid")
+	case paid > 0:
+		invoice.Set("status", "partial")
+	default:
+		invoice.Set("status", "pending")
+	}
+	return nil
+}
+
+func invoicePaidAmount(invoice, payment *core.Record) float64 {
+	currency := payment.GetString("currency")
+	if currency == "" || currency == invoice.GetString("currency") {
+		if original := payment.GetFloat("original_amount"); original != 0 {
+			return original
+		}
+		return payment.GetFloat("amount")
+	}
+	rate := invoice.GetFloat("exchange_rate")
+	if rate <= 0 {
+		rate = 1
+	}
+	return payment.GetFloat("amount") / rate
+}
+
+// recomputePartnerBalance sets the partner's balance, in the base currency,
+// to what the partner owes the company: open sale invoices and sales on
+// credit less open purchase invoices less the partner's unapplied credit.
+// A sale billed on an invoice is owed through the invoice.
+func recomputePartnerBalance(app core.App, partnerID string) error {
+	if partnerID == "" {
+		return nil
+	}
+	partner, err := app.FindRecordById(models.CName[models.Partners](), partnerID)
+	if err != nil {
+		return err
+	}
+	open, err := app.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"partner = {:partner} && bal > 0",
+		"",
+		0,
+		0,
+		dbx.Params{"partner": partnerID},
+	)
+	if err != nil {
+		return err
+	}
+
+	balance := -partner.GetFloat("credit")
+	for _, invoice := range open {
+		if invoice.GetString("type") == "purchase" {
+			balance -= BaseAmount(invoice, "bal")
+		} else {
+			balance += BaseAmount(invoice, "bal")
+		}
+	}
+	sales, err := app.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"customer = {:partner} && remaining_balance > 0",
+		"",
+		0,
+		0,
+		dbx.Params{"partner": partnerID},
+	)
+	if err != nil {
+		return err
+	}
+	billed, err := billedSales(app, partner.GetString("company"), partnerID, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, sale := range sales {
+		if billed[sale.Id] == nil {
+			balance += BaseAmount(sale, "remaining_balance")
+		}
+	}
+	partner.Set("balance", roundMoney(balance))
+	return app.Save(partner)
+}
+
+// billedSales maps the sales billed on the company's sale invoices to the
+// invoice billing them. From the invoice date the invoice carries what the
+// sale left unpaid, and payments against the invoice settle it. A partnerID
+// limits it to the partner's invoices and a non-zero before to invoices
+// dated before it.
+func billedSales(app core.App, companyID, partnerID string, before time.Time) (map[string]*core.Record, error) {
+	filter := "company = {:company} && type = 'sale' && sales:length > 0"
+	params := dbx.Params{"company": companyID}
+	if partnerID != "" {
+		filter += " && partner = {:partner}"
+		params["partner"] = partnerID
+	}
+	if !before.IsZero() {
+		filter += " && date < {:before}"
+		params["before"] = formatDate(before)
+	}
+	invoices, err := app.FindRecordsByFilter(models.CName[models.Invoices](), filter, "date", 0, 0, params)
+	if err != nil {
+		return nil, err
+	}
+	billed := map[string]*core.Record{}
+	for _, invoice := range invoices {
+		for _, saleID := range invoice.GetStringSlice("sales") {
+			if billed[saleID] == nil {
+				billed[saleID] = invoice
+			}
+		}
+	}
+	return billed, nil
+}
+
+// PaymentAllocation applies part of a payment to one invoice, in the
+// invoice currency.
+type PaymentAllocation struct {
+	InvoiceID string  `json:"invoice"`
+	Amount    float64 `json:"amount"`
+}
+
+// PaymentRequest records money received from a customer (sale invoices) or
+// paid to a supplier (purchase invoices) through one company account.
+//
+// Without Allocations the payment settles the partner's oldest open invoices
+// first. Whatever is left after the allocations becomes partner credit.
+type PaymentRequest struct {
+	CompanyID   string
+	PartnerID   string
+	AccountID   string
+	InvoiceType models.TypeSelectType
+	Amount      float64
+	Date        types.DateTime
+	AuthorID    string
+	Allocations []PaymentAllocation
+}
+
+// Payment holds the records written for one payment. Every transaction
+// shares Reference as its transaction_id.
+type Payment struct {
+	Reference    string                 `json:"reference"`
+	Transactions []*models.Transactions `json:"transactions"`
+	Invoices     []*models.Invoices     `json:"invoices"`
+	Credit       *models.Transactions   `json:"credit,omitempty"`
+}
+
+// AllocatePayment atomically books a payment against one or more invoices,
+// splitting it into one transaction per invoice plus one for any overpayment,
+// and updates the account balance, the invoices and the partner.
+func (helper *DbHelper) AllocatePayment(req PaymentRequest) (*Payment, error) {
+	req.Amount = roundMoney(req.Amount)
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("payment amount must be positive")
+	}
+	if req.Date.IsZero() {
+		req.Date = types.NowDateTime()
+	}
+
+	payment := &Payment{Reference: newDocumentReference("PAY")}
+
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		account, err := findCompanyAccount(txApp, req.CompanyID, req.AccountID)
+		if err != nil {
+			return err
+		}
+		partner, err := txApp.FindRecordById(models.CName[models.Partners](), req.PartnerID)
+		if err != nil {
+			return fmt.Errorf("partner %s not found: %w", req.PartnerID, err)
+		}
+		if partner.GetString("company") != req.CompanyID {
+			return fmt.Errorf("partner %s does not belong to the company", req.PartnerID)
+		}
+		currency := account.Currency()
+		if currency == "" {
+			if currency, err = companyBaseCurrency(txApp, req.CompanyID); err != nil {
+				return err
+			}
+		}
+
+		allocations, err := resolveAllocations(txApp, req, currency)
+		if err != nil {
+			return err
+		}
+
+		side, referenceType := models.Debit, models.Sale2
+		if req.InvoiceType == models.Purchase {
+			side, referenceType = models.Credit, models.Purchase2
+		}
+
+		remaining := req.Amount
+		for _, allocation := range allocations {
+			tx, err := savePaymentTransaction(txApp, req, account, side, referenceType, allocation.amount, allocation.invoice.Id, payment.Reference)
+			if err != nil {
+				return err
+			}
+			allocation.invoice.Set("transactions", append(allocation.invoice.GetStringSlice("transactions"), tx.Id))
+			if err := txApp.Save(allocation.invoice); err != nil {
+				return err
+			}
+			invoice, err := models.WrapRecord[models.Invoices](allocation.invoice)
+			if err != nil {
+				return err
+			}
+			payment.Transactions = append(payment.Transactions, tx)
+			payment.Invoices = append(payment.Invoices, invoice)
+			remaining = roundMoney(remaining - allocation.amount)
+		}
+
+		if remaining > 0 {
+			payment.Credit, err = savePaymentTransaction(txApp, req, account, side, referenceType, remaining, partner.Id, payment.Reference)
+			if err != nil {
+				return err
+			}
+			// credit is what the company owes the partner, so a supplier
+			// overpayment is negative credit
+			credit := payment.Credit.Amount()
+			if req.InvoiceType == models.Purchase {
+				credit = -credit
+			}
+			partner.Set("credit", roundMoney(partner.GetFloat("credit")+credit))
+			if err := txApp.Save(partner); err != nil {
+				return err
+			}
+			if err := recomputePartnerBalance(txApp, partner.Id); err != nil {
+				return err
+			}
+		}
+
+		if side == models.Debit {
+			account.SetBal(roundMoney(account.Bal() + req.Amount))
+		} else {
+			account.SetBal(roundMoney(account.Bal() - req.Amount))
+		}
+		return txApp.Save(account)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+type invoiceAllocation struct {
+	invoice *core.Record
+	amount  float64
+}
+
+// resolveAllocations validates the requested allocations or, without any,
+// spreads the payment over the partner's open invoices oldest first.
+func resolveAllocations(app core.App, req PaymentRequest, currency string) ([]invoiceAllocation, error) {
+	invoiceType := "sale"
+	if req.InvoiceType == models.Purchase {
+		invoiceType = "purchase"
+	}
+
+	if len(req.Allocations) == 0 {
+		open, err := app.FindRecordsByFilter(
+			models.CName[models.Invoices](),
+			"company = {:company} && partner = {:partner} && type = {:type} && currency = {:currency} && bal > 0",
+			"date,created",
+			0,
+			0,
+			dbx.Params{"company": req.CompanyID, "partner": req.PartnerID, "type": invoiceType, "currency": currency},
+		)
+		if err != nil {
+			return nil, err
+		}
+		var allocations []invoiceAllocation
+		remaining := req.Amount
+		for _, invoice := range open {
+			if remaining <= 0 {
+				break
+			}
+			amount := min(remaining, invoice.GetFloat("bal"))
+			allocations = append(allocations, invoiceAllocation{invoice: invoice, amount: amount})
+			remaining = roundMoney(remaining - amount)
+		}
+		return allocations, nil
+	}
+
+	allocations := make([]invoiceAllocation, 0, len(req.Allocations))
+	var seen []string
+	var total float64
+	for _, a := range req.Allocations {
+		amount := roundMoney(a.Amount)
+		if amount <= 0 {
+			return nil, fmt.Errorf("allocation to invoice %s must be positive", a.InvoiceID)
+		}
+		if slices.Contains(seen, a.InvoiceID) {
+			return nil, fmt.Errorf("invoice %s is allocated more than once", a.InvoiceID)
+		}
+		seen = append(seen, a.InvoiceID)
+
+		invoice, err := app.FindRecordById(models.CName[models.Invoices](), a.InvoiceID)
+		if err != nil {
+			return nil, fmt.Errorf("invoice %s not found: %w", a.InvoiceID, err)
+		}
+		switch {
+		case invoice.GetString("company") != req.CompanyID || invoice.GetString("partner") != req.PartnerID:
+			return nil, fmt.Errorf("invoice %s does not belong to the partner", a.InvoiceID)
+		case invoice.GetString("type") != invoiceType:
+			return nil, fmt.Errorf("invoice %s is not a %s invoice", a.InvoiceID, invoiceType)
+		case invoice.GetString("currency") != currency:
+			return nil, fmt.Errorf("invoice %s is in %s, the account in %s", a.InvoiceID, invoice.GetString("currency"), currency)
+		case amount > invoice.GetFloat("bal"):
+			return nil, fmt.Errorf("allocation of %.2f exceeds the %.2f due on invoice %s", amount, invoice.GetFloat("bal"), a.InvoiceID)
+		}
+		allocations = append(allocations, invoiceAllocation{invoice: invoice, amount: amount})
+		total += amount
+	}
+	if roundMoney(total) > req.Amount {
+		return nil, fmt.Errorf("allocations of %.2f exceed the payment of %.2f", total, req.Amount)
+	}
+	return allocations, nil
+}
+
+func savePaymentTransaction(app core.App, req PaymentRequest, account *models.CompanyAccounts, side models.TypeSelectType2, referenceType models.ReferenceTypeSelectType, amount float64, referenceID, reference string) (*models.Transactions, error) {
+	tx, err := models.NewProxy[models.Transactions](app)
+	if err != nil {
+		return nil, err
+	}
+	tx.Set("company", req.CompanyID)
+	tx.SetAccount(account)
+	tx.SetType(side)
+	tx.SetAmount(amount)
+	tx.SetTransactionId(reference)
+	tx.SetDate(req.Date)
+	tx.Set("author", req.AuthorID)
+	tx.SetReferenceType(referenceType)
+	tx.SetReferenceId(referenceID)
+	if err := app.Save(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// RecomputeInvoice re-derives an invoice's balance and status from its
+// payments, e.g. after importing payments with hooks disabled.
+func (helper *DbHelper) RecomputeInvoice(invoiceID string) (*models.Invoices, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.Invoices](), invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	// the invoice hooks recompute on save
+	if err := helper.pb.Save(record); err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.Invoices](record)
+}
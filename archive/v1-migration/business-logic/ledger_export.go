@@ -0,0 +1,500 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// LedgerFormat is one of the supported general-ledger export formats.
+type LedgerFormat string
+
+const (
+	LedgerFormatJournal    LedgerFormat = "journal"
+	LedgerFormatQuickBooks LedgerFormat = "iif"
+	LedgerFormatXero       LedgerFormat = "xero"
+)
+
+// System returns the mapping system whose external chart of accounts the
+// format uses.
+func (f LedgerFormat) System() string {
+	switch f {
+	case LedgerFormatQuickBooks:
+		return "quickbooks"
+	case LedgerFormatXero:
+		return "xero"
+	default:
+		return "generic"
+	}
+}
+
+// FileName returns the download name of an export for the given range.
+func (f LedgerFormat) FileName(r DateRange) string {
+	ext := "csv"
+	if f == LedgerFormatQuickBooks {
+		ext = "iif"
+	}
+	last := r.To.AddDate(0, 0, -1)
+	return fmt.Sprintf("ledger-%s-%s-%s.%s", f, r.From.Format("20060102"), last.Format("20060102"), ext)
+}
+
+// JournalEntry is one balanced journal built from a single transaction.
+type JournalEntry struct {
+	ID        string
+	Date      time.Time
+	Reference string
+	Narration string
+	Lines     []JournalLine
+}
+
+type JournalLine struct {
+	AccountCode string
+	AccountName string
+	TaxCode     string
+	Debit       float64
+	Credit      float64
+}
+
+// ExternalAccount is an account in the chart of accounts of the external
+// accounting software.
+type ExternalAccount struct {
+	Code    string
+	Name    string
+	TaxCode string
+}
+
+// ledgerMappings resolves company accounts, account types and contra
+// (reference) types to external accounts for one system, falling back to the
+// generic mapping and finally to the Dukahub names.
+type ledgerMappings struct {
+	system    string
+	byAccount map[string]map[string]ExternalAccount
+	byType    map[string]map[string]ExternalAccount
+	byContra  map[string]map[string]ExternalAccount
+}
+
+func (m *ledgerMappings) lookup(table map[string]map[string]ExternalAccount, key string) (ExternalAccount, bool) {
+	for _, system := range []string{m.system, "generic"} {
+		if ext, ok := table[system][key]; ok && key != "" {
+			return ext, true
+		}
+	}
+	return ExternalAccount{}, false
+}
+
+func (m *ledgerMappings) account(account *core.Record) ExternalAccount {
+	if account == nil {
+		return ExternalAccount{Name: "Unassigned"}
+	}
+	if ext, ok := m.lookup(m.byAccount, account.Id); ok {
+		return ext
+	}
+	if ext, ok := m.lookup(m.byType, account.GetString("type")); ok {
+		return ext
+	}
+	return ExternalAccount{Code: account.GetString("account_number"), Name: account.GetString("name")}
+}
+
+func (m *ledgerMappings) contra(referenceType string) ExternalAccount {
+	if ext, ok := m.lookup(m.byContra, referenceType); ok {
+		return ext
+	}
+	return ExternalAccount{Name: contraAccountNames[referenceType]}
+}
+
+var contraAccountNames = map[string]string{
+	"sale":       "Sales",
+	"purchase":   "Purchases",
+	"expense":    "Expenses",
+	"adjustment": "Adjustments",
+	"tax":        "Tax Payable",
+	"transfer":   "Transfer Clearing",
+	"":           "Suspense",
+}
+
+func (helper *DbHelper) fetchLedgerMappings(companyID, system string) (*ledgerMappings, error) {
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.LedgerAccountMappings](),
+		"company = {:company}",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ledgerMappings{
+		system:    system,
+		byAccount: map[string]map[string]ExternalAccount{},
+		byType:    map[string]map[string]ExternalAccount{},
+		byContra:  map[string]map[string]ExternalAccount{},
+	}
+	for _, record := range records {
+		ext := ExternalAccount{
+			Code:    record.GetString("external_code"),
+			Name:    record.GetString("external_name"),
+			TaxCode: record.GetString("tax_code"),
+		}
+		recordSystem := record.GetString("system")
+		var table map[string]map[string]ExternalAccount
+		var key string
+		switch {
+		case record.GetString("company_account") != "":
+			table, key = m.byAccount, record.GetString("company_account")
+		case record.GetString("account_type") != "":
+			table, key = m.byType, record.GetString("account_type")
+		case record.GetString("reference_type") != "":
+			table, key = m.byContra, record.GetString("reference_type")
+		default:
+			continue
+		}
+		if table[recordSystem] == nil {
+			table[recordSystem] = map[string]ExternalAccount{}
+		}
+		table[recordSystem][key] = ext
+	}
+	return m, nil
+}
+
+// LedgerMappingSettings map one company account, account type or contra
+// (reference) type to an account of the external chart of accounts, for one
+// system (generic, quickbooks or xero).
+type LedgerMappingSettings struct {
+	System         string `json:"system"`
+	CompanyAccount string `json:"company_account"`
+	AccountType    string `json:"account_type"`
+	ReferenceType  string `json:"reference_type"`
+	ExternalCode   string `json:"external_code"`
+	ExternalName   string `json:"external_name"`
+	TaxCode        string `json:"tax_code"`
+}
+
+// SaveLedgerMapping creates a ledger account mapping, or changes it when
+// mappingID is set. Only managers may change the mappings.
+func (helper *DbHelper) SaveLedgerMapping(companyID, userID, mappingID string, settings LedgerMappingSettings) (*models.LedgerAccountMappings, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	switch settings.System {
+	case "generic", "quickbooks", "xero":
+	default:
+		return nil, fmt.Errorf("unknown ledger system %q, expected generic, quickbooks or xero", settings.System)
+	}
+	targets := 0
+	for _, target := range []string{settings.CompanyAccount, settings.AccountType, settings.ReferenceType} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, fmt.Errorf("a mapping applies to one company account, account type or reference type")
+	}
+	if settings.CompanyAccount != "" {
+		account, err := helper.pb.FindRecordById(models.CName[models.CompanyAccounts](), settings.CompanyAccount)
+		if err != nil || account.GetString("company") != companyID {
+			return nil, fmt.Errorf("company account %s not found", settings.CompanyAccount)
+		}
+	}
+	if settings.AccountType != "" {
+		if _, err := helper.pb.FindRecordById(models.CName[models.AccountTypes](), settings.AccountType); err != nil {
+			return nil, fmt.Errorf("account type %s not found", settings.AccountType)
+		}
+	}
+	if _, ok := contraAccountNames[settings.ReferenceType]; !ok {
+		return nil, fmt.Errorf("unknown reference type %q", settings.ReferenceType)
+	}
+	settings.ExternalCode = strings.TrimSpace(settings.ExternalCode)
+	settings.ExternalName = strings.TrimSpace(settings.ExternalName)
+	if settings.ExternalCode == "" && settings.ExternalName == "" {
+		return nil, fmt.Errorf("an external account code or name is required")
+	}
+
+	var record *core.Record
+	if mappingID != "" {
+		existing, err := helper.pb.FindRecordById(models.CName[models.LedgerAccountMappings](), mappingID)
+		if err != nil || existing.GetString("company") != companyID {
+			return nil, fmt.Errorf("ledger mapping %s not found", mappingID)
+		}
+		record = existing
+	} else {
+		proxy, err := models.NewProxy[models.LedgerAccountMappings](helper.pb)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Set("company", companyID)
+		record = proxy.Record
+	}
+	record.Set("system", settings.System)
+	record.Set("company_account", settings.CompanyAccount)
+	record.Set("account_type", settings.AccountType)
+	record.Set("reference_type", settings.ReferenceType)
+	record.Set("external_code", settings.ExternalCode)
+	record.Set("external_name", settings.ExternalName)
+	record.Set("tax_code", strings.TrimSpace(settings.TaxCode))
+	if err := helper.pb.Save(record); err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.LedgerAccountMappings](record)
+}
+
+// DeleteLedgerMapping removes a ledger account mapping; exports then fall
+// back to the generic mapping or the Dukahub names.
+func (helper *DbHelper) DeleteLedgerMapping(companyID, userID, mappingID string) error {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return err
+	}
+	record, err := helper.pb.FindRecordById(models.CName[models.LedgerAccountMappings](), mappingID)
+	if err != nil || record.GetString("company") != companyID {
+		return fmt.Errorf("ledger mapping %s not found", mappingID)
+	}
+	return helper.pb.Delete(record)
+}
+
+// FetchLedgerMappings returns all of the company's ledger account mappings.
+func (helper *DbHelper) FetchLedgerMappings(companyID string) ([]*models.LedgerAccountMappings, error) {
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.LedgerAccountMappings](),
+		"company = {:company}",
+		"system,created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	mappings := make([]*models.LedgerAccountMappings, len(records))
+	for i, record := range records {
+		mapping, err := models.WrapRecord[models.LedgerAccountMappings](record)
+		if err != nil {
+			return nil, err
+		}
+		mappings[i] = mapping
+	}
+	return mappings, nil
+}
+
+// BuildJournal turns the company's transactions in the range into balanced
+// journal entries using the external chart of accounts of the format.
+//
+// Each transaction debits or credits its company account; the opposite side
+// goes to the contra account of its reference type, with any tax split out
+// to the "tax" contra account. Both sides of a transfer go through the
+// transfer clearing account, which nets to zero.
+func (helper *DbHelper) BuildJournal(companyID string, r DateRange, format LedgerFormat) ([]JournalEntry, error) {
+	mappings, err := helper.fetchLedgerMappings(companyID, format.System())
+	if err != nil {
+		return nil, err
+	}
+
+	params := r.Params()
+	params["company"] = companyID
+	transactions, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Transactions](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(transactions, []string{"account"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to expand transaction accounts: %v", errs)
+	}
+
+	entries := make([]JournalEntry, 0, len(transactions))
+	for _, tx := range transactions {
+		amount := roundMoney(tx.GetFloat("amount"))
+		if amount == 0 {
+			continue
+		}
+		tax := roundMoney(tx.GetFloat("tax_amount"))
+		referenceType := tx.GetString("reference_type")
+
+		reference := tx.GetString("transaction_id")
+		if reference == "" {
+			reference = tx.Id
+		}
+		entry := JournalEntry{
+			ID:        tx.Id,
+			Date:      tx.GetDateTime("date").Time(),
+			Reference: reference,
+			Narration: strings.TrimSpace(fmt.Sprintf("%s %s", referenceType, tx.GetString("reference_id"))),
+		}
+
+		// the transaction type is the side booked against the company account
+		debitAccount := tx.GetString("type") == "debit"
+		entry.Lines = append(entry.Lines, journalLine(mappings.account(tx.ExpandedOne("account")), amount, debitAccount))
+		entry.Lines = append(entry.Lines, journalLine(mappings.contra(referenceType), amount-tax, !debitAccount))
+		if tax != 0 {
+			entry.Lines = append(entry.Lines, journalLine(mappings.contra("tax"), tax, !debitAccount))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func journalLine(account ExternalAccount, amount float64, debit bool) JournalLine {
+	line := JournalLine{AccountCode: account.Code, AccountName: account.Name, TaxCode: account.TaxCode}
+	if debit {
+		line.Debit = amount
+	} else {
+		line.Credit = amount
+	}
+	return line
+}
+
+// ExportLedger renders the company's journal for the range in the format.
+func (helper *DbHelper) ExportLedger(companyID string, r DateRange, format LedgerFormat) (*bytes.Buffer, error) {
+	entries, err := helper.BuildJournal(companyID, r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	switch format {
+	case LedgerFormatJournal:
+		err = WriteJournalCSV(buf, entries)
+	case LedgerFormatQuickBooks:
+		err = WriteQuickBooksIIF(buf, entries)
+	case LedgerFormatXero:
+		err = WriteXeroJournalCSV(buf, entries)
+	default:
+		err = fmt.Errorf("unknown ledger format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// WriteJournalCSV writes one row per journal line in a generic layout most
+// accounting packages can map on import.
+func WriteJournalCSV(w io.Writer, entries []JournalEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Date", "Journal", "Reference", "Narration", "Account Code", "Account Name", "Debit", "Credit"})
+	for _, entry := range entries {
+		for _, line := range entry.Lines {
+			cw.Write([]string{
+				entry.Date.Format(time.DateOnly),
+				entry.ID,
+				entry.Reference,
+				entry.Narration,
+				line.AccountCode,
+				line.AccountName,
+				formatOptionalAmount(line.Debit),
+				formatOptionalAmount(line.Credit),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteQuickBooksIIF writes general journal transactions in QuickBooks
+// Desktop IIF format. QuickBooks matches accounts by name, debits are
+// positive and credits negative.
+func WriteQuickBooksIIF(w io.Writer, entries []JournalEntry) error {
+	rows := [][]string{
+		{"!TRNS", "TRNSID", "TRNSTYPE", "DATE", "ACCNT", "AMOUNT", "DOCNUM", "MEMO"},
+		{"!SPL", "SPLID", "TRNSTYPE", "DATE", "ACCNT", "AMOUNT", "DOCNUM", "MEMO"},
+		{"!ENDTRNS"},
+	}
+	for _, entry := range entries {
+		for i, line := range entry.Lines {
+			kind := "SPL"
+			if i == 0 {
+				kind = "TRNS"
+			}
+			account := line.AccountName
+			if account == "" {
+				account = line.AccountCode
+			}
+			rows = append(rows, []string{
+				kind,
+				"",
+				"GENERAL JOURNAL",
+				entry.Date.Format("01/02/2006"),
+				iifField(account),
+				formatAmount(line.Debit - line.Credit),
+				iifField(entry.Reference),
+				iifField(entry.Narration),
+			})
+		}
+		rows = append(rows, []string{"ENDTRNS"})
+	}
+	for _, row := range rows {
+		if _, err := io.WriteString(w, strings.Join(row, "\t")+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iifField strips the characters that would break the tab-separated layout.
+func iifField(s string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", `"`, "'").Replace(s)
+}
+
+// WriteXeroJournalCSV writes the Xero manual journal import template. Xero
+// matches accounts by code; debits are positive and credits negative.
+func WriteXeroJournalCSV(w io.Writer, entries []JournalEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount", "TrackingName1", "TrackingOption1", "TrackingName2", "TrackingOption2"})
+	for _, entry := range entries {
+		narration := entry.Reference
+		if entry.Narration != "" {
+			narration = fmt.Sprintf("%s (%s)", entry.Narration, entry.Reference)
+		}
+		for _, line := range entry.Lines {
+			code := line.AccountCode
+			if code == "" {
+				code = line.AccountName
+			}
+			taxRate := line.TaxCode
+			if taxRate == "" {
+				taxRate = "Tax Exempt"
+			}
+			cw.Write([]string{
+				narration,
+				entry.Date.Format("02/01/2006"),
+				line.AccountName,
+				code,
+				taxRate,
+				formatAmount(line.Debit - line.Credit),
+				"", "", "", "",
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatAmount(v float64) string {
+	return fmt.Sprintf("%.2f", roundMoney(v))
+}
+
+// formatOptionalAmount leaves zero amounts blank, as in a paper journal.
+func formatOptionalAmount(v float64) string {
+	if roundMoney(v) == 0 {
+		return ""
+	}
+	return formatAmount(v)
+}
+
+// roundMoney rounds to whole cents to keep float sums from drifting.
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
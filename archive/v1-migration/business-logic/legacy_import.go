@@ -0,0 +1,664 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// legacyImportReference is the reference type of the stock movements an
+// import creates, and legacyImportNote the note on its records, so that a
+// row is not imported twice.
+const (
+	legacyImportReference = "legacy_import"
+	legacyImportNote      = "Imported from the legacy daily log"
+)
+
+// The reference IDs of imported stock movements: the inventory log column
+// they came from.
+const (
+	legacyReceived  = "received"
+	legacyRetail    = "retail"
+	legacyWholesale = "wholesale"
+)
+
+// errDryRun rolls back a dry-run import once every row has been tried.
+var errDryRun = errors.New("dry run")
+
+// LegacyImportOptions says which company the legacy logs belong to and who
+// is recorded as having entered them, by ID or email. The accounts of the cash and M-Pesa
+// columns are found by type or name unless given, and products the import
+// creates get SkuID, or the SKU of the company's first product.
+type LegacyImportOptions struct {
+	CompanyID      string
+	UserID         string
+	CashAccountID  string
+	MpesaAccountID string
+	SkuID          string
+	DryRun         bool
+}
+
+// LegacyRowError is why a row of a legacy log was not imported.
+type LegacyRowError struct {
+	Sheet   string `json:"sheet"`
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// LegacyImportResult counts the rows of a workbook and the records they
+// created, or would create in a dry run, and lists the rows left out.
+type LegacyImportResult struct {
+	File          string           `json:"file"`
+	DryRun        bool             `json:"dry_run"`
+	Rows          int              `json:"rows"`
+	Imported      int              `json:"imported"`
+	Products      int              `json:"products"`
+	DailyAccounts int              `json:"daily_accounts"`
+	StockTakes    int              `json:"stock_takes"`
+	Sales         int              `json:"sales"`
+	Purchases     int              `json:"purchases"`
+	Movements     int              `json:"movements"`
+	Errors        []LegacyRowError `json:"errors"`
+}
+
+func (r *LegacyImportResult) add(counts LegacyImportResult) {
+	r.Imported++
+	r.Products += counts.Products
+	r.DailyAccounts += counts.DailyAccounts
+	r.StockTakes += counts.StockTakes
+	r.Sales += counts.Sales
+	r.Purchases += counts.Purchases
+	r.Movements += counts.Movements
+}
+
+// ImportLegacyLog imports every sheet of a workbook laid out like the
+// bookkeepers' daily financial or inventory logs. Financial rows become
+// daily_accounts of the cash and M-Pesa accounts and a sale per payment
+// method; inventory rows become daily_stock_takes, purchases of what was
+// received and stock movements of what was sold, creating missing products.
+//
+// Rows that cannot be imported are reported and left out; the rest are
+// saved together. A dry run tries every row the same way and then rolls
+// everything back.
+func (helper *DbHelper) ImportLegacyLog(name string, r io.ReaderAt, size int64, opts LegacyImportOptions) (*LegacyImportResult, error) {
+	sheets, err := readXLSX(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LegacyImportResult{File: name, DryRun: opts.DryRun, Errors: []LegacyRowError{}}
+	err = helper.pb.RunInTransaction(func(txApp core.App) error {
+		imp, err := newLegacyImporter(txApp, opts)
+		if err != nil {
+			return err
+		}
+		for _, sheet := range sheets {
+			if err := imp.importSheet(sheet, result); err != nil {
+				return err
+			}
+		}
+		if err := imp.updateStock(); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+type legacyImporter struct {
+	app        core.App
+	opts       LegacyImportOptions
+	accounts   map[string]string
+	products   map[string]*core.Record
+	defaultSku string
+
+	// the last counted stock of the products the import created, which
+	// becomes their stock on hand
+	created map[string]*models.Inventory
+	counted map[string]time.Time
+}
+
+func newLegacyImporter(app core.App, opts LegacyImportOptions) (*legacyImporter, error) {
+	if _, err := app.FindRecordById(models.CName[models.Companies](), opts.CompanyID); err != nil {
+		return nil, fmt.Errorf("company %s not found: %w", opts.CompanyID, err)
+	}
+	user, err := app.FindRecordById(models.CName[models.Users](), opts.UserID)
+	if err != nil {
+		if user, err = app.FindAuthRecordByEmail(models.CName[models.Users](), opts.UserID); err != nil {
+			return nil, fmt.Errorf("user %s not found: %w", opts.UserID, err)
+		}
+	}
+	if !slices.Contains(user.GetStringSlice("company"), opts.CompanyID) {
+		return nil, fmt.Errorf("user %s is not a member of company %s", opts.UserID, opts.CompanyID)
+	}
+	opts.UserID = user.Id
+
+	imp := &legacyImporter{
+		app:        app,
+		opts:       opts,
+		accounts:   map[string]string{},
+		products:   map[string]*core.Record{},
+		defaultSku: opts.SkuID,
+		created:    map[string]*models.Inventory{},
+		counted:    map[string]time.Time{},
+	}
+
+	accounts, err := app.FindRecordsByFilter(
+		models.CName[models.CompanyAccounts](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": opts.CompanyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := app.ExpandRecords(accounts, []string{"type"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load account types: %v", errs)
+	}
+	for _, account := range accounts {
+		if column := logAccountColumn(account); column != "" && imp.accounts[column] == "" {
+			imp.accounts[column] = account.Id
+		}
+	}
+	for column, id := range map[string]string{logAccountCash: opts.CashAccountID, logAccountMpesa: opts.MpesaAccountID} {
+		if id == "" {
+			continue
+		}
+		if !slices.ContainsFunc(accounts, func(a *core.Record) bool { return a.Id == id }) {
+			return nil, fmt.Errorf("account %s is not an account of company %s", id, opts.CompanyID)
+		}
+		imp.accounts[column] = id
+	}
+
+	products, err := app.FindRecordsByFilter(
+		models.CName[models.Products](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": opts.CompanyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		key := legacyKey(product.GetString("name"))
+		if imp.products[key] == nil {
+			imp.products[key] = product
+		}
+		if skus := product.GetStringSlice("skus"); imp.defaultSku == "" && len(skus) > 0 {
+			imp.defaultSku = skus[0]
+		}
+	}
+	return imp, nil
+}
+
+// legacyKey normalises a product name or header for matching.
+func legacyKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// importSheet imports the rows of a sheet whose header row matches one of
+// the logs. Other sheets are reported and skipped.
+func (imp *legacyImporter) importSheet(sheet xlsxReadSheet, result *LegacyImportResult) error {
+	if len(sheet.Rows) == 0 {
+		return nil
+	}
+	header := sheet.Rows[0]
+	columns := map[string]int{}
+	for i, cell := range header.Cells {
+		if s, ok := cell.(string); ok {
+			columns[legacyKey(s)] = i
+		}
+	}
+	matches := func(want []string) bool {
+		for _, h := range want {
+			if _, ok := columns[legacyKey(h)]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	var importRow func(row legacyRow, counts *LegacyImportResult) error
+	switch {
+	case matches(financialLogHeader):
+		importRow = imp.importFinancialRow
+	case matches(inventoryLogHeader):
+		importRow = imp.importInventoryRow
+	default:
+		result.Errors = append(result.Errors, LegacyRowError{
+			Sheet:   sheet.Name,
+			Row:     header.Number,
+			Message: "the header row matches neither the daily financial nor the inventory log",
+		})
+		return nil
+	}
+
+	for _, row := range sheet.Rows[1:] {
+		if !slices.ContainsFunc(row.Cells, func(v any) bool { return v != nil && v != "" }) {
+			continue
+		}
+		result.Rows++
+		var counts LegacyImportResult
+		rowErr, err := inSavepoint(imp.app, func() error {
+			return importRow(legacyRow{cells: row.Cells, columns: columns}, &counts)
+		})
+		if err != nil {
+			return err
+		}
+		if rowErr != nil {
+			result.Errors = append(result.Errors, LegacyRowError{Sheet: sheet.Name, Row: row.Number, Message: rowErr.Error()})
+			continue
+		}
+		result.add(counts)
+	}
+	return nil
+}
+
+// inSavepoint runs fn so that, if it fails, only what it saved is undone.
+// fn's error is returned as rowErr; err is a failure of the savepoint.
+func inSavepoint(app core.App, fn func() error) (rowErr error, err error) {
+	if _, err := app.DB().NewQuery("SAVEPOINT legacy_row").Execute(); err != nil {
+		return nil, err
+	}
+	if rowErr = fn(); rowErr != nil {
+		if _, err := app.DB().NewQuery("ROLLBACK TO legacy_row").Execute(); err != nil {
+			return nil, err
+		}
+	}
+	_, err = app.DB().NewQuery("RELEASE legacy_row").Execute()
+	return rowErr, err
+}
+
+// legacyRow reads the cells of a row by header.
+type legacyRow struct {
+	cells   []any
+	columns map[string]int
+}
+
+func (r legacyRow) cell(header string) any {
+	i, ok := r.columns[legacyKey(header)]
+	if !ok || i >= len(r.cells) {
+		return nil
+	}
+	return r.cells[i]
+}
+
+// date reads a date cell, which is a date serial or text like 2024-07-01.
+func (r legacyRow) date(header string) (time.Time, error) {
+	switch v := r.cell(header).(type) {
+	case float64:
+		return xlsxDate(v), nil
+	case string:
+		v = strings.TrimSpace(v)
+		for _, layout := range []string{time.DateOnly, time.DateTime, "2/1/2006"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return startOfDay(t), nil
+			}
+		}
+		if v != "" {
+			return time.Time{}, fmt.Errorf("%s %q is not a date", header, v)
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s is missing", header)
+}
+
+// number reads a number cell, nil when blank.
+func (r legacyRow) number(header string) (*float64, error) {
+	switch v := r.cell(header).(type) {
+	case float64:
+		return &v, nil
+	case string:
+		v = strings.ReplaceAll(strings.TrimSpace(v), ",", "")
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q is not a number", header, v)
+		}
+		return &n, nil
+	}
+	return nil, nil
+}
+
+// numbers reads number cells, stopping at the first that is not a number.
+func (r legacyRow) numbers(headers ...string) ([]*float64, error) {
+	values := make([]*float64, len(headers))
+	for i, header := range headers {
+		v, err := r.number(header)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// legacyBalances checks an opening and closing pair is given in full or not at
+// all, and reports whether it is given.
+func legacyBalances(what string, opening, closing *float64) (bool, error) {
+	if (opening == nil) != (closing == nil) {
+		return false, fmt.Errorf("give both the opening and the closing %s, or neither", what)
+	}
+	return opening != nil, nil
+}
+
+func (imp *legacyImporter) importFinancialRow(row legacyRow, counts *LegacyImportResult) error {
+	day, err := row.date("Date")
+	if err != nil {
+		return err
+	}
+	v, err := row.numbers(financialLogHeader[1:]...)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []struct {
+		account, name, method   string
+		opening, sales, closing *float64
+	}{
+		{logAccountCash, "cash", "cash", v[0], v[1], v[2]},
+		{logAccountMpesa, "M-Pesa", "mobile_money", v[3], v[4], v[5]},
+	} {
+		given, err := legacyBalances(column.name+" balance", column.opening, column.closing)
+		if err != nil {
+			return err
+		}
+		if given {
+			accountID := imp.accounts[column.account]
+			if accountID == "" {
+				return fmt.Errorf("the company has no %s account; name one with --%s-account", column.name, column.account)
+			}
+			if *column.opening != math.Trunc(*column.opening) || *column.closing != math.Trunc(*column.closing) {
+				return fmt.Errorf("the %s balances must be whole amounts", column.name)
+			}
+			existing, err := imp.app.CountRecords(
+				models.CName[models.DailyAccounts](),
+				dbx.HashExp{"account": accountID},
+				dbx.NewExp("date >= {:from} AND date < {:to}", NewDateRange(day, day).Params()),
+			)
+			if err != nil {
+				return err
+			}
+			if existing > 0 {
+				return fmt.Errorf("the %s balances of %s are already recorded", column.name, day.Format(time.DateOnly))
+			}
+
+			record, err := models.NewProxy[models.DailyAccounts](imp.app)
+			if err != nil {
+				return err
+			}
+			record.Set("company", imp.opts.CompanyID)
+			record.Set("account", accountID)
+			record.Set("user", imp.opts.UserID)
+			record.Set("date", day)
+			record.Set("opening_bal", *column.opening)
+			record.Set("closing_bal", *column.closing)
+			record.Set("notes", legacyImportNote)
+			if err := imp.app.Save(record); err != nil {
+				return fmt.Errorf("failed to save the %s balances: %w", column.name, err)
+			}
+			counts.DailyAccounts++
+		}
+
+		if column.sales == nil || *column.sales == 0 {
+			continue
+		}
+		existing, err := imp.app.CountRecords(
+			models.CName[models.SalesTransactions](),
+			dbx.HashExp{"company": imp.opts.CompanyID, "payment_method": column.method, "notes": legacyImportNote},
+			dbx.NewExp("transaction_date >= {:from} AND transaction_date < {:to}", NewDateRange(day, day).Params()),
+		)
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("the %s sales of %s are already recorded", column.name, day.Format(time.DateOnly))
+		}
+		sale, err := models.NewProxy[models.SalesTransactions](imp.app)
+		if err != nil {
+			return err
+		}
+		// takings below zero are refunds
+		kind := "sale"
+		if *column.sales < 0 {
+			kind = "return"
+		}
+		sale.Set("company", imp.opts.CompanyID)
+		sale.Set("salesperson", imp.opts.UserID)
+		sale.Set("transaction_type", kind)
+		sale.Set("payment_method", column.method)
+		sale.Set("payment_status", "paid")
+		sale.Set("total_amount", math.Abs(*column.sales))
+		sale.Set("transaction_date", day)
+		sale.Set("notes", legacyImportNote)
+		if err := imp.app.Save(sale); err != nil {
+			return fmt.Errorf("failed to save the %s sales: %w", column.name, err)
+		}
+		counts.Sales++
+	}
+	return nil
+}
+
+func (imp *legacyImporter) importInventoryRow(row legacyRow, counts *LegacyImportResult) error {
+	day, err := row.date("Date")
+	if err != nil {
+		return err
+	}
+	name, _ := row.cell("Product").(string)
+	if name = strings.TrimSpace(name); name == "" {
+		return fmt.Errorf("Product is missing")
+	}
+	v, err := row.numbers(inventoryLogHeader[2:]...)
+	if err != nil {
+		return err
+	}
+	opening, received, retail, wholesale, closing := v[0], v[1], v[2], v[3], v[4]
+	counted, err := legacyBalances("stock", opening, closing)
+	if err != nil {
+		return err
+	}
+	if received != nil && *received < 0 {
+		return fmt.Errorf("Quantity Received cannot be negative")
+	}
+
+	product, err := imp.product(name, counts)
+	if err != nil {
+		return err
+	}
+	skuID := imp.defaultSku
+	if skus := product.GetStringSlice("skus"); len(skus) > 0 {
+		skuID = skus[0]
+	}
+
+	params := NewDateRange(day, day).Params()
+	for _, check := range []struct {
+		collection string
+		filter     dbx.Expression
+	}{
+		{models.CName[models.DailyStockTakes](), dbx.NewExp("date >= {:from} AND date < {:to}", params)},
+		{models.CName[models.InventoryTransactions](), dbx.And(
+			dbx.HashExp{"reference_type": legacyImportReference},
+			dbx.NewExp("transaction_date >= {:from} AND transaction_date < {:to}", params),
+		)},
+	} {
+		existing, err := imp.app.CountRecords(check.collection, dbx.HashExp{"product": product.Id}, check.filter)
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("the stock of %s on %s is already recorded", name, day.Format(time.DateOnly))
+		}
+	}
+
+	if counted {
+		take, err := models.NewProxy[models.DailyStockTakes](imp.app)
+		if err != nil {
+			return err
+		}
+		take.Set("company", imp.opts.CompanyID)
+		take.Set("product", product.Id)
+		take.Set("sku", skuID)
+		take.Set("user", imp.opts.UserID)
+		take.Set("date", day)
+		take.Set("opening_bal", *opening)
+		take.Set("closing_bal", *closing)
+		if err := imp.app.Save(take); err != nil {
+			return fmt.Errorf("failed to save the stock take: %w", err)
+		}
+		counts.StockTakes++
+	}
+
+	if received != nil && *received > 0 {
+		purchase, err := models.NewProxy[models.Purchases](imp.app)
+		if err != nil {
+			return err
+		}
+		purchase.Set("company", imp.opts.CompanyID)
+		purchase.Set("product", product.Id)
+		purchase.Set("sku", skuID)
+		purchase.Set("user", imp.opts.UserID)
+		purchase.Set("quantity", *received)
+		purchase.Set("date", day)
+		// the log has no prices: the goods are valued at the cost price
+		inventory, err := findInventory(imp.app, imp.opts.CompanyID, product.Id, skuID)
+		if err != nil {
+			return err
+		}
+		if inventory != nil {
+			purchase.Set("unit_cost", inventory.CostPrice())
+		}
+		if err := imp.app.Save(purchase); err != nil {
+			return fmt.Errorf("failed to save the purchase: %w", err)
+		}
+		counts.Purchases++
+		if err := imp.movement(product.Id, skuID, day, *received, models.Purchase3, legacyReceived, closing); err != nil {
+			return err
+		}
+		counts.Movements++
+	}
+
+	// sales take stock out and sales below zero are returns putting it back
+	for column, sold := range map[string]*float64{legacyRetail: retail, legacyWholesale: wholesale} {
+		if sold == nil || *sold == 0 {
+			continue
+		}
+		reason := models.Sale4
+		if *sold < 0 {
+			reason = models.Return2
+		}
+		if err := imp.movement(product.Id, skuID, day, -*sold, reason, column, closing); err != nil {
+			return err
+		}
+		counts.Movements++
+	}
+
+	if inventory := imp.created[product.Id]; inventory != nil && counted && !day.Before(imp.counted[product.Id]) {
+		inventory.SetCurrentQuantity(*closing)
+		imp.counted[product.Id] = day
+	}
+	return nil
+}
+
+// product finds the company's product of the name, creating it, with an
+// empty inventory line, if there is none.
+func (imp *legacyImporter) product(name string, counts *LegacyImportResult) (*core.Record, error) {
+	key := legacyKey(name)
+	if product := imp.products[key]; product != nil {
+		// a product created by a row that failed was rolled back with it
+		if _, err := imp.app.FindRecordById(models.CName[models.Products](), product.Id); err == nil {
+			return product, nil
+		}
+	}
+	if imp.defaultSku == "" {
+		return nil, fmt.Errorf("product %q does not exist and there is no SKU to create it with; name one with --sku", name)
+	}
+
+	product, err := models.NewProxy[models.Products](imp.app)
+	if err != nil {
+		return nil, err
+	}
+	product.Set("company", imp.opts.CompanyID)
+	product.Set("name", name)
+	product.Set("skus", []string{imp.defaultSku})
+	if err := imp.app.Save(product); err != nil {
+		return nil, fmt.Errorf("failed to create product %q: %w", name, err)
+	}
+	inventory, err := models.NewProxy[models.Inventory](imp.app)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Set("company", imp.opts.CompanyID)
+	inventory.Set("product", product.Id)
+	inventory.Set("sku", imp.defaultSku)
+	if err := imp.app.Save(inventory); err != nil {
+		return nil, fmt.Errorf("failed to create the inventory of %q: %w", name, err)
+	}
+
+	imp.products[key] = product.Record
+	imp.created[product.Id] = inventory
+	imp.counted[product.Id] = time.Time{}
+	counts.Products++
+	return product.Record, nil
+}
+
+// movement logs a stock movement of the day from a column of the inventory
+// log without touching the stock on hand, which the stock takes record.
+func (imp *legacyImporter) movement(productID, skuID string, day time.Time, change float64, reason models.ReasonCodeSelectType, column string, after *float64) error {
+	movement, err := models.NewProxy[models.InventoryTransactions](imp.app)
+	if err != nil {
+		return err
+	}
+	movement.Set("company", imp.opts.CompanyID)
+	movement.Set("product", productID)
+	movement.Set("sku", skuID)
+	movement.Set("user", imp.opts.UserID)
+	movement.SetQuantityChange(change)
+	if after != nil {
+		movement.SetQuantityAfter(*after)
+	}
+	movement.Set("transaction_date", day)
+	movement.SetReasonCode(reason)
+	movement.SetReferenceType(legacyImportReference)
+	movement.SetReferenceId(column)
+	if err := imp.app.Save(movement); err != nil {
+		return fmt.Errorf("failed to save the stock movement: %w", err)
+	}
+	return nil
+}
+
+// updateStock sets the stock on hand of the products the import created to
+// their last counted closing stock.
+func (imp *legacyImporter) updateStock() error {
+	for productID, inventory := range imp.created {
+		if imp.counted[productID].IsZero() {
+			continue
+		}
+		if _, err := imp.app.FindRecordById(models.CName[models.Inventory](), inventory.Id); err != nil {
+			continue
+		}
+		if err := imp.app.Save(inventory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,677 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// loyaltyProgram returns the company's active loyalty program, or nil when
+// it has none or has switched it off.
+func loyaltyProgram(app core.App, companyID string) (*core.Record, error) {
+	program, err := app.FindFirstRecordByFilter(
+		models.CName[models.LoyaltyPrograms](),
+		"company = {:company}",
+		dbx.Params{"company": companyID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !program.GetBool("active") {
+		return nil, nil
+	}
+	return program, nil
+}
+
+// BindLoyalty registers the record hooks that redeem points tendered on a
+// sale, award points on sales to customers and claw them back on returns,
+// repost them when a sale is edited, and schedules the nightly expiry of old
+// points.
+//
+// Points are whole numbers. A sale's loyalty_points_redeemed is the tender
+// offered by the customer; the hook sets loyalty_amount to the part of the
+// total it settles.
+func (helper *DbHelper) BindLoyalty() {
+	sales := models.CName[models.SalesTransactions]()
+
+	helper.pb.OnRecordCreate(sales).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("customer") == "" {
+			if e.Record.GetInt("loyalty_points_redeemed") > 0 {
+				return fmt.Errorf("loyalty points can only be redeemed by a customer")
+			}
+			return e.Next()
+		}
+		// the ledger is written with the sale or not at all; hooks bound
+		// earlier get the outer app back afterwards
+		app := e.App
+		err := app.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := prepareRedemption(txApp, e.Record); err != nil {
+				return err
+			}
+			if err := e.Next(); err != nil {
+				return err
+			}
+			return postLoyalty(txApp, e.Record)
+		})
+		e.App = app
+		return err
+	})
+
+	helper.pb.OnRecordUpdate(sales).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		changed := false
+		for _, field := range loyaltySaleFields {
+			if fmt.Sprint(e.Record.Get(field)) != fmt.Sprint(original.Get(field)) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return e.Next()
+		}
+		if e.Record.GetString("customer") == "" && e.Record.GetInt("loyalty_points_redeemed") > 0 {
+			return fmt.Errorf("loyalty points can only be redeemed by a customer")
+		}
+		app := e.App
+		err := app.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			// the previous customer gets back what the sale redeemed and
+			// loses what it earned before the sale is posted again
+			if err := unpostLoyalty(txApp, e.Record.Id); err != nil {
+				return err
+			}
+			if err := recomputeLoyalty(txApp, original.GetString("customer")); err != nil {
+				return err
+			}
+			if e.Record.GetString("customer") == "" {
+				e.Record.Set("loyalty_amount", 0)
+				return e.Next()
+			}
+			if err := prepareRedemption(txApp, e.Record); err != nil {
+				return err
+			}
+			if err := e.Next(); err != nil {
+				return err
+			}
+			return postLoyalty(txApp, e.Record)
+		})
+		e.App = app
+		return err
+	})
+
+	helper.pb.OnRecordDelete(sales).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		// the sale's ledger entries went with it
+		return recomputeLoyalty(e.App, e.Record.GetString("customer"))
+	})
+
+	helper.pb.Cron().MustAdd("loyaltyExpiry", "10 0 * * *", func() {
+		if err := helper.ExpireLoyaltyPoints(time.Now()); err != nil {
+			helper.Logger.Printf("Error expiring loyalty points: %v", err)
+		}
+	})
+}
+
+// loyaltySaleFields are the sale fields its loyalty entries are worked out
+// from.
+var loyaltySaleFields = []string{
+	"customer",
+	"total_amount",
+	"exchange_rate",
+	"loyalty_points_redeemed",
+	"transaction_type",
+	"transaction_date",
+	"return_of",
+	"sales_details",
+}
+
+// unpostLoyalty takes back the ledger entries of a sale. The points earned
+// are kept as an empty batch while later clawbacks or expiries refer to it,
+// so that reposting the sale fills the same batch again.
+func unpostLoyalty(txApp core.App, saleID string) error {
+	entries, err := txApp.FindRecordsByFilter(
+		models.CName[models.LoyaltyEntries](),
+		"sale = {:sale}",
+		"",
+		0,
+		0,
+		dbx.Params{"sale": saleID},
+	)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.GetString("type") == "earn" {
+			used, err := txApp.CountRecords(models.CName[models.LoyaltyEntries](), dbx.HashExp{"lot": entry.Id})
+			if err != nil {
+				return err
+			}
+			if used > 0 {
+				entry.Set("points", 0)
+				entry.Set("amount", 0)
+				entry.Set("remaining", 0)
+				if err := txApp.Save(entry); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := txApp.Delete(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareRedemption checks the points tendered on a sale against the
+// customer's balance and converts them to an amount in the sale currency.
+func prepareRedemption(txApp core.App, sale *core.Record) error {
+	points := sale.GetInt("loyalty_points_redeemed")
+	if points <= 0 {
+		sale.Set("loyalty_amount", 0)
+		return nil
+	}
+
+	program, err := loyaltyProgram(txApp, sale.GetString("company"))
+	if err != nil {
+		return err
+	}
+	if program == nil {
+		return fmt.Errorf("the company has no active loyalty program")
+	}
+	if minimum := program.GetInt("min_redeem_points"); points < minimum {
+		return fmt.Errorf("at least %d points must be redeemed at a time", minimum)
+	}
+
+	customerID := sale.GetString("customer")
+	if err := expireLoyaltyPoints(txApp, time.Now(), customerID); err != nil {
+		return err
+	}
+	customer, err := txApp.FindRecordById(models.CName[models.Partners](), customerID)
+	if err != nil {
+		return err
+	}
+	if balance := customer.GetInt("loyalty_points"); points > balance {
+		return fmt.Errorf("%s has %d loyalty points, not %d", customer.GetString("name"), balance, points)
+	}
+
+	amount := float64(points) * program.GetFloat("point_value")
+	if rate := sale.GetFloat("exchange_rate"); rate > 0 {
+		amount /= rate
+	}
+	amount = roundMoney(amount)
+	if amount > sale.GetFloat("total_amount") {
+		return fmt.Errorf("%d points are worth %s, more than the sale total", points, formatAmount(amount))
+	}
+	sale.Set("loyalty_amount", amount)
+	return nil
+}
+
+// postLoyalty writes the ledger entries of a sale: the points redeemed, the
+// points earned on what was not paid with points, or the points clawed back
+// by a return.
+func postLoyalty(txApp core.App, sale *core.Record) error {
+	customerID := sale.GetString("customer")
+	program, err := loyaltyProgram(txApp, sale.GetString("company"))
+	if err != nil {
+		return err
+	}
+	date := sale.GetDateTime("transaction_date")
+	if date.IsZero() {
+		date = types.NowDateTime()
+	}
+
+	if points := sale.GetInt("loyalty_points_redeemed"); points > 0 {
+		if _, err := saveLoyaltyEntry(txApp, sale, "redeem", -points, -BaseAmount(sale, "loyalty_amount"), date, nil); err != nil {
+			return err
+		}
+	}
+
+	switch sale.GetString("transaction_type") {
+	case "", "sale":
+		if program == nil {
+			break
+		}
+		base := roundMoney(BaseAmount(sale, "total_amount") - BaseAmount(sale, "loyalty_amount"))
+		points := int(math.Floor(base * program.GetFloat("earn_rate")))
+		if points <= 0 {
+			break
+		}
+		_, err := saveLoyaltyEntry(txApp, sale, "earn", points, base, date, func(entry *core.Record) {
+			entry.Set("remaining", points)
+			entry.Set("expires_at", "")
+			if days := program.GetInt("expiry_days"); days > 0 {
+				expires, _ := types.ParseDateTime(date.Time().AddDate(0, 0, days))
+				entry.Set("expires_at", expires)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	case "return":
+		if err := clawBackLoyalty(txApp, sale, program, date); err != nil {
+			return err
+		}
+	}
+	return recomputeLoyalty(txApp, customerID)
+}
+
+// clawBackLoyalty takes back the points earned on the returned lines. Each
+// returned line is matched to the original sale's line of the same SKU, or
+// product, and takes back that line's share of the points the sale earned
+// for the quantity returned, whatever the return is priced at. A return
+// without lines takes back the share the return is of the sale's value, and
+// one whose original sale is not known the current earn rate.
+func clawBackLoyalty(txApp core.App, sale, program *core.Record, date types.DateTime) error {
+	value := math.Abs(BaseAmount(sale, "total_amount"))
+
+	var lot *core.Record
+	points := 0
+	if original := sale.GetString("return_of"); original != "" {
+		earned, err := txApp.FindFirstRecordByFilter(
+			models.CName[models.LoyaltyEntries](),
+			"sale = {:sale} && type = 'earn'",
+			dbx.Params{"sale": original},
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			// nothing was earned on the original sale
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if earned.GetFloat("amount") <= 0 {
+			return nil
+		}
+		lot = earned
+
+		share, err := returnedShare(txApp, sale, original)
+		if err != nil {
+			return err
+		}
+		if share < 0 {
+			share = value / earned.GetFloat("amount")
+		}
+		share = min(share, 1)
+		points = int(math.Round(float64(earned.GetInt("points")) * share))
+		value = roundMoney(earned.GetFloat("amount") * share)
+
+		// never take back more than was earned on the sale
+		previous, err := txApp.FindRecordsByFilter(
+			models.CName[models.LoyaltyEntries](),
+			"lot = {:lot} && type = 'clawback'",
+			"",
+			0,
+			0,
+			dbx.Params{"lot": earned.Id},
+		)
+		if err != nil {
+			return err
+		}
+		left := earned.GetInt("points")
+		for _, p := range previous {
+			left += p.GetInt("points")
+		}
+		points = min(points, max(left, 0))
+	} else if program != nil {
+		points = int(math.Floor(value * program.GetFloat("earn_rate")))
+	}
+	if points <= 0 {
+		return nil
+	}
+
+	_, err := saveLoyaltyEntry(txApp, sale, "clawback", -points, -value, date, func(entry *core.Record) {
+		if lot != nil {
+			entry.Set("lot", lot.Id)
+		}
+	})
+	return err
+}
+
+// returnedShare is the share of the original sale's lines, by their value at
+// the original prices, that the return's lines bring back. Lines matching
+// nothing on the original sale take nothing back. It is -1 when either sale
+// has no lines to compare.
+func returnedShare(txApp core.App, sale *core.Record, originalID string) (float64, error) {
+	original, err := txApp.FindRecordById(models.CName[models.SalesTransactions](), originalID)
+	if err != nil {
+		return 0, err
+	}
+	originalIDs, returnedIDs := original.GetStringSlice("sales_details"), sale.GetStringSlice("sales_details")
+	if len(originalIDs) == 0 || len(returnedIDs) == 0 {
+		return -1, nil
+	}
+	originalLines, err := txApp.FindRecordsByIds(models.CName[models.SalesDetails](), originalIDs)
+	if err != nil {
+		return 0, err
+	}
+	returnedLines, err := txApp.FindRecordsByIds(models.CName[models.SalesDetails](), returnedIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	type soldLine struct {
+		sku, product string
+		quantity     float64
+		unitPrice    float64
+	}
+	var sold []*soldLine
+	var total float64
+	for _, line := range originalLines {
+		l := &soldLine{
+			sku:       line.GetString("sku"),
+			product:   line.GetString("product"),
+			quantity:  math.Abs(line.GetFloat("quantity")),
+			unitPrice: line.GetFloat("unit_price"),
+		}
+		sold = append(sold, l)
+		total += l.quantity * l.unitPrice
+	}
+	if total <= 0 {
+		return -1, nil
+	}
+
+	var returned float64
+	for _, line := range returnedLines {
+		quantity := math.Abs(line.GetFloat("quantity"))
+		for _, matchSku := range []bool{true, false} {
+			for _, l := range sold {
+				if quantity <= 0 {
+					break
+				}
+				if l.quantity <= 0 || (matchSku && (l.sku == "" || l.sku != line.GetString("sku"))) || l.product != line.GetString("product") {
+					continue
+				}
+				// each sold unit is taken back once
+				taken := min(quantity, l.quantity)
+				returned += taken * l.unitPrice
+				l.quantity -= taken
+				quantity -= taken
+			}
+		}
+	}
+	return returned / total, nil
+}
+
+// saveLoyaltyEntry writes one ledger entry of the sale. A batch of earned
+// points kept by unpostLoyalty is filled again rather than a new one added.
+func saveLoyaltyEntry(txApp core.App, sale *core.Record, entryType string, points int, amount float64, date types.DateTime, configure func(*core.Record)) (*core.Record, error) {
+	entry, err := txApp.FindFirstRecordByFilter(
+		models.CName[models.LoyaltyEntries](),
+		"sale = {:sale} && type = {:type}",
+		dbx.Params{"sale": sale.Id, "type": entryType},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		proxy, err := models.NewProxy[models.LoyaltyEntries](txApp)
+		if err != nil {
+			return nil, err
+		}
+		entry = proxy.Record
+	} else if err != nil {
+		return nil, err
+	}
+	entry.Set("company", sale.GetString("company"))
+	entry.Set("partner", sale.GetString("customer"))
+	entry.Set("sale", sale.Id)
+	entry.Set("type", entryType)
+	entry.Set("points", points)
+	entry.Set("amount", roundMoney(amount))
+	entry.Set("date", date)
+	if configure != nil {
+		configure(entry)
+	}
+	if err := txApp.Save(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// recomputeLoyalty replays a customer's ledger to set what is left of each
+// batch of earned points and the customer's points balance. Redemptions and
+// clawbacks use up the batches that expire first; a clawback tied to a sale
+// uses that sale's batch first.
+func recomputeLoyalty(app core.App, partnerID string) error {
+	if partnerID == "" {
+		return nil
+	}
+	entries, err := app.FindRecordsByFilter(
+		models.CName[models.LoyaltyEntries](),
+		"partner = {:partner}",
+		"date,created",
+		0,
+		0,
+		dbx.Params{"partner": partnerID},
+	)
+	if err != nil {
+		return err
+	}
+
+	var lots []*core.Record
+	remaining := map[string]int{}
+	take := func(lotID string, points int) int {
+		used := min(remaining[lotID], points)
+		remaining[lotID] -= used
+		return points - used
+	}
+
+	balance := 0
+	for _, entry := range entries {
+		points := entry.GetInt("points")
+		balance += points
+		switch entry.GetString("type") {
+		case "earn":
+			lots = append(lots, entry)
+			remaining[entry.Id] = points
+			// earnings first make up for points clawed back after
+			// they were spent
+			if balance < points {
+				remaining[entry.Id] = max(balance, 0)
+			}
+		case "expire":
+			take(entry.GetString("lot"), -points)
+		default:
+			need := -points
+			if lotID := entry.GetString("lot"); lotID != "" {
+				need = take(lotID, need)
+			}
+			at := entry.GetDateTime("date").Time()
+			sort.SliceStable(lots, func(i, j int) bool {
+				a, b := lots[i].GetDateTime("expires_at"), lots[j].GetDateTime("expires_at")
+				if a.IsZero() || b.IsZero() {
+					return !a.IsZero()
+				}
+				return a.Time().Before(b.Time())
+			})
+			for _, lot := range lots {
+				if need <= 0 {
+					break
+				}
+				if expires := lot.GetDateTime("expires_at"); !expires.IsZero() && !expires.Time().After(at) {
+					continue
+				}
+				need = take(lot.Id, need)
+			}
+		}
+	}
+
+	for _, lot := range lots {
+		if lot.GetInt("remaining") == remaining[lot.Id] {
+			continue
+		}
+		lot.Set("remaining", remaining[lot.Id])
+		if err := app.Save(lot); err != nil {
+			return err
+		}
+	}
+	partner, err := app.FindRecordById(models.CName[models.Partners](), partnerID)
+	if err != nil {
+		return err
+	}
+	if partner.GetInt("loyalty_points") == balance {
+		return nil
+	}
+	partner.Set("loyalty_points", balance)
+	return app.Save(partner)
+}
+
+// expireLoyaltyPoints writes off what is left of every batch of points that
+// expired by now, for one partner or, when partnerID is empty, for all.
+func expireLoyaltyPoints(app core.App, now time.Time, partnerID string) error {
+	filter := "type = 'earn' && remaining > 0 && expires_at != '' && expires_at <= {:now}"
+	params := dbx.Params{"now": formatDate(now)}
+	if partnerID != "" {
+		filter += " && partner = {:partner}"
+		params["partner"] = partnerID
+	}
+	lots, err := app.FindRecordsByFilter(models.CName[models.LoyaltyEntries](), filter, "expires_at", 0, 0, params)
+	if err != nil {
+		return err
+	}
+
+	partners := map[string]bool{}
+	for _, lot := range lots {
+		entry, err := models.NewProxy[models.LoyaltyEntries](app)
+		if err != nil {
+			return err
+		}
+		entry.Set("company", lot.GetString("company"))
+		entry.Set("partner", lot.GetString("partner"))
+		entry.Set("type", "expire")
+		entry.Set("points", -lot.GetInt("remaining"))
+		entry.Set("lot", lot.Id)
+		entry.Set("date", lot.GetDateTime("expires_at"))
+		if err := app.Save(entry); err != nil {
+			return err
+		}
+		partners[lot.GetString("partner")] = true
+	}
+	for id := range partners {
+		if err := recomputeLoyalty(app, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireLoyaltyPoints writes off every batch of loyalty points that has
+// expired by now. It runs nightly.
+func (helper *DbHelper) ExpireLoyaltyPoints(now time.Time) error {
+	return helper.pb.RunInTransaction(func(txApp core.App) error {
+		return expireLoyaltyPoints(txApp, now, "")
+	})
+}
+
+// LoyaltyProgramSettings configures how customers earn and spend points.
+// EarnRate is the points earned per unit of base currency spent and
+// PointValue what one point is worth, in the base currency, when redeemed.
+// Points never expire when ExpiryDays is zero.
+type LoyaltyProgramSettings struct {
+	Active          bool    `json:"active"`
+	EarnRate        float64 `json:"earn_rate"`
+	PointValue      float64 `json:"point_value"`
+	ExpiryDays      int     `json:"expiry_days"`
+	MinRedeemPoints int     `json:"min_redeem_points"`
+}
+
+// ConfigureLoyaltyProgram sets up or changes the company's loyalty program.
+// Only managers may change it; points already earned keep their expiry.
+func (helper *DbHelper) ConfigureLoyaltyProgram(companyID, userID string, settings LoyaltyProgramSettings) (*models.LoyaltyPrograms, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	if settings.EarnRate < 0 || settings.PointValue < 0 || settings.ExpiryDays < 0 || settings.MinRedeemPoints < 0 {
+		return nil, fmt.Errorf("loyalty settings cannot be negative")
+	}
+
+	program, err := helper.pb.FindFirstRecordByFilter(
+		models.CName[models.LoyaltyPrograms](),
+		"company = {:company}",
+		dbx.Params{"company": companyID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		proxy, err := models.NewProxy[models.LoyaltyPrograms](helper.pb)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Set("company", companyID)
+		program = proxy.Record
+	} else if err != nil {
+		return nil, err
+	}
+	program.Set("active", settings.Active)
+	program.Set("earn_rate", settings.EarnRate)
+	program.Set("point_value", settings.PointValue)
+	program.Set("expiry_days", settings.ExpiryDays)
+	program.Set("min_redeem_points", settings.MinRedeemPoints)
+	if err := helper.pb.Save(program); err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.LoyaltyPrograms](program)
+}
+
+// LoyaltyAccount is a customer's points balance and ledger, newest first.
+type LoyaltyAccount struct {
+	Partner *models.Partners         `json:"partner"`
+	Points  int                      `json:"points"`
+	Value   float64                  `json:"value"`
+	Entries []*models.LoyaltyEntries `json:"entries"`
+}
+
+// LoyaltyAccount returns the customer's points, what they are worth at the
+// current point value and the ledger behind them.
+func (helper *DbHelper) LoyaltyAccount(companyID, partnerID string) (*LoyaltyAccount, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.Partners](), partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("partner %s not found: %w", partnerID, err)
+	}
+	if record.GetString("company") != companyID {
+		return nil, fmt.Errorf("partner %s does not belong to the company", partnerID)
+	}
+	partner, err := models.WrapRecord[models.Partners](record)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &LoyaltyAccount{Partner: partner, Points: record.GetInt("loyalty_points"), Entries: []*models.LoyaltyEntries{}}
+	program, err := loyaltyProgram(helper.pb, companyID)
+	if err != nil {
+		return nil, err
+	}
+	if program != nil {
+		account.Value = roundMoney(float64(account.Points) * program.GetFloat("point_value"))
+	}
+
+	entries, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.LoyaltyEntries](),
+		"partner = {:partner}",
+		"-date,-created",
+		0,
+		0,
+		dbx.Params{"partner": partnerID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range entries {
+		entry, err := models.WrapRecord[models.LoyaltyEntries](record)
+		if err != nil {
+			return nil, err
+		}
+		account.Entries = append(account.Entries, entry)
+	}
+	return account, nil
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/domodwyer/mailyak/v3"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// FileDropMailer writes every message to Dir as an .eml file instead of
+// sending it, so documents and notifications can be checked in development
+// without a mail server.
+type FileDropMailer struct {
+	Dir string
+}
+
+// Send implements mailer.Mailer.
+func (m *FileDropMailer) Send(message *mailer.Message) error {
+	y := mailyak.New("", nil)
+	y.From(message.From.Address)
+	y.FromName(message.From.Name)
+	y.To(mailAddresses(message.To)...)
+	y.Cc(mailAddresses(message.Cc)...)
+	y.Subject(message.Subject)
+	for name, value := range message.Headers {
+		y.SetHeader(name, value)
+	}
+	y.HTML().Set(message.HTML)
+	y.Plain().Set(message.Text)
+	for name, r := range message.Attachments {
+		y.Attach(name, r)
+	}
+	for name, r := range message.InlineAttachments {
+		y.AttachInline(name, r)
+	}
+
+	buf, err := y.MimeBuf()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), security.RandomString(6))
+	return os.WriteFile(filepath.Join(m.Dir, name), buf.Bytes(), 0o644)
+}
+
+func mailAddresses(addresses []mail.Address) []string {
+	out := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		out = append(out, a.Address)
+	}
+	return out
+}
+
+// Mailer returns the mail sender used for documents and notifications: the
+// one set on the helper, else a file drop in the data directory when running
+// with --dev, else PocketBase's mail client with the SMTP settings from the
+// admin UI.
+func (helper *DbHelper) Mailer() mailer.Mailer {
+	if helper.MailSender != nil {
+		return helper.MailSender
+	}
+	if helper.pb.IsDev() {
+		return &FileDropMailer{Dir: filepath.Join(helper.pb.DataDir(), "outbox")}
+	}
+	return helper.pb.NewMailClient()
+}
+
+// mailFrom is the sender address configured in the PocketBase settings.
+func (helper *DbHelper) mailFrom(name string) mail.Address {
+	meta := helper.pb.Settings().Meta
+	if name == "" {
+		name = meta.SenderName
+	}
+	return mail.Address{Name: name, Address: meta.SenderAddress}
+}
@@ -0,0 +1,315 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// notificationMaxAttempts is how often a message is tried before it is
+	// marked failed.
+	notificationMaxAttempts = 6
+	notificationBaseBackoff = time.Minute
+	notificationMaxBackoff  = 6 * time.Hour
+	notificationBatchSize   = 50
+	// notificationStaleAfter is how long a message may stay "sending"
+	// before it is assumed lost with a crashed dispatcher and sent again.
+	notificationStaleAfter = 10 * time.Minute
+)
+
+// notificationBackoff is the wait before the next attempt after the given
+// number of failed ones: 1, 2, 4, 8 ... minutes, at most six hours.
+func notificationBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	backoff := notificationBaseBackoff << min(attempts-1, 20)
+	return min(backoff, notificationMaxBackoff)
+}
+
+// Notification is a message to queue in the outbox. Channel is "sms" or
+// "email"; HTML marks an email body as HTML. Kind and ReferenceID say what
+// the message is about, e.g. "receipt" and the sale id. SendAt delays the
+// first attempt.
+type Notification struct {
+	Company     string
+	Channel     string
+	Recipient   string
+	Subject     string
+	Body        string
+	HTML        bool
+	Kind        string
+	ReferenceID string
+	SendAt      time.Time
+}
+
+// queueNotification writes a message to the outbox with app, so it is only
+// sent if the transaction that queued it commits.
+func queueNotification(app core.App, n Notification) (*models.Notifications, error) {
+	n.Recipient = strings.TrimSpace(n.Recipient)
+	switch n.Channel {
+	case "sms":
+		if len(normalizePhone(n.Recipient)) < 9 {
+			return nil, fmt.Errorf("%q is not a phone number", n.Recipient)
+		}
+	case "email":
+		if _, err := mail.ParseAddress(n.Recipient); err != nil {
+			return nil, fmt.Errorf("%q is not an email address", n.Recipient)
+		}
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q", n.Channel)
+	}
+	if strings.TrimSpace(n.Body) == "" {
+		return nil, fmt.Errorf("notification has no message")
+	}
+	if n.SendAt.IsZero() {
+		n.SendAt = time.Now()
+	}
+	sendAt, err := types.ParseDateTime(n.SendAt)
+	if err != nil {
+		return nil, err
+	}
+
+	notification, err := models.NewProxy[models.Notifications](app)
+	if err != nil {
+		return nil, err
+	}
+	notification.Set("company", n.Company)
+	notification.Set("channel", n.Channel)
+	notification.SetRecipient(n.Recipient)
+	notification.SetSubject(n.Subject)
+	notification.SetBody(n.Body)
+	notification.SetHtml(n.HTML)
+	notification.SetKind(n.Kind)
+	notification.SetReferenceId(n.ReferenceID)
+	notification.Set("status", "pending")
+	notification.SetNextAttemptAt(sendAt)
+	if err := app.Save(notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// QueueNotification adds a message to the outbox. The dispatcher sends it
+// shortly after, retrying with backoff if the provider fails.
+func (helper *DbHelper) QueueNotification(n Notification) (*models.Notifications, error) {
+	return queueNotification(helper.pb, n)
+}
+
+// BindNotifications starts the outbox dispatcher: it runs every minute and
+// as soon as a message is queued.
+func (helper *DbHelper) BindNotifications() {
+	dispatch := func() {
+		if _, err := helper.DispatchNotifications(context.Background()); err != nil {
+			helper.Logger.Printf("Error dispatching notifications: %v", err)
+		}
+	}
+
+	helper.pb.OnRecordAfterCreateSuccess(models.CName[models.Notifications]()).BindFunc(func(e *core.RecordEvent) error {
+		go dispatch()
+		return e.Next()
+	})
+
+	helper.pb.Cron().MustAdd("notificationDispatch", "* * * * *", dispatch)
+}
+
+// DispatchNotifications sends the messages that are due and returns how
+// many were sent. Failed messages are retried with exponential backoff until
+// notificationMaxAttempts, or marked failed at once when the provider
+// rejects them outright.
+func (helper *DbHelper) DispatchNotifications(ctx context.Context) (int, error) {
+	helper.dispatching.Lock()
+	defer helper.dispatching.Unlock()
+
+	if err := helper.requeueStaleNotifications(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	due, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Notifications](),
+		"status = 'pending' && next_attempt_at <= {:now}",
+		"next_attempt_at",
+		notificationBatchSize,
+		0,
+		dbx.Params{"now": formatDate(now)},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, record := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, err := helper.claimNotification(record.Id)
+		if err != nil {
+			return sent, err
+		}
+		if claimed == nil {
+			continue
+		}
+
+		provider, messageID, sendErr := helper.deliverNotification(ctx, claimed)
+		attempts := claimed.GetInt("attempts") + 1
+		claimed.Set("attempts", attempts)
+		claimed.Set("provider", provider)
+		switch {
+		case sendErr == nil:
+			claimed.Set("status", "sent")
+			claimed.Set("sent_at", types.NowDateTime())
+			claimed.Set("provider_message_id", messageID)
+			claimed.Set("last_error", "")
+			sent++
+		case isPermanent(sendErr) || attempts >= notificationMaxAttempts:
+			claimed.Set("status", "failed")
+			claimed.Set("last_error", sendErr.Error())
+		default:
+			next, _ := types.ParseDateTime(time.Now().Add(notificationBackoff(attempts)))
+			claimed.Set("status", "pending")
+			claimed.Set("next_attempt_at", next)
+			claimed.Set("last_error", sendErr.Error())
+		}
+		if err := helper.pb.Save(claimed); err != nil {
+			return sent, fmt.Errorf("failed to record delivery of notification %s: %w", claimed.Id, err)
+		}
+	}
+	return sent, nil
+}
+
+// claimNotification marks a pending message as being sent, or returns nil
+// when another dispatcher got to it first.
+func (helper *DbHelper) claimNotification(id string) (*core.Record, error) {
+	var claimed *core.Record
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		record, err := txApp.FindRecordById(models.CName[models.Notifications](), id)
+		if err != nil {
+			return err
+		}
+		if record.GetString("status") != "pending" {
+			return nil
+		}
+		record.Set("status", "sending")
+		if err := txApp.Save(record); err != nil {
+			return err
+		}
+		claimed = record
+		return nil
+	})
+	return claimed, err
+}
+
+// requeueStaleNotifications puts back messages left "sending" by a
+// dispatcher that stopped mid-delivery. They may be delivered twice.
+func (helper *DbHelper) requeueStaleNotifications() error {
+	stale, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Notifications](),
+		"status = 'sending' && updated < {:before}",
+		"",
+		0,
+		0,
+		dbx.Params{"before": formatDate(time.Now().Add(-notificationStaleAfter))},
+	)
+	if err != nil {
+		return err
+	}
+	for _, record := range stale {
+		record.Set("status", "pending")
+		if err := helper.pb.Save(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverNotification sends one message and returns the provider used and
+// its id for the message.
+func (helper *DbHelper) deliverNotification(ctx context.Context, record *core.Record) (string, string, error) {
+	switch record.GetString("channel") {
+	case "sms":
+		sender := helper.SMS()
+		if sender == nil {
+			// retried, so messages go out once a provider is configured
+			return "", "", fmt.Errorf("no SMS provider is configured")
+		}
+		id, err := sender.SendSMS(ctx, record.GetString("recipient"), record.GetString("body"))
+		return sender.Name(), id, err
+	case "email":
+		mailClient := helper.Mailer()
+		provider := "smtp"
+		if _, ok := mailClient.(*FileDropMailer); ok {
+			provider = "file"
+		}
+		message := &mailer.Message{
+			From:    helper.mailFrom(""),
+			To:      []mail.Address{{Address: record.GetString("recipient")}},
+			Subject: record.GetString("subject"),
+		}
+		if record.GetBool("html") {
+			message.HTML = record.GetString("body")
+		} else {
+			message.Text = record.GetString("body")
+		}
+		return provider, "", mailClient.Send(message)
+	default:
+		return "", "", &permanentError{fmt.Errorf("unknown notification channel %q", record.GetString("channel"))}
+	}
+}
+
+// FetchNotifications lists the company's outbox, newest first, optionally
+// only the messages with the given status.
+func (helper *DbHelper) FetchNotifications(companyID, status string, limit int) ([]*models.Notifications, error) {
+	filter := "company = {:company}"
+	if status != "" {
+		filter += " && status = {:status}"
+	}
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Notifications](),
+		filter,
+		"-created",
+		limit,
+		0,
+		dbx.Params{"company": companyID, "status": status},
+	)
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]*models.Notifications, 0, len(records))
+	for _, record := range records {
+		n, err := models.WrapRecord[models.Notifications](record)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// RetryNotification sends a failed message again, with a fresh set of
+// attempts.
+func (helper *DbHelper) RetryNotification(companyID, notificationID string) (*models.Notifications, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.Notifications](), notificationID)
+	if err != nil || record.GetString("company") != companyID {
+		return nil, fmt.Errorf("notification %s not found", notificationID)
+	}
+	if record.GetString("status") != "failed" {
+		return nil, fmt.Errorf("only failed notifications can be retried")
+	}
+	record.Set("status", "pending")
+	record.Set("attempts", 0)
+	record.Set("next_attempt_at", types.NowDateTime())
+	if err := helper.pb.Save(record); err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.Notifications](record)
+}
@@ -0,0 +1,282 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// defaultDocumentPrefixes are used when a company numbers its first document
+// of a type. Companies may change them afterwards.
+var defaultDocumentPrefixes = map[string]string{
+	"sale":                "RCP",
+	"invoice":             "INV",
+	"purchase_order":      "PO",
+	"goods_received_note": "GRN",
+	"credit_note":         "CN",
+}
+
+const defaultDocumentPadding = 6
+
+// nextDocumentNumber takes the next number of the company's sequence for the
+// document type, dated on the given day in the company's timezone.
+//
+// It must be called inside the transaction that saves the document: SQLite
+// serialises write transactions, so two checkouts at the same instant take
+// consecutive numbers, and a failed save rolls the sequence back with it, so
+// no number is skipped.
+func nextDocumentNumber(txApp core.App, companyID, docType string, date time.Time) (string, error) {
+	if _, ok := defaultDocumentPrefixes[docType]; !ok {
+		return "", fmt.Errorf("unknown document type %q", docType)
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	company, err := txApp.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return "", err
+	}
+	// the year turns at midnight where the company trades
+	year := date.In(companyLocation(company)).Year()
+
+	seq, err := documentSequence(txApp, companyID, docType, year)
+	if err != nil {
+		return "", err
+	}
+	number := max(seq.GetInt("next_number"), 1)
+	seq.Set("next_number", number+1)
+	if err := txApp.Save(seq); err != nil {
+		return "", fmt.Errorf("failed to advance %s numbering: %w", docType, err)
+	}
+	return formatDocumentNumber(seq, number), nil
+}
+
+// documentSequence returns the counter a document of the year is numbered
+// from. Numbering that resets yearly keeps a counter per year, so a document
+// dated into the next year does not restart the current year's numbers;
+// otherwise the company's latest counter runs on. A new counter takes the
+// prefix, padding and reset of the latest one, or the defaults.
+func documentSequence(txApp core.App, companyID, docType string, year int) (*core.Record, error) {
+	sequences, err := txApp.FindRecordsByFilter(
+		models.CName[models.DocumentSequences](),
+		"company = {:company} && document_type = {:type}",
+		"-year",
+		0,
+		0,
+		dbx.Params{"company": companyID, "type": docType},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(sequences) > 0 && !sequences[0].GetBool("reset_yearly") {
+		return sequences[0], nil
+	}
+	for _, seq := range sequences {
+		if seq.GetInt("year") == year {
+			return seq, nil
+		}
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(models.CName[models.DocumentSequences]())
+	if err != nil {
+		return nil, err
+	}
+	seq := core.NewRecord(collection)
+	seq.Set("company", companyID)
+	seq.Set("document_type", docType)
+	seq.Set("year", year)
+	seq.Set("next_number", 1)
+	seq.Set("reset_yearly", true)
+	if len(sequences) > 0 {
+		seq.Set("prefix", sequences[0].GetString("prefix"))
+		seq.Set("padding", sequences[0].GetInt("padding"))
+	} else {
+		seq.Set("prefix", defaultDocumentPrefixes[docType])
+		seq.Set("padding", defaultDocumentPadding)
+	}
+	return seq, nil
+}
+
+// formatDocumentNumber joins the prefix, the year when numbering resets
+// yearly and the zero-padded number, e.g. "INV-2025-000042".
+func formatDocumentNumber(seq *core.Record, number int) string {
+	var parts []string
+	if prefix := strings.TrimSpace(seq.GetString("prefix")); prefix != "" {
+		parts = append(parts, prefix)
+	}
+	if seq.GetBool("reset_yearly") {
+		parts = append(parts, strconv.Itoa(seq.GetInt("year")))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", seq.GetInt("padding"), number))
+	return strings.Join(parts, "-")
+}
+
+// documentNumber returns the document's number, or its id if it was created
+// before documents were numbered.
+func documentNumber(record *core.Record) string {
+	if number := record.GetString("number"); number != "" {
+		return number
+	}
+	return record.Id
+}
+
+// BindDocumentNumbering registers the record hooks that number sales,
+// returns (as credit notes) and sale invoices as they are created, however
+// they are created, and stop the numbers from being changed afterwards.
+//
+// Purchase orders and goods-received notes are numbered by the services
+// that create them.
+func (helper *DbHelper) BindDocumentNumbering() {
+	numbered := []struct {
+		collection string
+		dateField  string
+		// docType returns the sequence a new record is numbered from, or ""
+		// when it is not numbered
+		docType func(record *core.Record) string
+	}{
+		{models.CName[models.SalesTransactions](), "transaction_date", func(record *core.Record) string {
+			if record.GetString("transaction_type") == "return" || record.GetString("return_of") != "" {
+				return "credit_note"
+			}
+			return "sale"
+		}},
+		{models.CName[models.Invoices](), "date", func(record *core.Record) string {
+			// purchase invoices carry the supplier's own numbering
+			if record.GetString("type") == "purchase" {
+				return ""
+			}
+			return "invoice"
+		}},
+	}
+
+	for _, n := range numbered {
+		helper.pb.OnRecordCreate(n.collection).BindFunc(func(e *core.RecordEvent) error {
+			if e.Record.GetString("number") != "" {
+				return e.Next()
+			}
+			docType := n.docType(e.Record)
+			if docType == "" {
+				return e.Next()
+			}
+			// hooks bound earlier resume with e.App after Next returns, so
+			// they must get the outer app back once the transaction ends
+			app := e.App
+			err := app.RunInTransaction(func(txApp core.App) error {
+				e.App = txApp
+				number, err := nextDocumentNumber(txApp, e.Record.GetString("company"), docType, e.Record.GetDateTime(n.dateField).Time())
+				if err != nil {
+					return err
+				}
+				e.Record.Set("number", number)
+				return e.Next()
+			})
+			e.App = app
+			if err != nil {
+				// the number went back to the sequence with the rollback
+				e.Record.Set("number", "")
+			}
+			return err
+		})
+
+		helper.pb.OnRecordUpdate(n.collection).BindFunc(func(e *core.RecordEvent) error {
+			if previous := e.Record.Original().GetString("number"); previous != "" && e.Record.GetString("number") != previous {
+				return fmt.Errorf("document number %s cannot be changed", previous)
+			}
+			return e.Next()
+		})
+	}
+}
+
+// ConfigureDocumentSequence sets the prefix, yearly reset and zero padding a
+// company uses for a document type. Only managers may change numbering and
+// numbers already issued are unchanged.
+func (helper *DbHelper) ConfigureDocumentSequence(companyID, userID, docType, prefix string, resetYearly bool, padding int) (*models.DocumentSequences, error) {
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+	if _, ok := defaultDocumentPrefixes[docType]; !ok {
+		return nil, fmt.Errorf("unknown document type %q", docType)
+	}
+	prefix = strings.TrimSpace(prefix)
+	if len(prefix) > 12 || strings.ContainsAny(prefix, " /\\") {
+		return nil, fmt.Errorf("prefix must be at most 12 characters without spaces or slashes")
+	}
+	if padding < 0 || padding > 12 {
+		return nil, fmt.Errorf("padding must be between 0 and 12")
+	}
+
+	var seq *core.Record
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		sequences, err := txApp.FindRecordsByFilter(
+			models.CName[models.DocumentSequences](),
+			"company = {:company} && document_type = {:type}",
+			"-year",
+			0,
+			0,
+			dbx.Params{"company": companyID, "type": docType},
+		)
+		if err != nil {
+			return err
+		}
+		if len(sequences) == 0 {
+			collection, err := txApp.FindCollectionByNameOrId(models.CName[models.DocumentSequences]())
+			if err != nil {
+				return err
+			}
+			created := core.NewRecord(collection)
+			created.Set("company", companyID)
+			created.Set("document_type", docType)
+			created.Set("year", time.Now().UTC().Year())
+			created.Set("next_number", 1)
+			sequences = append(sequences, created)
+		}
+		// every year's counter numbers the same way
+		for _, counter := range sequences {
+			counter.Set("prefix", prefix)
+			counter.Set("reset_yearly", resetYearly)
+			counter.Set("padding", padding)
+			if err := txApp.Save(counter); err != nil {
+				return err
+			}
+		}
+		seq = sequences[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return models.WrapRecord[models.DocumentSequences](seq)
+}
+
+// FetchDocumentSequences lists the numbering configured for the company,
+// with the latest counter of each document type.
+func (helper *DbHelper) FetchDocumentSequences(companyID string) ([]*models.DocumentSequences, error) {
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.DocumentSequences](),
+		"company = {:company}",
+		"document_type,-year",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	sequences := make([]*models.DocumentSequences, 0, len(records))
+	for i, record := range records {
+		if i > 0 && records[i-1].GetString("document_type") == record.GetString("document_type") {
+			continue
+		}
+		seq, err := models.WrapRecord[models.DocumentSequences](record)
+		if err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences, nil
+}
@@ -0,0 +1,340 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// partnerNameThreshold is the name similarity, from 0 to 1, at which two
+// partners are reported as possible duplicates.
+const partnerNameThreshold = 0.85
+
+// PartnerMatch is a pair of partners that are probably the same person or
+// business. Reason is "phone" when their phone numbers match, else "name".
+type PartnerMatch struct {
+	Partner *models.Partners `json:"partner,omitempty"`
+	Match   *models.Partners `json:"match"`
+	Reason  string           `json:"reason"`
+	Score   float64          `json:"score"`
+}
+
+// normalizePhone reduces a phone number to its digits in international
+// form, so "0712 345 678", "+254712345678" and "712345678" compare equal.
+// Local numbers are assumed to be Kenyan.
+func normalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+	switch {
+	case len(digits) == 10 && digits[0] == '0':
+		return "254" + digits[1:]
+	case len(digits) == 9 && (digits[0] == '7' || digits[0] == '1'):
+		return "254" + digits
+	}
+	return digits
+}
+
+// normalizeName lower-cases a name and drops punctuation and extra spaces.
+func normalizeName(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameSimilarity scores two names from 0 to 1 by edit distance, ignoring
+// case, punctuation and the order of the words.
+func nameSimilarity(a, b string) float64 {
+	ta, tb := normalizeName(a), normalizeName(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	score := similarity(strings.Join(ta, " "), strings.Join(tb, " "))
+	slices.Sort(ta)
+	slices.Sort(tb)
+	return max(score, similarity(strings.Join(ta, " "), strings.Join(tb, " ")))
+}
+
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// matchPartners compares a name and phone with a partner.
+func matchPartners(name, phone string, other *core.Record) (string, float64, bool) {
+	if p := normalizePhone(phone); len(p) >= 9 && p == normalizePhone(other.GetString("phone")) {
+		return "phone", 1, true
+	}
+	if score := nameSimilarity(name, other.GetString("name")); score >= partnerNameThreshold {
+		return "name", score, true
+	}
+	return "", 0, false
+}
+
+func (helper *DbHelper) companyPartners(companyID string) ([]*core.Record, error) {
+	return helper.pb.FindRecordsByFilter(
+		models.CName[models.Partners](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+}
+
+// FindDuplicatePartners lists every pair of the company's partners that
+// share a phone number or have very similar names, the oldest partner of
+// each pair first.
+func (helper *DbHelper) FindDuplicatePartners(companyID string) ([]PartnerMatch, error) {
+	partners, err := helper.companyPartners(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []PartnerMatch{}
+	for i, a := range partners {
+		for _, b := range partners[i+1:] {
+			reason, score, ok := matchPartners(a.GetString("name"), a.GetString("phone"), b)
+			if !ok {
+				continue
+			}
+			partner, _ := models.WrapRecord[models.Partners](a)
+			match, _ := models.WrapRecord[models.Partners](b)
+			matches = append(matches, PartnerMatch{Partner: partner, Match: match, Reason: reason, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
+}
+
+// MatchPartner lists the existing partners a new name and phone probably
+// refer to, so a cashier can pick one instead of creating a duplicate.
+func (helper *DbHelper) MatchPartner(companyID, name, phone string) ([]PartnerMatch, error) {
+	partners, err := helper.companyPartners(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []PartnerMatch{}
+	for _, p := range partners {
+		reason, score, ok := matchPartners(name, phone, p)
+		if !ok {
+			continue
+		}
+		match, _ := models.WrapRecord[models.Partners](p)
+		matches = append(matches, PartnerMatch{Match: match, Reason: reason, Score: score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
+}
+
+// PartnerMerge is the outcome of merging duplicates into one partner.
+// Repointed counts the records moved per collection and field.
+type PartnerMerge struct {
+	Partner   *models.Partners `json:"partner"`
+	Merged    []string         `json:"merged"`
+	Repointed map[string]int   `json:"repointed"`
+}
+
+// MergePartners folds the duplicates into the surviving partner: every
+// relation to a duplicate (see models.Relations) and every payment booked
+// against it is moved to the survivor, credits are added up, the balance
+// is recomputed and the duplicates are deleted. The merge is written to the
+// audit log with the duplicates' details. Only managers may merge.
+//
+// Relations are moved with direct updates, not record saves, so the credit
+// limit and period lock hooks, which guard new business, do not stop
+// history from being re-pointed.
+func (helper *DbHelper) MergePartners(companyID, survivorID string, duplicateIDs []string, userID string) (*PartnerMerge, error) {
+	if len(duplicateIDs) == 0 {
+		return nil, fmt.Errorf("no partners to merge")
+	}
+	if slices.Contains(duplicateIDs, survivorID) {
+		return nil, fmt.Errorf("a partner cannot be merged into itself")
+	}
+	if err := helper.requireManager(userID, companyID); err != nil {
+		return nil, err
+	}
+
+	result := &PartnerMerge{Repointed: map[string]int{}}
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		survivor, err := txApp.FindRecordById(models.CName[models.Partners](), survivorID)
+		if err != nil {
+			return fmt.Errorf("partner %s not found: %w", survivorID, err)
+		}
+		if survivor.GetString("company") != companyID {
+			return fmt.Errorf("partner %s does not belong to the company", survivorID)
+		}
+		before := partnerAuditDetails(survivor)
+
+		var merged []map[string]any
+		for _, id := range duplicateIDs {
+			duplicate, err := txApp.FindRecordById(models.CName[models.Partners](), id)
+			if err != nil {
+				return fmt.Errorf("partner %s not found: %w", id, err)
+			}
+			if duplicate.GetString("company") != companyID {
+				return fmt.Errorf("partner %s does not belong to the company", id)
+			}
+			if err := repointPartner(txApp, id, survivorID, result.Repointed); err != nil {
+				return err
+			}
+
+			survivor.Set("credit", roundMoney(survivor.GetFloat("credit")+duplicate.GetFloat("credit")))
+			survivor.Set("credit_limit", max(survivor.GetFloat("credit_limit"), duplicate.GetFloat("credit_limit")))
+			if survivor.GetString("phone") == "" {
+				survivor.Set("phone", duplicate.GetString("phone"))
+			}
+			if role := duplicate.GetString("role"); role != "" && role != survivor.GetString("role") && survivor.GetString("role") != "" {
+				survivor.Set("role", "both")
+			}
+
+			merged = append(merged, partnerAuditDetails(duplicate))
+			if err := txApp.Delete(duplicate); err != nil {
+				return fmt.Errorf("failed to delete merged partner %s: %w", id, err)
+			}
+			result.Merged = append(result.Merged, id)
+		}
+
+		if err := txApp.Save(survivor); err != nil {
+			return err
+		}
+		if err := recomputePartnerBalance(txApp, survivorID); err != nil {
+			return err
+		}
+		if err := recomputeLoyalty(txApp, survivorID); err != nil {
+			return err
+		}
+		if survivor, err = txApp.FindRecordById(models.CName[models.Partners](), survivorID); err != nil {
+			return err
+		}
+		if result.Partner, err = models.WrapRecord[models.Partners](survivor); err != nil {
+			return err
+		}
+
+		return writeAuditLog(txApp, companyID, userID, "partner.merge", models.CName[models.Partners](), survivorID, map[string]any{
+			"before":    before,
+			"after":     partnerAuditDetails(survivor),
+			"merged":    merged,
+			"repointed": result.Repointed,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func partnerAuditDetails(partner *core.Record) map[string]any {
+	return map[string]any{
+		"id":      partner.Id,
+		"name":    partner.GetString("name"),
+		"phone":   partner.GetString("phone"),
+		"balance": partner.GetFloat("balance"),
+		"credit":  partner.GetFloat("credit"),
+	}
+}
+
+// repointPartner moves every relation and payment of one partner to another.
+func repointPartner(txApp core.App, fromID, toID string, counts map[string]int) error {
+	partners := models.CName[models.Partners]()
+	for collection, targets := range models.Relations {
+		for _, field := range targets[partners] {
+			var n int
+			var err error
+			if field.IsMulti {
+				n, err = repointMultiRelation(txApp, collection, field.FieldName, fromID, toID)
+			} else {
+				n, err = updateColumn(txApp, collection, field.FieldName, fromID, toID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to move %s.%s: %w", collection, field.FieldName, err)
+			}
+			if n > 0 {
+				counts[collection+"."+field.FieldName] += n
+			}
+		}
+	}
+
+	// payments booked against the partner rather than an invoice
+	n, err := updateColumn(txApp, models.CName[models.Transactions](), "reference_id", fromID, toID)
+	if err != nil {
+		return fmt.Errorf("failed to move partner payments: %w", err)
+	}
+	if n > 0 {
+		counts["transactions.reference_id"] += n
+	}
+	return nil
+}
+
+func updateColumn(txApp core.App, table, column, from, to string) (int, error) {
+	res, err := txApp.DB().Update(table, dbx.Params{column: to}, dbx.HashExp{column: from}).Execute()
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func repointMultiRelation(txApp core.App, collection, field, from, to string) (int, error) {
+	records, err := txApp.FindRecordsByFilter(collection, field+" ~ {:id}", "", 0, 0, dbx.Params{"id": from})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, record := range records {
+		ids := record.GetStringSlice(field)
+		if !slices.Contains(ids, from) {
+			continue
+		}
+		for i := range ids {
+			if ids[i] == from {
+				ids[i] = to
+			}
+		}
+		slices.Sort(ids)
+		raw, err := json.Marshal(slices.Compact(ids))
+		if err != nil {
+			return n, err
+		}
+		if _, err := txApp.DB().Update(collection, dbx.Params{field: string(raw)}, dbx.HashExp{"id": record.Id}).Execute(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,239 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ManagerLevel is the lowest user level allowed to approve credit over a
+// customer's limit. Staff accounts keep the default level 0.
+const ManagerLevel = 1
+
+// creditOverrideTTL is how long a manager's approval stays usable at the till.
+const creditOverrideTTL = 15 * time.Minute
+
+// ErrCreditLimitExceeded is returned (wrapped) when a sale on credit would
+// take the customer over their credit limit without a manager override.
+var ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+
+// partnerHasRole reports whether the partner may act in the role. Partners
+// created before roles existed have none and may act in either.
+func partnerHasRole(partner *core.Record, role string) bool {
+	switch partner.GetString("role") {
+	case "", "both":
+		return true
+	default:
+		return partner.GetString("role") == role
+	}
+}
+
+// BindPartnerRules registers the record hooks that enforce customer credit
+// limits on sales, stamp invoice due dates from the partner's payment terms
+// and keep partner balances current as credit sales change.
+func (helper *DbHelper) BindPartnerRules() {
+	sales := models.CName[models.SalesTransactions]()
+
+	helper.pb.OnRecordCreate(sales).BindFunc(func(e *core.RecordEvent) error {
+		if err := checkCreditLimit(e.App, e.Record, nil); err != nil {
+			return err
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if err := markCreditOverrideUsed(e.App, e.Record); err != nil {
+			return err
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("customer"))
+	})
+
+	helper.pb.OnRecordUpdate(sales).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if err := checkCreditLimit(e.App, e.Record, original); err != nil {
+			return err
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if err := markCreditOverrideUsed(e.App, e.Record); err != nil {
+			return err
+		}
+		if previous := original.GetString("customer"); previous != e.Record.GetString("customer") {
+			if err := recomputePartnerBalance(e.App, previous); err != nil {
+				return err
+			}
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("customer"))
+	})
+
+	helper.pb.OnRecordDelete(sales).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return recomputePartnerBalance(e.App, e.Record.GetString("customer"))
+	})
+
+	helper.pb.OnRecordCreate(models.CName[models.Invoices]()).BindFunc(func(e *core.RecordEvent) error {
+		if err := stampDueDate(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+}
+
+// checkCreditLimit rejects a sale whose unpaid remainder would take the
+// customer's balance over their credit limit, unless it carries an unused,
+// unexpired manager override large enough to cover the excess. A limit of
+// zero means the customer has no limit.
+func checkCreditLimit(app core.App, sale, original *core.Record) error {
+	customerID := sale.GetString("customer")
+	owed := BaseAmount(sale, "remaining_balance")
+	if customerID == "" || owed <= 0 {
+		return nil
+	}
+	if original != nil && original.GetString("customer") == customerID {
+		// only the increase is new credit
+		owed -= BaseAmount(original, "remaining_balance")
+		if owed <= 0 {
+			return nil
+		}
+	}
+
+	customer, err := app.FindRecordById(models.CName[models.Partners](), customerID)
+	if err != nil {
+		return fmt.Errorf("customer %s not found: %w", customerID, err)
+	}
+	if !partnerHasRole(customer, "customer") {
+		return fmt.Errorf("%s is not a customer and cannot buy on credit", customer.GetString("name"))
+	}
+	limit := customer.GetFloat("credit_limit")
+	if limit <= 0 {
+		return nil
+	}
+	excess := roundMoney(customer.GetFloat("balance") + owed - limit)
+	if excess <= 0 {
+		return nil
+	}
+
+	overrideID := sale.GetString("credit_override")
+	if overrideID == "" || (original != nil && original.GetString("credit_override") == overrideID) {
+		return fmt.Errorf("%w: %s would owe %s over the limit of %s; a manager must approve",
+			ErrCreditLimitExceeded, customer.GetString("name"), formatAmount(excess), formatAmount(limit))
+	}
+	override, err := app.FindRecordById(models.CName[models.CreditOverrides](), overrideID)
+	if err != nil {
+		return fmt.Errorf("credit override %s not found: %w", overrideID, err)
+	}
+	switch {
+	case override.GetString("partner") != customerID:
+		return fmt.Errorf("credit override %s was approved for another customer", overrideID)
+	case !override.GetDateTime("used_at").IsZero():
+		return fmt.Errorf("credit override %s has already been used", overrideID)
+	case override.GetDateTime("expires_at").Time().Before(time.Now()):
+		return fmt.Errorf("credit override %s has expired", overrideID)
+	case override.GetFloat("amount") < excess:
+		return fmt.Errorf("%w: credit override covers %s but the sale exceeds the limit by %s",
+			ErrCreditLimitExceeded, formatAmount(override.GetFloat("amount")), formatAmount(excess))
+	}
+	return nil
+}
+
+func markCreditOverrideUsed(app core.App, sale *core.Record) error {
+	overrideID := sale.GetString("credit_override")
+	if overrideID == "" {
+		return nil
+	}
+	override, err := app.FindRecordById(models.CName[models.CreditOverrides](), overrideID)
+	if err != nil {
+		return err
+	}
+	if !override.GetDateTime("used_at").IsZero() {
+		return nil
+	}
+	override.Set("used_at", types.NowDateTime())
+	override.Set("sale", sale.Id)
+	return app.Save(override)
+}
+
+// stampDueDate sets a new invoice's due date from the partner's payment
+// terms when none was given.
+func stampDueDate(app core.App, invoice *core.Record) error {
+	if !invoice.GetDateTime("due_date").IsZero() || invoice.GetString("partner") == "" {
+		return nil
+	}
+	partner, err := app.FindRecordById(models.CName[models.Partners](), invoice.GetString("partner"))
+	if err != nil {
+		return err
+	}
+	date := invoice.GetDateTime("date")
+	if date.IsZero() {
+		date = types.NowDateTime()
+	}
+	due, err := types.ParseDateTime(date.Time().AddDate(0, 0, partner.GetInt("payment_terms_days")))
+	if err != nil {
+		return err
+	}
+	invoice.Set("due_date", due)
+	return nil
+}
+
+// requireManager fails unless the user is a manager of the company.
+func (helper *DbHelper) requireManager(userID, companyID string) error {
+	user, err := helper.pb.FindRecordById(models.CName[models.Users](), userID)
+	if err != nil {
+		return err
+	}
+	if user.GetFloat("level") < ManagerLevel || !slices.Contains(user.GetStringSlice("company"), companyID) {
+		return fmt.Errorf("this action needs a manager of the company")
+	}
+	return nil
+}
+
+// ApproveCreditOverride lets a manager allow one sale to take the customer
+// up to amount over their credit limit. The approval expires if it is not
+// used at the till within a few minutes.
+func (helper *DbHelper) ApproveCreditOverride(companyID, partnerID, managerID string, amount float64, reason string) (*models.CreditOverrides, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to override a credit limit")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("override amount must be positive")
+	}
+
+	if err := helper.requireManager(managerID, companyID); err != nil {
+		return nil, err
+	}
+	partner, err := helper.pb.FindRecordById(models.CName[models.Partners](), partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("partner %s not found: %w", partnerID, err)
+	}
+	if partner.GetString("company") != companyID {
+		return nil, fmt.Errorf("partner %s does not belong to the company", partnerID)
+	}
+
+	expires, err := types.ParseDateTime(time.Now().Add(creditOverrideTTL))
+	if err != nil {
+		return nil, err
+	}
+	override, err := models.NewProxy[models.CreditOverrides](helper.pb)
+	if err != nil {
+		return nil, err
+	}
+	override.Set("company", companyID)
+	override.Set("partner", partnerID)
+	override.Set("approved_by", managerID)
+	override.SetAmount(roundMoney(amount))
+	override.SetReason(reason)
+	override.SetExpiresAt(expires)
+	if err := helper.pb.Save(override); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
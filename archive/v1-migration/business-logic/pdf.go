@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/kisinga/dukahub/models"
+)
+
+// pdfDocument wraps an A4 portrait fpdf document with the core Helvetica
+// font. Text is translated to the cp1252 encoding the core fonts use.
+type pdfDocument struct {
+	*fpdf.Fpdf
+	tr func(string) string
+}
+
+func newPDFDocument() *pdfDocument {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	return &pdfDocument{Fpdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+// text writes a full-width line in the given style ("", "B", "I") and size.
+func (d *pdfDocument) text(style string, size float64, s string) {
+	d.SetFont("Helvetica", style, size)
+	d.CellFormat(0, size*0.5, d.tr(s), "", 1, "L", false, 0, "")
+}
+
+// companyHeader writes the company name and its contact details.
+func (d *pdfDocument) companyHeader(company *models.Companies) {
+	d.text("B", 16, company.Name())
+	var details []string
+	for _, v := range []string{company.Address(), company.Location(), company.Phone()} {
+		if v = strings.TrimSpace(v); v != "" {
+			details = append(details, v)
+		}
+	}
+	if len(details) > 0 {
+		d.text("", 9, strings.Join(details, " | "))
+	}
+	if taxID := strings.TrimSpace(company.TaxId()); taxID != "" {
+		d.text("", 9, "Tax ID: "+taxID)
+	}
+	d.Ln(4)
+}
+
+// table writes a header row and body rows. Columns are sized by widths (in
+// mm); columns listed in right are right-aligned, as amounts are.
+func (d *pdfDocument) table(headers []string, rows [][]string, widths []float64, right map[int]bool) {
+	align := func(i int) string {
+		if right[i] {
+			return "R"
+		}
+		return "L"
+	}
+	d.SetFont("Helvetica", "B", 9)
+	d.SetFillColor(235, 235, 235)
+	for i, h := range headers {
+		d.CellFormat(widths[i], 7, d.tr(h), "B", 0, align(i), true, 0, "")
+	}
+	d.Ln(-1)
+	d.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		for i, v := range row {
+			d.CellFormat(widths[i], 6, d.tr(v), "", 0, align(i), false, 0, "")
+		}
+		d.Ln(-1)
+	}
+}
+
+// amountRow writes a label and an amount aligned to the right edge, as in
+// the totals under an invoice.
+func (d *pdfDocument) amountRow(label, amount string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.SetFont("Helvetica", style, 10)
+	d.CellFormat(150, 6, d.tr(label), "", 0, "R", false, 0, "")
+	d.CellFormat(30, 6, d.tr(amount), "", 1, "R", false, 0, "")
+}
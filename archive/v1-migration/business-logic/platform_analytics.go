@@ -0,0 +1,325 @@
+package lib
+
+import (
+	"sort"
+	"time"
+)
+
+// tenantRankSize is how many companies are listed as most and least active.
+const tenantRankSize = 5
+
+// saleBaseAmountSQL is a sale's total in its company's base currency, with
+// returns counted against sales, for use in aggregate queries.
+const saleBaseAmountSQL = `(CASE WHEN transaction_type = 'return' THEN -ABS(total_amount) ELSE total_amount END) *
+	(CASE WHEN exchange_rate > 0 THEN exchange_rate ELSE 1 END)`
+
+// ActivityPoint is the activity in one period of a series.
+type ActivityPoint struct {
+	Period      string    `json:"period"`
+	From        time.Time `json:"from"`
+	Sales       int       `json:"sales"`
+	Revenue     float64   `json:"revenue"`
+	NewProducts int       `json:"new_products"`
+	NewPhotos   int       `json:"new_photos"`
+}
+
+// TenantActivity is one company's activity over the range. Revenue is in
+// the company's base currency; RevenueGrowth compares it with the range of
+// the same length just before, in percent, and is nil when there was no
+// revenue then.
+type TenantActivity struct {
+	CompanyID       string          `json:"company_id"`
+	Name            string          `json:"name"`
+	Currency        string          `json:"currency"`
+	Created         time.Time       `json:"created"`
+	Sales           int             `json:"sales"`
+	Revenue         float64         `json:"revenue"`
+	PreviousRevenue float64         `json:"previous_revenue"`
+	RevenueGrowth   *float64        `json:"revenue_growth"`
+	Users           int             `json:"users"`
+	ActiveUsers     int             `json:"active_users"`
+	Products        int             `json:"products"`
+	NewProducts     int             `json:"new_products"`
+	Photos          int             `json:"photos"`
+	NewPhotos       int             `json:"new_photos"`
+	Series          []ActivityPoint `json:"series"`
+}
+
+// PlatformTotals add up every company. Revenue mixes the companies' base
+// currencies and is only a rough measure of volume.
+type PlatformTotals struct {
+	Companies       int      `json:"companies"`
+	NewCompanies    int      `json:"new_companies"`
+	ActiveCompanies int      `json:"active_companies"`
+	Users           int      `json:"users"`
+	ActiveUsers     int      `json:"active_users"`
+	Products        int      `json:"products"`
+	NewProducts     int      `json:"new_products"`
+	Photos          int      `json:"photos"`
+	NewPhotos       int      `json:"new_photos"`
+	Sales           int      `json:"sales"`
+	Revenue         float64  `json:"revenue"`
+	PreviousRevenue float64  `json:"previous_revenue"`
+	RevenueGrowth   *float64 `json:"revenue_growth"`
+}
+
+// PlatformAnalytics is the super admins' view of every company's activity
+// over a date range. Series are per day for ranges up to a month, per week
+// up to half a year and per month beyond. A company's activity is its number
+// of sales, then its active users, then its new products.
+type PlatformAnalytics struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Interval    string           `json:"interval"`
+	Totals      PlatformTotals   `json:"totals"`
+	Series      []ActivityPoint  `json:"series"`
+	Tenants     []TenantActivity `json:"tenants"`
+	MostActive  []TenantActivity `json:"most_active"`
+	LeastActive []TenantActivity `json:"least_active"`
+}
+
+func seriesInterval(days DateRange) string {
+	switch n := days.Days(); {
+	case n <= 31:
+		return "day"
+	case n <= 182:
+		return "week"
+	}
+	return "month"
+}
+
+// newSeries returns an empty point for every period of the interval that
+// overlaps the range, and an index from period label to point.
+func newSeries(days DateRange, interval string) ([]ActivityPoint, map[string]int) {
+	var series []ActivityPoint
+	index := map[string]int{}
+	for date := days.From; date.Before(days.To); {
+		period, _ := periodOf(interval, date, time.UTC)
+		index[period.Label] = len(series)
+		series = append(series, ActivityPoint{Period: period.Label, From: period.Start})
+		date = period.End
+	}
+	return series, index
+}
+
+type dailyCount struct {
+	Company string  `db:"company"`
+	Day     string  `db:"day"`
+	Count   int     `db:"n"`
+	Amount  float64 `db:"amount"`
+}
+
+type companyCount struct {
+	Company string  `db:"company"`
+	Count   int     `db:"n"`
+	Amount  float64 `db:"amount"`
+}
+
+// FetchPlatformAnalytics gathers sales, users, products and photos of every
+// company over the range, with platform totals and the most and least
+// active companies.
+func (helper *DbHelper) FetchPlatformAnalytics(days DateRange) (*PlatformAnalytics, error) {
+	db := helper.pb.DB()
+	previous := DateRange{From: days.From.AddDate(0, 0, -days.Days()), To: days.From}
+	params := days.Params()
+	params["previous"] = formatDate(previous.From)
+
+	var sales []dailyCount
+	err := db.NewQuery(`SELECT company, substr(transaction_date, 1, 10) AS day, COUNT(*) AS n, SUM(` + saleBaseAmountSQL + `) AS amount
+		FROM sales_transactions
+		WHERE transaction_date >= {:from} AND transaction_date < {:to}
+		GROUP BY company, day`).Bind(params).All(&sales)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousSales []companyCount
+	err = db.NewQuery(`SELECT company, COUNT(*) AS n, SUM(` + saleBaseAmountSQL + `) AS amount
+		FROM sales_transactions
+		WHERE transaction_date >= {:previous} AND transaction_date < {:from}
+		GROUP BY company`).Bind(params).All(&previousSales)
+	if err != nil {
+		return nil, err
+	}
+
+	var activeUsers []companyCount
+	err = db.NewQuery(`SELECT company, COUNT(DISTINCT salesperson) AS n, 0 AS amount
+		FROM sales_transactions
+		WHERE salesperson != '' AND transaction_date >= {:from} AND transaction_date < {:to}
+		GROUP BY company`).Bind(params).All(&activeUsers)
+	if err != nil {
+		return nil, err
+	}
+	var platformActiveUsers int
+	err = db.NewQuery(`SELECT COUNT(DISTINCT salesperson)
+		FROM sales_transactions
+		WHERE salesperson != '' AND transaction_date >= {:from} AND transaction_date < {:to}`).Bind(params).Row(&platformActiveUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	// users may belong to several companies
+	var users []companyCount
+	err = db.NewQuery(`SELECT c.value AS company, COUNT(*) AS n, 0 AS amount
+		FROM users, json_each(CASE WHEN json_valid(users.company) THEN users.company ELSE json_array(users.company) END) c
+		WHERE users.created < {:to}
+		GROUP BY c.value`).Bind(params).All(&users)
+	if err != nil {
+		return nil, err
+	}
+	var platformUsers int
+	err = db.NewQuery(`SELECT COUNT(*) FROM users WHERE created < {:to}`).Bind(params).Row(&platformUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	// photos are counted with the product they were added to
+	var products []dailyCount
+	err = db.NewQuery(`SELECT company, substr(created, 1, 10) AS day, COUNT(*) AS n,
+			SUM(CASE WHEN json_valid(photos) THEN json_array_length(photos) ELSE 0 END) AS amount
+		FROM products
+		WHERE created < {:to}
+		GROUP BY company, day`).Bind(params).All(&products)
+	if err != nil {
+		return nil, err
+	}
+
+	companies, err := helper.FetchAllCompanies()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PlatformAnalytics{From: days.From, To: days.To, Interval: seriesInterval(days)}
+	var index map[string]int
+	report.Series, index = newSeries(days, report.Interval)
+	bucket := func(day string) (int, bool) {
+		t, err := time.Parse(time.DateOnly, day)
+		if err != nil || !days.Contains(t) {
+			return 0, false
+		}
+		period, _ := periodOf(report.Interval, t, time.UTC)
+		i, ok := index[period.Label]
+		return i, ok
+	}
+
+	tenants := map[string]*TenantActivity{}
+	for _, company := range companies {
+		currency := company.BaseCurrency()
+		if currency == "" {
+			currency = DefaultBaseCurrency
+		}
+		t := &TenantActivity{
+			CompanyID: company.Id,
+			Name:      company.Name(),
+			Currency:  currency,
+			Created:   company.Created().Time(),
+		}
+		t.Series, _ = newSeries(days, report.Interval)
+		tenants[company.Id] = t
+		report.Totals.Companies++
+		if days.Contains(t.Created) {
+			report.Totals.NewCompanies++
+		}
+	}
+
+	for _, row := range sales {
+		t := tenants[row.Company]
+		if t == nil {
+			continue
+		}
+		t.Sales += row.Count
+		t.Revenue += row.Amount
+		if i, ok := bucket(row.Day); ok {
+			t.Series[i].Sales += row.Count
+			t.Series[i].Revenue += row.Amount
+			report.Series[i].Sales += row.Count
+			report.Series[i].Revenue += row.Amount
+		}
+	}
+	for _, row := range previousSales {
+		if t := tenants[row.Company]; t != nil {
+			t.PreviousRevenue = roundMoney(row.Amount)
+		}
+	}
+	for _, row := range activeUsers {
+		if t := tenants[row.Company]; t != nil {
+			t.ActiveUsers = row.Count
+		}
+	}
+	for _, row := range users {
+		if t := tenants[row.Company]; t != nil {
+			t.Users = row.Count
+		}
+	}
+	for _, row := range products {
+		t := tenants[row.Company]
+		if t == nil {
+			continue
+		}
+		photos := int(row.Amount)
+		t.Products += row.Count
+		t.Photos += photos
+		if i, ok := bucket(row.Day); ok {
+			t.NewProducts += row.Count
+			t.NewPhotos += photos
+			t.Series[i].NewProducts += row.Count
+			t.Series[i].NewPhotos += photos
+			report.Series[i].NewProducts += row.Count
+			report.Series[i].NewPhotos += photos
+		}
+	}
+
+	totals := &report.Totals
+	totals.Users = platformUsers
+	totals.ActiveUsers = platformActiveUsers
+	for _, t := range tenants {
+		t.Revenue = roundMoney(t.Revenue)
+		t.RevenueGrowth = percentChange(t.Revenue, t.PreviousRevenue)
+		for i := range t.Series {
+			t.Series[i].Revenue = roundMoney(t.Series[i].Revenue)
+		}
+		if t.Sales > 0 {
+			totals.ActiveCompanies++
+		}
+		totals.Products += t.Products
+		totals.NewProducts += t.NewProducts
+		totals.Photos += t.Photos
+		totals.NewPhotos += t.NewPhotos
+		totals.Sales += t.Sales
+		totals.Revenue += t.Revenue
+		totals.PreviousRevenue += t.PreviousRevenue
+		report.Tenants = append(report.Tenants, *t)
+	}
+	totals.Revenue = roundMoney(totals.Revenue)
+	totals.PreviousRevenue = roundMoney(totals.PreviousRevenue)
+	totals.RevenueGrowth = percentChange(totals.Revenue, totals.PreviousRevenue)
+	for i := range report.Series {
+		report.Series[i].Revenue = roundMoney(report.Series[i].Revenue)
+	}
+
+	sort.Slice(report.Tenants, func(i, j int) bool {
+		a, b := report.Tenants[i], report.Tenants[j]
+		if a.Sales != b.Sales {
+			return a.Sales > b.Sales
+		}
+		if a.ActiveUsers != b.ActiveUsers {
+			return a.ActiveUsers > b.ActiveUsers
+		}
+		if a.NewProducts != b.NewProducts {
+			return a.NewProducts > b.NewProducts
+		}
+		return a.Name < b.Name
+	})
+	report.MostActive = report.Tenants[:min(tenantRankSize, len(report.Tenants))]
+	// the least active are ranked from the companies not already listed as
+	// the most active
+	n := min(tenantRankSize, len(report.Tenants)-len(report.MostActive))
+	report.LeastActive = make([]TenantActivity, 0, n)
+	for i := len(report.Tenants) - 1; i >= len(report.Tenants)-n; i-- {
+		report.LeastActive = append(report.LeastActive, report.Tenants[i])
+	}
+	if report.Tenants == nil {
+		report.Tenants = []TenantActivity{}
+	}
+	return report, nil
+}
@@ -0,0 +1,605 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// analyticsPeriod is a day, an ISO week starting on Monday or a calendar
+// month, in the company's timezone. Label is the product_analytics period:
+// "2024-07-15", "2024-W29" or "2024-07".
+type analyticsPeriod struct {
+	Type  string
+	Start time.Time
+	End   time.Time
+	Label string
+}
+
+// periodOf returns the period of the type that t falls in, where loc is.
+func periodOf(periodType string, t time.Time, loc *time.Location) (analyticsPeriod, error) {
+	day := startOfDay(t.In(loc))
+	switch periodType {
+	case "day":
+		return analyticsPeriod{periodType, day, day.AddDate(0, 0, 1), day.Format(time.DateOnly)}, nil
+	case "week":
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		year, week := start.ISOWeek()
+		return analyticsPeriod{periodType, start, start.AddDate(0, 0, 7), fmt.Sprintf("%d-W%02d", year, week)}, nil
+	case "month":
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, loc)
+		return analyticsPeriod{periodType, start, start.AddDate(0, 1, 0), start.Format("2006-01")}, nil
+	}
+	return analyticsPeriod{}, fmt.Errorf("unknown period %q, expected day, week or month", periodType)
+}
+
+func (p analyticsPeriod) previous() analyticsPeriod {
+	prev, _ := periodOf(p.Type, p.Start.AddDate(0, 0, -1), p.Start.Location())
+	return prev
+}
+
+// localDate is the calendar day of date, such as a day given as YYYY-MM-DD,
+// starting at midnight where loc is.
+func localDate(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// ProductFigures are a product's sales over a period, in the base currency.
+// Cost is valued at the inventory cost price when the figures were built.
+type ProductFigures struct {
+	UnitsSold       float64 `json:"units_sold"`
+	Revenue         float64 `json:"revenue"`
+	Cost            float64 `json:"cost"`
+	Profit          float64 `json:"profit"`
+	AvgSellingPrice float64 `json:"avg_selling_price"`
+}
+
+func (f *ProductFigures) add(units, revenue, cost float64) {
+	f.UnitsSold += units
+	f.Revenue += revenue
+	f.Cost += cost
+}
+
+func (f *ProductFigures) finish() {
+	f.UnitsSold = roundMoney(f.UnitsSold)
+	f.Revenue = roundMoney(f.Revenue)
+	f.Cost = roundMoney(f.Cost)
+	f.Profit = roundMoney(f.Revenue - f.Cost)
+	f.AvgSellingPrice = 0
+	if f.UnitsSold != 0 {
+		f.AvgSellingPrice = roundMoney(f.Revenue / f.UnitsSold)
+	}
+}
+
+func (f *ProductFigures) isZero() bool {
+	return f.UnitsSold == 0 && f.Revenue == 0 && f.Cost == 0
+}
+
+// productCosts returns the inventory cost price of each product and SKU of
+// the company. The "" SKU holds a product's cost whatever the SKU.
+func productCosts(app core.App, companyID string) (map[string]map[string]float64, error) {
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.Inventory](),
+		"company = {:company}",
+		"created",
+		0,
+		0,
+		dbx.Params{"company": companyID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	costs := map[string]map[string]float64{}
+	for _, record := range records {
+		product := record.GetString("product")
+		if costs[product] == nil {
+			costs[product] = map[string]float64{"": record.GetFloat("cost_price")}
+		}
+		costs[product][record.GetString("sku")] = record.GetFloat("cost_price")
+	}
+	return costs, nil
+}
+
+// computeProductFigures adds up the sale lines dated in [from, to) per
+// product, for the given products or, when products is nil, for all of them.
+func computeProductFigures(app core.App, companyID string, from, to time.Time, products []string) (map[string]*ProductFigures, error) {
+	params := dbx.Params{"company": companyID, "from": formatDate(from), "to": formatDate(to)}
+	sales, err := app.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := app.ExpandRecords(sales, []string{"sales_details"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load sale lines: %v", errs)
+	}
+	costs, err := productCosts(app, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	figures := map[string]*ProductFigures{}
+	for _, id := range products {
+		figures[id] = &ProductFigures{}
+	}
+	for _, sale := range sales {
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		}
+		rate := sale.GetFloat("exchange_rate")
+		if rate <= 0 {
+			rate = 1
+		}
+		for _, line := range sale.ExpandedAll("sales_details") {
+			productID := line.GetString("product")
+			if productID == "" || (products != nil && !slices.Contains(products, productID)) {
+				continue
+			}
+			f := figures[productID]
+			if f == nil {
+				f = &ProductFigures{}
+				figures[productID] = f
+			}
+			cost, ok := costs[productID][line.GetString("sku")]
+			if !ok {
+				cost = costs[productID][""]
+			}
+			units := sign * math.Abs(line.GetFloat("quantity"))
+			f.add(units, units*line.GetFloat("unit_price")*rate, units*cost)
+		}
+	}
+	for _, f := range figures {
+		f.finish()
+	}
+	return figures, nil
+}
+
+// saveProductFigures stores a product's figures of a period, or removes the
+// period's row when the product sold nothing in it.
+func saveProductFigures(app core.App, companyID, productID string, period analyticsPeriod, f *ProductFigures) error {
+	record, err := app.FindFirstRecordByFilter(
+		models.CName[models.ProductAnalytics](),
+		"product = {:product} && period = {:period}",
+		dbx.Params{"product": productID, "period": period.Label},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if f.isZero() {
+		if record != nil {
+			return app.Delete(record)
+		}
+		return nil
+	}
+	if record == nil {
+		proxy, err := models.NewProxy[models.ProductAnalytics](app)
+		if err != nil {
+			return err
+		}
+		proxy.Set("company", companyID)
+		proxy.Set("product", productID)
+		proxy.Set("period", period.Label)
+		proxy.Set("period_type", period.Type)
+		proxy.Set("period_start", period.Start)
+		record = proxy.Record
+	}
+	record.Set("units_sold", f.UnitsSold)
+	record.Set("revenue", f.Revenue)
+	record.Set("cost", f.Cost)
+	record.Set("profit", f.Profit)
+	record.Set("avg_selling_price", f.AvgSellingPrice)
+	return app.Save(record)
+}
+
+// rollUpProducts rebuilds the week or month rows of the given products, or
+// of all products when products is nil, from their day rows.
+func rollUpProducts(app core.App, companyID string, period analyticsPeriod, products []string) error {
+	days, err := app.FindRecordsByFilter(
+		models.CName[models.ProductAnalytics](),
+		"company = {:company} && period_type = 'day' && period_start >= {:from} && period_start < {:to}",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID, "from": formatDate(period.Start), "to": formatDate(period.End)},
+	)
+	if err != nil {
+		return err
+	}
+
+	figures := map[string]*ProductFigures{}
+	for _, id := range products {
+		figures[id] = &ProductFigures{}
+	}
+	if products == nil {
+		// rows of products that no longer sold anything are cleared too
+		existing, err := app.FindRecordsByFilter(
+			models.CName[models.ProductAnalytics](),
+			"company = {:company} && period = {:period}",
+			"",
+			0,
+			0,
+			dbx.Params{"company": companyID, "period": period.Label},
+		)
+		if err != nil {
+			return err
+		}
+		for _, record := range existing {
+			figures[record.GetString("product")] = &ProductFigures{}
+		}
+	}
+	for _, day := range days {
+		productID := day.GetString("product")
+		f := figures[productID]
+		if f == nil {
+			if products != nil {
+				continue
+			}
+			f = &ProductFigures{}
+			figures[productID] = f
+		}
+		f.add(day.GetFloat("units_sold"), day.GetFloat("revenue"), day.GetFloat("cost"))
+	}
+
+	for productID, f := range figures {
+		f.finish()
+		if err := saveProductFigures(app, companyID, productID, period, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshProductAnalytics rebuilds the day, week and month rows of the
+// given products for the periods containing date.
+func refreshProductAnalytics(app core.App, companyID string, products []string, date time.Time) error {
+	if len(products) == 0 {
+		return nil
+	}
+	company, err := app.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return err
+	}
+	loc := companyLocation(company)
+	day, _ := periodOf("day", date, loc)
+	figures, err := computeProductFigures(app, companyID, day.Start, day.End, products)
+	if err != nil {
+		return err
+	}
+	for productID, f := range figures {
+		if err := saveProductFigures(app, companyID, productID, day, f); err != nil {
+			return err
+		}
+	}
+	for _, periodType := range []string{"week", "month"} {
+		period, _ := periodOf(periodType, date, loc)
+		if err := rollUpProducts(app, companyID, period, products); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saleProducts returns the products on a sale's lines.
+func saleProducts(app core.App, sale *core.Record) []string {
+	ids := sale.GetStringSlice("sales_details")
+	if len(ids) == 0 {
+		return nil
+	}
+	lines, err := app.FindRecordsByIds(models.CName[models.SalesDetails](), ids)
+	if err != nil {
+		return nil
+	}
+	var products []string
+	for _, line := range lines {
+		if id := line.GetString("product"); id != "" && !slices.Contains(products, id) {
+			products = append(products, id)
+		}
+	}
+	return products
+}
+
+// BindProductAnalytics registers the record hooks that keep the products'
+// day, week and month rows up to date as sales and sale lines are added,
+// changed or removed. Only the products and periods a change touches are
+// rebuilt, with the change's app, so they are saved or rolled back with it.
+func (helper *DbHelper) BindProductAnalytics() {
+	sales := models.CName[models.SalesTransactions]()
+
+	refresh := func(app core.App, sale *core.Record, products []string) {
+		date := sale.GetDateTime("transaction_date")
+		if sale.GetString("company") == "" || date.IsZero() {
+			return
+		}
+		if err := refreshProductAnalytics(app, sale.GetString("company"), products, date.Time()); err != nil {
+			helper.Logger.Printf("Error updating product analytics of %s: %v", date.Time().Format(time.DateOnly), err)
+		}
+	}
+
+	helper.pb.OnRecordCreate(sales).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		refresh(e.App, e.Record, saleProducts(e.App, e.Record))
+		return nil
+	})
+
+	helper.pb.OnRecordUpdate(sales).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		before := saleProducts(e.App, original)
+		if err := e.Next(); err != nil {
+			return err
+		}
+		products := append(slices.Clone(before), saleProducts(e.App, e.Record)...)
+		slices.Sort(products)
+		refresh(e.App, e.Record, slices.Compact(products))
+		if !original.GetDateTime("transaction_date").Time().Equal(e.Record.GetDateTime("transaction_date").Time()) {
+			refresh(e.App, original, before)
+		}
+		return nil
+	})
+
+	helper.pb.OnRecordDelete(sales).BindFunc(func(e *core.RecordEvent) error {
+		products := saleProducts(e.App, e.Record)
+		if err := e.Next(); err != nil {
+			return err
+		}
+		refresh(e.App, e.Record, products)
+		return nil
+	})
+
+	helper.pb.OnRecordUpdate(models.CName[models.SalesDetails]()).BindFunc(func(e *core.RecordEvent) error {
+		products := []string{e.Record.GetString("product")}
+		if previous := e.Record.Original().GetString("product"); previous != "" && previous != products[0] {
+			products = append(products, previous)
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		sales, err := e.App.FindRecordsByFilter(sales, "sales_details ~ {:line}", "", 0, 0, dbx.Params{"line": e.Record.Id})
+		if err != nil {
+			helper.Logger.Printf("Error finding the sale of line %s: %v", e.Record.Id, err)
+		}
+		for _, sale := range sales {
+			refresh(e.App, sale, products)
+		}
+		return nil
+	})
+}
+
+// RebuildProductAnalytics recomputes the product rows of every calendar day
+// in the range and of the weeks and months containing them, for one company
+// or, when companyID is empty, for every company. It returns the number of
+// days rebuilt.
+func (helper *DbHelper) RebuildProductAnalytics(companyID string, days DateRange) (int, error) {
+	companies, err := helper.summaryCompanies(companyID)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, company := range companies {
+		id, loc := company.Id, companyLocation(company)
+		err := helper.pb.RunInTransaction(func(txApp core.App) error {
+			rollups := map[string]analyticsPeriod{}
+			for date := days.From; date.Before(days.To); date = date.AddDate(0, 0, 1) {
+				day, _ := periodOf("day", localDate(date, loc), loc)
+				if err := rollUpDay(txApp, id, day); err != nil {
+					return err
+				}
+				for _, periodType := range []string{"week", "month"} {
+					period, _ := periodOf(periodType, localDate(date, loc), loc)
+					rollups[period.Label] = period
+				}
+				n++
+			}
+			for _, period := range rollups {
+				if err := rollUpProducts(txApp, id, period, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return n, fmt.Errorf("company %s: %w", id, err)
+		}
+	}
+	return n, nil
+}
+
+// rollUpDay rebuilds all product rows of a day, removing the rows of
+// products that no longer sold anything on it.
+func rollUpDay(app core.App, companyID string, day analyticsPeriod) error {
+	figures, err := computeProductFigures(app, companyID, day.Start, day.End, nil)
+	if err != nil {
+		return err
+	}
+	existing, err := app.FindRecordsByFilter(
+		models.CName[models.ProductAnalytics](),
+		"company = {:company} && period = {:period}",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID, "period": day.Label},
+	)
+	if err != nil {
+		return err
+	}
+	for _, record := range existing {
+		if _, ok := figures[record.GetString("product")]; !ok {
+			figures[record.GetString("product")] = &ProductFigures{}
+		}
+	}
+	for productID, f := range figures {
+		if err := saveProductFigures(app, companyID, productID, day, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProductAnalyticsQuery selects the period to rank products in. PeriodType
+// is "day", "week" or "month" and Date any calendar day in the period, today
+// where the company is when zero. The period is
+// compared with the one starting on CompareDate, or the one before it when
+// CompareDate is zero. SortBy is "revenue" (the default), "units_sold" or
+// "profit"; Category limits the products to one category.
+type ProductAnalyticsQuery struct {
+	PeriodType  string
+	Date        time.Time
+	CompareDate time.Time
+	Category    string
+	SortBy      string
+	Limit       int
+}
+
+// ProductRanking is a product's figures in the period and the period it is
+// compared with. The changes are percentages, nil when the product sold
+// nothing in the earlier period.
+type ProductRanking struct {
+	Rank    int    `json:"rank"`
+	Product string `json:"product"`
+	Name    string `json:"name"`
+	ProductFigures
+	Previous      ProductFigures `json:"previous"`
+	RevenueChange *float64       `json:"revenue_change"`
+	UnitsChange   *float64       `json:"units_change"`
+	ProfitChange  *float64       `json:"profit_change"`
+}
+
+// ProductAnalyticsReport ranks the company's products in a period.
+type ProductAnalyticsReport struct {
+	PeriodType string           `json:"period_type"`
+	Period     string           `json:"period"`
+	ComparedTo string           `json:"compared_to"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Products   []ProductRanking `json:"products"`
+}
+
+// QueryProductAnalytics ranks the company's products by their sales in a
+// period and compares each with an earlier period.
+func (helper *DbHelper) QueryProductAnalytics(companyID string, q ProductAnalyticsQuery) (*ProductAnalyticsReport, error) {
+	company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+	if err != nil {
+		return nil, err
+	}
+	loc := companyLocation(company)
+	if q.Date.IsZero() {
+		q.Date = time.Now().In(loc)
+	}
+	period, err := periodOf(q.PeriodType, localDate(q.Date, loc), loc)
+	if err != nil {
+		return nil, err
+	}
+	compare := period.previous()
+	if !q.CompareDate.IsZero() {
+		compare, _ = periodOf(q.PeriodType, localDate(q.CompareDate, loc), loc)
+	}
+	sortKey := func(f ProductFigures) float64 { return f.Revenue }
+	switch q.SortBy {
+	case "", "revenue":
+	case "units_sold":
+		sortKey = func(f ProductFigures) float64 { return f.UnitsSold }
+	case "profit":
+		sortKey = func(f ProductFigures) float64 { return f.Profit }
+	default:
+		return nil, fmt.Errorf("cannot sort by %q, expected revenue, units_sold or profit", q.SortBy)
+	}
+
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.ProductAnalytics](),
+		"company = {:company} && (period = {:period} || period = {:compare})",
+		"",
+		0,
+		0,
+		dbx.Params{"company": companyID, "period": period.Label, "compare": compare.Label},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := helper.pb.ExpandRecords(records, []string{"product"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load products: %v", errs)
+	}
+
+	rankings := map[string]*ProductRanking{}
+	for _, record := range records {
+		product := record.ExpandedOne("product")
+		if product == nil {
+			continue
+		}
+		if q.Category != "" && !slices.Contains(product.GetStringSlice("category"), q.Category) {
+			continue
+		}
+		r := rankings[product.Id]
+		if r == nil {
+			r = &ProductRanking{Product: product.Id, Name: product.GetString("name")}
+			rankings[product.Id] = r
+		}
+		f := ProductFigures{
+			UnitsSold:       record.GetFloat("units_sold"),
+			Revenue:         record.GetFloat("revenue"),
+			Cost:            record.GetFloat("cost"),
+			Profit:          record.GetFloat("profit"),
+			AvgSellingPrice: record.GetFloat("avg_selling_price"),
+		}
+		if record.GetString("period") == period.Label {
+			r.ProductFigures = f
+		} else {
+			r.Previous = f
+		}
+	}
+
+	report := &ProductAnalyticsReport{
+		PeriodType: period.Type,
+		Period:     period.Label,
+		ComparedTo: compare.Label,
+		From:       period.Start,
+		To:         period.End,
+		Products:   make([]ProductRanking, 0, len(rankings)),
+	}
+	for _, r := range rankings {
+		r.RevenueChange = percentChange(r.Revenue, r.Previous.Revenue)
+		r.UnitsChange = percentChange(r.UnitsSold, r.Previous.UnitsSold)
+		r.ProfitChange = percentChange(r.Profit, r.Previous.Profit)
+		report.Products = append(report.Products, *r)
+	}
+	sort.Slice(report.Products, func(i, j int) bool {
+		a, b := report.Products[i], report.Products[j]
+		if sortKey(a.ProductFigures) != sortKey(b.ProductFigures) {
+			return sortKey(a.ProductFigures) > sortKey(b.ProductFigures)
+		}
+		return a.Name < b.Name
+	})
+	if q.Limit > 0 && len(report.Products) > q.Limit {
+		report.Products = report.Products[:q.Limit]
+	}
+	for i := range report.Products {
+		report.Products[i].Rank = i + 1
+	}
+	return report, nil
+}
+
+// percentChange is the change from previous to current in percent, or nil
+// when there is nothing to compare with.
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := roundMoney((current - previous) / math.Abs(previous) * 100)
+	return &change
+}
@@ -0,0 +1,398 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// PurchaseOrderLineInput is one product ordered at an expected unit price,
+// in the order currency.
+type PurchaseOrderLineInput struct {
+	ProductID string  `json:"product"`
+	SkuID     string  `json:"sku"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+type PurchaseOrderRequest struct {
+	CompanyID    string
+	SupplierID   string
+	UserID       string
+	Currency     string
+	ExpectedDate types.DateTime
+	Notes        string
+	Lines        []PurchaseOrderLineInput
+}
+
+// PurchaseOrder is an order with its lines.
+type PurchaseOrder struct {
+	Order *models.PurchaseOrders       `json:"order"`
+	Lines []*models.PurchaseOrderLines `json:"lines"`
+}
+
+// CreatePurchaseOrder opens an order to a supplier for one or more products.
+func (helper *DbHelper) CreatePurchaseOrder(req PurchaseOrderRequest) (*PurchaseOrder, error) {
+	if len(req.Lines) == 0 {
+		return nil, fmt.Errorf("a purchase order needs at least one line")
+	}
+
+	order := &PurchaseOrder{}
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		supplier, err := txApp.FindRecordById(models.CName[models.Partners](), req.SupplierID)
+		if err != nil {
+			return fmt.Errorf("supplier %s not found: %w", req.SupplierID, err)
+		}
+		if supplier.GetString("company") != req.CompanyID {
+			return fmt.Errorf("supplier %s does not belong to the company", req.SupplierID)
+		}
+		if !partnerHasRole(supplier, "supplier") {
+			return fmt.Errorf("%s is not a supplier", supplier.GetString("name"))
+		}
+		currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+		if currency == "" {
+			if currency, err = companyBaseCurrency(txApp, req.CompanyID); err != nil {
+				return err
+			}
+		}
+
+		po, err := models.NewProxy[models.PurchaseOrders](txApp)
+		if err != nil {
+			return err
+		}
+		orderDate := types.NowDateTime()
+		number, err := nextDocumentNumber(txApp, req.CompanyID, "purchase_order", orderDate.Time())
+		if err != nil {
+			return err
+		}
+		po.Set("company", req.CompanyID)
+		po.Set("supplier", req.SupplierID)
+		po.Set("user", req.UserID)
+		po.SetNumber(number)
+		po.SetStatus(models.OrderOpen)
+		po.SetOrderDate(orderDate)
+		po.SetExpectedDate(req.ExpectedDate)
+		po.SetCurrency(currency)
+		po.SetNotes(req.Notes)
+		if err := txApp.Save(po); err != nil {
+			return err
+		}
+
+		var total float64
+		for i, input := range req.Lines {
+			if input.Quantity <= 0 {
+				return fmt.Errorf("line %d: quantity must be positive", i+1)
+			}
+			if input.UnitPrice < 0 {
+				return fmt.Errorf("line %d: unit price cannot be negative", i+1)
+			}
+			product, err := txApp.FindRecordById(models.CName[models.Products](), input.ProductID)
+			if err != nil {
+				return fmt.Errorf("line %d: product %s not found: %w", i+1, input.ProductID, err)
+			}
+			if product.GetString("company") != req.CompanyID {
+				return fmt.Errorf("line %d: product %s does not belong to the company", i+1, input.ProductID)
+			}
+
+			line, err := models.NewProxy[models.PurchaseOrderLines](txApp)
+			if err != nil {
+				return err
+			}
+			line.SetPurchaseOrder(po)
+			line.Set("product", input.ProductID)
+			line.Set("sku", input.SkuID)
+			line.SetQuantity(input.Quantity)
+			line.SetUnitPrice(roundMoney(input.UnitPrice))
+			if err := txApp.Save(line); err != nil {
+				return err
+			}
+			order.Lines = append(order.Lines, line)
+			total += input.Quantity * line.UnitPrice()
+		}
+
+		po.SetTotal(roundMoney(total))
+		order.Order = po
+		return txApp.Save(po)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// FetchPurchaseOrder returns one of the company's orders with its lines.
+func (helper *DbHelper) FetchPurchaseOrder(companyID, orderID string) (*PurchaseOrder, error) {
+	po, err := findPurchaseOrder(helper.pb, companyID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := purchaseOrderLines(helper.pb, po.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &PurchaseOrder{Order: po, Lines: lines}, nil
+}
+
+// CancelPurchaseOrder stops further receipts against an order. Goods already
+// received stay booked.
+func (helper *DbHelper) CancelPurchaseOrder(companyID, orderID string) (*models.PurchaseOrders, error) {
+	po, err := findPurchaseOrder(helper.pb, companyID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if status := po.GetString("status"); status == "received" || status == "cancelled" {
+		return nil, fmt.Errorf("purchase order %s is already %s", po.Number(), status)
+	}
+	po.SetStatus(models.OrderCancelled)
+	if err := helper.pb.Save(po); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func findPurchaseOrder(app core.App, companyID, orderID string) (*models.PurchaseOrders, error) {
+	record, err := app.FindRecordById(models.CName[models.PurchaseOrders](), orderID)
+	if err != nil {
+		return nil, fmt.Errorf("purchase order %s not found: %w", orderID, err)
+	}
+	if record.GetString("company") != companyID {
+		return nil, fmt.Errorf("purchase order %s does not belong to the company", orderID)
+	}
+	return models.WrapRecord[models.PurchaseOrders](record)
+}
+
+func purchaseOrderLines(app core.App, orderID string) ([]*models.PurchaseOrderLines, error) {
+	records, err := app.FindRecordsByFilter(
+		models.CName[models.PurchaseOrderLines](),
+		"purchase_order = {:order}",
+		"created",
+		0,
+		0,
+		dbx.Params{"order": orderID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]*models.PurchaseOrderLines, len(records))
+	for i, record := range records {
+		if lines[i], err = models.WrapRecord[models.PurchaseOrderLines](record); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// GoodsReceiptLineInput receives part or all of the outstanding quantity of
+// an order line. Without a UnitPrice the goods came at the order price; a
+// zero UnitPrice receives them free of charge.
+type GoodsReceiptLineInput struct {
+	LineID    string   `json:"line"`
+	Quantity  float64  `json:"quantity"`
+	UnitPrice *float64 `json:"unit_price"`
+}
+
+type GoodsReceiptRequest struct {
+	CompanyID       string
+	PurchaseOrderID string
+	UserID          string
+	Date            types.DateTime
+	Notes           string
+	Lines           []GoodsReceiptLineInput
+}
+
+// GoodsReceipt holds the records written for one goods-received note.
+type GoodsReceipt struct {
+	Note      *models.GoodsReceivedNotes   `json:"note"`
+	Lines     []*models.GoodsReceivedLines `json:"lines"`
+	Purchases []*models.Purchases          `json:"purchases"`
+	Invoice   *models.Invoices             `json:"invoice"`
+}
+
+// ReceiveGoods books a delivery against a purchase order: a goods-received
+// note, one purchases row and inventory movement per line and the supplier's
+// purchase invoice for the delivered value. Lines received at a price other
+// than the order price are flagged with their variance.
+func (helper *DbHelper) ReceiveGoods(req GoodsReceiptRequest) (*GoodsReceipt, error) {
+	if len(req.Lines) == 0 {
+		return nil, fmt.Errorf("a goods-received note needs at least one line")
+	}
+	if req.Date.IsZero() {
+		req.Date = types.NowDateTime()
+	}
+
+	receipt := &GoodsReceipt{}
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		po, err := findPurchaseOrder(txApp, req.CompanyID, req.PurchaseOrderID)
+		if err != nil {
+			return err
+		}
+		if status := po.GetString("status"); status == "received" || status == "cancelled" {
+			return fmt.Errorf("purchase order %s is %s", po.Number(), status)
+		}
+		orderLines, err := purchaseOrderLines(txApp, po.Id)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]*models.PurchaseOrderLines, len(orderLines))
+		for _, line := range orderLines {
+			byID[line.Id] = line
+		}
+		rate, err := FindExchangeRate(txApp, req.CompanyID, po.Currency(), req.Date.Time())
+		if err != nil {
+			return err
+		}
+
+		note, err := models.NewProxy[models.GoodsReceivedNotes](txApp)
+		if err != nil {
+			return err
+		}
+		number, err := nextDocumentNumber(txApp, req.CompanyID, "goods_received_note", req.Date.Time())
+		if err != nil {
+			return err
+		}
+		note.Set("company", req.CompanyID)
+		note.SetPurchaseOrder(po)
+		note.Set("supplier", po.GetString("supplier"))
+		note.Set("user", req.UserID)
+		note.SetNumber(number)
+		note.SetDate(req.Date)
+		note.SetNotes(req.Notes)
+		if err := txApp.Save(note); err != nil {
+			return err
+		}
+
+		invoice, err := models.NewProxy[models.Invoices](txApp)
+		if err != nil {
+			return err
+		}
+		invoice.Set("company", req.CompanyID)
+		invoice.Set("partner", po.GetString("supplier"))
+		invoice.Set("user", req.UserID)
+		invoice.SetType(models.Purchase)
+		invoice.SetDate(req.Date)
+		invoice.SetCurrency(po.Currency())
+		invoice.SetExchangeRate(rate)
+		if err := txApp.Save(invoice); err != nil {
+			return err
+		}
+
+		var total float64
+		for i, input := range req.Lines {
+			orderLine, ok := byID[input.LineID]
+			if !ok {
+				return fmt.Errorf("line %d: %s is not a line of purchase order %s", i+1, input.LineID, po.Number())
+			}
+			outstanding := orderLine.Quantity() - orderLine.ReceivedQuantity()
+			if input.Quantity <= 0 {
+				return fmt.Errorf("line %d: quantity must be positive", i+1)
+			}
+			if input.Quantity > outstanding {
+				return fmt.Errorf("line %d: receiving %g but only %g is outstanding", i+1, input.Quantity, outstanding)
+			}
+			price := orderLine.UnitPrice()
+			if input.UnitPrice != nil {
+				if *input.UnitPrice < 0 {
+					return fmt.Errorf("line %d: unit price cannot be negative", i+1)
+				}
+				price = roundMoney(*input.UnitPrice)
+			}
+
+			purchase, err := models.NewProxy[models.Purchases](txApp)
+			if err != nil {
+				return err
+			}
+			purchase.Set("company", req.CompanyID)
+			purchase.Set("user", req.UserID)
+			purchase.Set("product", orderLine.GetString("product"))
+			purchase.Set("sku", orderLine.GetString("sku"))
+			purchase.SetQuantity(input.Quantity)
+			purchase.SetUnitCost(price)
+			purchase.SetInvoice(invoice)
+			purchase.SetDate(req.Date)
+			purchase.SetCurrency(po.Currency())
+			purchase.SetExchangeRate(rate)
+			if err := txApp.Save(purchase); err != nil {
+				return err
+			}
+
+			if _, err := recordInventoryMovement(txApp, InventoryMovement{
+				CompanyID:     req.CompanyID,
+				ProductID:     orderLine.GetString("product"),
+				SkuID:         orderLine.GetString("sku"),
+				Change:        input.Quantity,
+				Reason:        models.Purchase3,
+				ReferenceType: models.CName[models.GoodsReceivedNotes](),
+				ReferenceID:   note.Id,
+				UserID:        req.UserID,
+				Date:          req.Date,
+				UnitCost:      price * rate,
+			}); err != nil {
+				return err
+			}
+
+			line, err := models.NewProxy[models.GoodsReceivedLines](txApp)
+			if err != nil {
+				return err
+			}
+			variance := roundMoney((price - orderLine.UnitPrice()) * input.Quantity)
+			line.SetGoodsReceivedNote(note)
+			line.SetPurchaseOrderLine(orderLine)
+			line.Set("product", orderLine.GetString("product"))
+			line.Set("sku", orderLine.GetString("sku"))
+			line.SetQuantity(input.Quantity)
+			line.SetExpectedPrice(orderLine.UnitPrice())
+			line.SetUnitPrice(price)
+			line.SetPriceVariance(variance)
+			line.SetVarianceFlag(math.Abs(price-orderLine.UnitPrice()) >= 0.01)
+			line.SetPurchase(purchase)
+			if err := txApp.Save(line); err != nil {
+				return err
+			}
+			if line.VarianceFlag() {
+				note.SetHasVariance(true)
+			}
+
+			orderLine.SetReceivedQuantity(orderLine.ReceivedQuantity() + input.Quantity)
+			if err := txApp.Save(orderLine); err != nil {
+				return err
+			}
+			total += input.Quantity * price
+
+			receipt.Lines = append(receipt.Lines, line)
+			receipt.Purchases = append(receipt.Purchases, purchase)
+		}
+
+		invoice.SetAmount(roundMoney(total))
+		if err := txApp.Save(invoice); err != nil {
+			return err
+		}
+		note.SetInvoice(invoice)
+		if err := txApp.Save(note); err != nil {
+			return err
+		}
+
+		po.SetStatus(models.OrderReceived)
+		for _, line := range orderLines {
+			if line.ReceivedQuantity() < line.Quantity() {
+				po.SetStatus(models.OrderPartial)
+				break
+			}
+		}
+		if err := txApp.Save(po); err != nil {
+			return err
+		}
+
+		receipt.Note = note
+		receipt.Invoice = invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// BindSaleVoids registers the hook that records every deleted sale in the
+// audit log, so voids still count against the salesperson who rang them up.
+func (helper *DbHelper) BindSaleVoids() {
+	sales := models.CName[models.SalesTransactions]()
+
+	helper.pb.OnRecordDelete(sales).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if e.Record.GetString("company") == "" {
+			return nil
+		}
+		return writeAuditLog(e.App, e.Record.GetString("company"), "", "sale.void", sales, e.Record.Id, map[string]any{
+			"salesperson":      e.Record.GetString("salesperson"),
+			"number":           e.Record.GetString("number"),
+			"transaction_type": e.Record.GetString("transaction_type"),
+			"transaction_date": e.Record.GetDateTime("transaction_date").String(),
+			"amount":           BaseAmount(e.Record, "total_amount"),
+		})
+	})
+}
+
+// SalespersonPerformance is one salesperson's takings over a range, in the
+// base currency. Revenue and AverageBasket cover sales only; returns and
+// voided (deleted) sales are counted separately.
+type SalespersonPerformance struct {
+	UserID        string  `json:"user_id"`
+	Name          string  `json:"name"`
+	Sales         int     `json:"sales"`
+	Revenue       float64 `json:"revenue"`
+	AverageBasket float64 `json:"average_basket"`
+	Discounts     float64 `json:"discounts"`
+	Returns       int     `json:"returns"`
+	ReturnsAmount float64 `json:"returns_amount"`
+	NetRevenue    float64 `json:"net_revenue"`
+	Voids         int     `json:"voids"`
+	VoidsAmount   float64 `json:"voids_amount"`
+	Commission    float64 `json:"commission"`
+}
+
+func (p *SalespersonPerformance) finish() {
+	p.Revenue = roundMoney(p.Revenue)
+	p.Discounts = roundMoney(p.Discounts)
+	p.ReturnsAmount = roundMoney(p.ReturnsAmount)
+	p.VoidsAmount = roundMoney(p.VoidsAmount)
+	p.NetRevenue = roundMoney(p.Revenue - p.ReturnsAmount)
+	p.Commission = roundMoney(p.Commission)
+	p.AverageBasket = 0
+	if p.Sales > 0 {
+		p.AverageBasket = roundMoney(p.Revenue / float64(p.Sales))
+	}
+}
+
+// SalespeopleReport ranks the company's salespeople by revenue.
+type SalespeopleReport struct {
+	From        time.Time                `json:"from"`
+	To          time.Time                `json:"to"`
+	Currency    string                   `json:"currency"`
+	Salespeople []SalespersonPerformance `json:"salespeople"`
+	Total       SalespersonPerformance   `json:"total"`
+}
+
+// SalespeopleReport returns each salesperson's sales, discounts, returns,
+// voids and commission on the days of the range. Sales without a
+// salesperson are reported under an empty user.
+func (helper *DbHelper) SalespeopleReport(companyID string, days DateRange) (*SalespeopleReport, error) {
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+	params := days.Params()
+	params["company"] = companyID
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[string]*SalespersonPerformance{}
+	row := func(userID string) *SalespersonPerformance {
+		if rows[userID] == nil {
+			rows[userID] = &SalespersonPerformance{UserID: userID}
+		}
+		return rows[userID]
+	}
+	for _, sale := range sales {
+		p := row(sale.GetString("salesperson"))
+		amount := math.Abs(BaseAmount(sale, "total_amount"))
+		if sale.GetString("transaction_type") == "return" {
+			p.Returns++
+			p.ReturnsAmount += amount
+			continue
+		}
+		p.Sales++
+		p.Revenue += amount
+		p.Discounts += BaseAmount(sale, "discount_amount")
+	}
+
+	var voids []struct {
+		Salesperson string  `db:"salesperson"`
+		N           int     `db:"n"`
+		Amount      float64 `db:"amount"`
+	}
+	err = helper.pb.DB().NewQuery(`SELECT COALESCE(json_extract(details, '$.salesperson'), '') AS salesperson, COUNT(*) AS n,
+			COALESCE(SUM(ABS(json_extract(details, '$.amount'))), 0) AS amount
+		FROM audit_logs
+		WHERE company = {:company} AND action = 'sale.void'
+			AND json_extract(details, '$.transaction_date') >= {:from} AND json_extract(details, '$.transaction_date') < {:to}
+		GROUP BY salesperson`).Bind(params).All(&voids)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range voids {
+		p := row(v.Salesperson)
+		p.Voids += v.N
+		p.VoidsAmount += v.Amount
+	}
+
+	statements, err := computeCommissions(helper.pb, companyID, days)
+	if err != nil {
+		return nil, err
+	}
+	for userID, statement := range statements {
+		row(userID).Commission += statement.Commission
+	}
+
+	ids := make([]string, 0, len(rows))
+	for id := range rows {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	users, err := helper.pb.FindRecordsByIds(models.CName[models.Users](), ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		rows[user.Id].Name = userDisplayName(user)
+	}
+
+	report := &SalespeopleReport{From: days.From, To: days.To, Currency: currency, Salespeople: []SalespersonPerformance{}}
+	for _, p := range rows {
+		p.finish()
+		report.Salespeople = append(report.Salespeople, *p)
+		t := &report.Total
+		t.Sales += p.Sales
+		t.Revenue += p.Revenue
+		t.Discounts += p.Discounts
+		t.Returns += p.Returns
+		t.ReturnsAmount += p.ReturnsAmount
+		t.Voids += p.Voids
+		t.VoidsAmount += p.VoidsAmount
+		t.Commission += p.Commission
+	}
+	report.Total.finish()
+	sort.Slice(report.Salespeople, func(i, j int) bool {
+		a, b := report.Salespeople[i], report.Salespeople[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+func userDisplayName(user *core.Record) string {
+	if name := user.GetString("name"); name != "" {
+		return name
+	}
+	if username := user.GetString("username"); username != "" {
+		return username
+	}
+	return user.Email()
+}
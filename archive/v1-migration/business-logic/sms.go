@@ -0,0 +1,233 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers text messages. SendSMS returns the provider's id for
+// the message. Errors marked with permanentError are not retried.
+type SMSSender interface {
+	Name() string
+	SendSMS(ctx context.Context, to, message string) (string, error)
+}
+
+// permanentError marks a delivery failure that will fail the same way on
+// every attempt, such as a rejected number or bad credentials.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// httpError turns a provider's error response into an error, permanent for
+// client errors other than rate limiting.
+func httpError(provider string, res *http.Response, body []byte) error {
+	err := fmt.Errorf("%s returned %s: %s", provider, res.Status, strings.TrimSpace(string(body)))
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
+
+var smsHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// AfricasTalkingSMS sends messages through the Africa's Talking bulk SMS API.
+// From is the registered sender id or short code; leave it empty to use the
+// account default. Use the sandbox endpoint and username for testing.
+type AfricasTalkingSMS struct {
+	Endpoint string
+	Username string
+	APIKey   string
+	From     string
+}
+
+func (p *AfricasTalkingSMS) Name() string { return "africastalking" }
+
+func (p *AfricasTalkingSMS) SendSMS(ctx context.Context, to, message string) (string, error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.africastalking.com/version1/messaging"
+	}
+	form := url.Values{"username": {p.Username}, "to": {"+" + normalizePhone(to)}, "message": {message}}
+	if p.From != "" {
+		form.Set("from", p.From)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apiKey", p.APIKey)
+
+	res, err := smsHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode >= 300 {
+		return "", httpError(p.Name(), res, body)
+	}
+
+	var out struct {
+		SMSMessageData struct {
+			Message    string
+			Recipients []struct {
+				Status     string `json:"status"`
+				StatusCode int    `json:"statusCode"`
+				MessageID  string `json:"messageId"`
+			}
+		}
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("unreadable %s response: %w", p.Name(), err)
+	}
+	if len(out.SMSMessageData.Recipients) == 0 {
+		return "", &permanentError{fmt.Errorf("%s rejected the message: %s", p.Name(), out.SMSMessageData.Message)}
+	}
+	r := out.SMSMessageData.Recipients[0]
+	// 100-102 are processed, sent and queued
+	if r.StatusCode < 100 || r.StatusCode > 102 {
+		err := fmt.Errorf("%s did not send the message: %s", p.Name(), r.Status)
+		// 405 insufficient balance and 500 internal error may clear up
+		if r.StatusCode == 405 || r.StatusCode >= 500 {
+			return "", err
+		}
+		return "", &permanentError{err}
+	}
+	return r.MessageID, nil
+}
+
+// TwilioSMS sends messages through the Twilio Messages API.
+type TwilioSMS struct {
+	Endpoint   string
+	AccountSID string
+	AuthToken  string
+	From       string
+}
+
+func (p *TwilioSMS) Name() string { return "twilio" }
+
+func (p *TwilioSMS) SendSMS(ctx context.Context, to, message string) (string, error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(p.AccountSID) + "/Messages.json"
+	}
+	form := url.Values{"To": {"+" + normalizePhone(to)}, "From": {p.From}, "Body": {message}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+
+	res, err := smsHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode >= 300 {
+		return "", httpError(p.Name(), res, body)
+	}
+
+	var out struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("unreadable %s response: %w", p.Name(), err)
+	}
+	return out.SID, nil
+}
+
+// LogSMS appends every message to a file instead of sending it, for
+// development and tests.
+type LogSMS struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (p *LogSMS) Name() string { return "log" }
+
+func (p *LogSMS) SendSMS(_ context.Context, to, message string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(p.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	now := time.Now().UTC()
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%q\n", now.Format(time.RFC3339), to, message); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("log-%d", now.UnixNano()), nil
+}
+
+// SMSSenderFromEnv configures the SMS provider from the environment:
+// SMS_PROVIDER is "africastalking", "twilio" or "log", and SMS_USERNAME,
+// SMS_API_KEY, SMS_FROM and optionally SMS_ENDPOINT hold its settings (the
+// Twilio account SID and auth token go in SMS_USERNAME and SMS_API_KEY).
+// SMS_LOG_PATH is where the log provider writes. It returns nil when no
+// provider is configured.
+func SMSSenderFromEnv() (SMSSender, error) {
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case "":
+		return nil, nil
+	case "africastalking":
+		return &AfricasTalkingSMS{
+			Endpoint: os.Getenv("SMS_ENDPOINT"),
+			Username: os.Getenv("SMS_USERNAME"),
+			APIKey:   os.Getenv("SMS_API_KEY"),
+			From:     os.Getenv("SMS_FROM"),
+		}, nil
+	case "twilio":
+		return &TwilioSMS{
+			Endpoint:   os.Getenv("SMS_ENDPOINT"),
+			AccountSID: os.Getenv("SMS_USERNAME"),
+			AuthToken:  os.Getenv("SMS_API_KEY"),
+			From:       os.Getenv("SMS_FROM"),
+		}, nil
+	case "log":
+		path := os.Getenv("SMS_LOG_PATH")
+		if path == "" {
+			path = "sms.log"
+		}
+		return &LogSMS{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_PROVIDER %q", provider)
+	}
+}
+
+// SMS returns the SMS provider: the one set on the helper, else a log file
+// in the data directory when running with --dev, else nil.
+func (helper *DbHelper) SMS() SMSSender {
+	if helper.SMSSender != nil {
+		return helper.SMSSender
+	}
+	if helper.pb.IsDev() {
+		return &LogSMS{Path: filepath.Join(helper.pb.DataDir(), "outbox", "sms.log")}
+	}
+	return nil
+}
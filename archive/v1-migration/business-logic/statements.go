@@ -0,0 +1,329 @@
+package lib
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// StatementLine is one entry on a partner statement. Debits increase what
+// the partner owes the company, credits reduce it.
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`
+	Description string    `json:"description"`
+	Debit       float64   `json:"debit"`
+	Credit      float64   `json:"credit"`
+	Balance     float64   `json:"balance"`
+}
+
+// Statement is a partner's account statement for a date range, in the
+// company's base currency.
+type Statement struct {
+	Company     *models.Companies `json:"company"`
+	Partner     *models.Partners  `json:"partner"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Currency    string            `json:"currency"`
+	Opening     float64           `json:"opening"`
+	Closing     float64           `json:"closing"`
+	TotalDebit  float64           `json:"total_debit"`
+	TotalCredit float64           `json:"total_credit"`
+	Lines       []StatementLine   `json:"lines"`
+}
+
+// LastDay returns the last day covered by the statement.
+func (s *Statement) LastDay() time.Time {
+	return s.To.AddDate(0, 0, -1)
+}
+
+// PartnerStatement builds the statement of a partner for the range: sale
+// invoices and sales on credit are debits, purchase invoices are credits and
+// payments settle them. Everything dated before the range is carried in the
+// opening balance.
+func (helper *DbHelper) PartnerStatement(companyID, partnerID string, r DateRange) (*Statement, error) {
+	company, err := helper.FetchCompanyById(companyID)
+	if err != nil {
+		return nil, err
+	}
+	partnerRecord, err := helper.pb.FindRecordById(models.CName[models.Partners](), partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("partner %s not found: %w", partnerID, err)
+	}
+	if partnerRecord.GetString("company") != companyID {
+		return nil, fmt.Errorf("partner %s does not belong to the company", partnerID)
+	}
+	partner, err := models.WrapRecord[models.Partners](partnerRecord)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := helper.BaseCurrency(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := helper.statementEntries(companyID, partnerID, r.To)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Date.Before(lines[j].Date) })
+
+	st := &Statement{Company: company, Partner: partner, From: r.From, To: r.To, Currency: currency, Lines: []StatementLine{}}
+	balance := 0.0
+	for _, line := range lines {
+		balance = roundMoney(balance + line.Debit - line.Credit)
+		if line.Date.Before(r.From) {
+			st.Opening = balance
+			continue
+		}
+		line.Balance = balance
+		st.TotalDebit = roundMoney(st.TotalDebit + line.Debit)
+		st.TotalCredit = roundMoney(st.TotalCredit + line.Credit)
+		st.Lines = append(st.Lines, line)
+	}
+	st.Closing = balance
+	return st, nil
+}
+
+// statementEntries collects every entry of the partner dated before the end
+// of the statement.
+func (helper *DbHelper) statementEntries(companyID, partnerID string, to time.Time) ([]StatementLine, error) {
+	params := dbx.Params{"company": companyID, "partner": partnerID, "to": formatDate(to)}
+	var lines []StatementLine
+
+	invoices, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Invoices](),
+		"company = {:company} && partner = {:partner} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var paymentIDs []string
+	for _, invoice := range invoices {
+		line := StatementLine{
+			Date:      invoice.GetDateTime("date").Time(),
+			Type:      "invoice",
+			Reference: documentNumber(invoice),
+		}
+		amount := BaseAmount(invoice, "amount")
+		if invoice.GetString("type") == "purchase" {
+			line.Description = "Purchase invoice"
+			line.Credit = amount
+		} else {
+			line.Description = "Invoice"
+			line.Debit = amount
+		}
+		lines = append(lines, line)
+		paymentIDs = append(paymentIDs, invoice.GetStringSlice("transactions")...)
+	}
+
+	sales, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && customer = {:partner} && transaction_date < {:to}",
+		"transaction_date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	saleLines, err := helper.creditSaleEntries(companyID, sales, to)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, saleLines...)
+
+	// payments allocated to the partner's invoices and any unallocated
+	// overpayment booked against the partner itself
+	payments, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.Transactions](),
+		"company = {:company} && reference_id = {:partner} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(paymentIDs) > 0 {
+		allocated, err := helper.pb.FindRecordsByIds(models.CName[models.Transactions](), paymentIDs, func(q *dbx.SelectQuery) error {
+			q.AndWhere(dbx.NewExp("date < {:to}", dbx.Params{"to": formatDate(to)}))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, allocated...)
+	}
+	for _, payment := range payments {
+		lines = append(lines, paymentStatementLine(payment))
+	}
+	return lines, nil
+}
+
+// creditSaleEntries debits each sale the full amount it left on credit, on
+// the sale date, and credits the later payments against it on their own
+// dates, so a past statement keeps its figures as the sales are paid off.
+// Payments against a sale are the transactions referencing it other than the
+// one taken at the till; what a sale left on credit is what it still owes
+// plus all of them. A sale later billed on an invoice is credited what it
+// still owes on the invoice date, where the invoice debits it.
+func (helper *DbHelper) creditSaleEntries(companyID string, sales []*core.Record, to time.Time) ([]StatementLine, error) {
+	if len(sales) == 0 {
+		return nil, nil
+	}
+	ids := make([]any, len(sales))
+	for i, sale := range sales {
+		ids[i] = sale.Id
+	}
+	payments, err := helper.pb.FindAllRecords(
+		models.CName[models.Transactions](),
+		dbx.HashExp{"company": companyID, "reference_type": "sale", "reference_id": ids},
+	)
+	if err != nil {
+		return nil, err
+	}
+	bySale := map[string][]*core.Record{}
+	for _, payment := range payments {
+		bySale[payment.GetString("reference_id")] = append(bySale[payment.GetString("reference_id")], payment)
+	}
+
+	billed, err := billedSales(helper.pb, companyID, sales[0].GetString("customer"), to)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []StatementLine
+	for _, sale := range sales {
+		credit := BaseAmount(sale, "remaining_balance")
+		var settled []*core.Record
+		for _, payment := range bySale[sale.Id] {
+			if payment.Id == sale.GetString("transaction") {
+				continue
+			}
+			credit += payment.GetFloat("amount")
+			settled = append(settled, payment)
+		}
+		if credit = roundMoney(credit); credit <= 0 {
+			continue
+		}
+		lines = append(lines, StatementLine{
+			Date:        sale.GetDateTime("transaction_date").Time(),
+			Type:        "credit_sale",
+			Reference:   documentNumber(sale),
+			Description: fmt.Sprintf("Sale on credit (total %s)", formatAmount(BaseAmount(sale, "total_amount"))),
+			Debit:       credit,
+		})
+		for _, payment := range settled {
+			if !payment.GetDateTime("date").Time().Before(to) {
+				continue
+			}
+			lines = append(lines, paymentStatementLine(payment))
+		}
+		// the invoice debits what the sale left unpaid again
+		if invoice := billed[sale.Id]; invoice != nil {
+			if owed := roundMoney(BaseAmount(sale, "remaining_balance")); owed > 0 {
+				lines = append(lines, StatementLine{
+					Date:        invoice.GetDateTime("date").Time(),
+					Type:        "billed",
+					Reference:   documentNumber(sale),
+					Description: "Billed on invoice " + documentNumber(invoice),
+					Credit:      owed,
+				})
+			}
+		}
+	}
+	return lines, nil
+}
+
+func paymentStatementLine(payment *core.Record) StatementLine {
+	reference := payment.GetString("transaction_id")
+	if reference == "" {
+		reference = payment.Id
+	}
+	line := StatementLine{
+		Date:      payment.GetDateTime("date").Time(),
+		Type:      "payment",
+		Reference: reference,
+	}
+	if payment.GetString("reference_type") == "purchase" {
+		line.Description = "Payment made"
+		line.Debit = payment.GetFloat("amount")
+	} else {
+		line.Description = "Payment received"
+		line.Credit = payment.GetFloat("amount")
+	}
+	return line
+}
+
+// WriteStatementCSV writes the statement lines between an opening and a
+// closing balance row.
+func WriteStatementCSV(w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Date", "Type", "Reference", "Description", "Debit", "Credit", "Balance"})
+	cw.Write([]string{st.From.Format(time.DateOnly), "", "", "Opening balance", "", "", formatAmount(st.Opening)})
+	for _, line := range st.Lines {
+		cw.Write([]string{
+			line.Date.Format(time.DateOnly),
+			line.Type,
+			line.Reference,
+			line.Description,
+			formatOptionalAmount(line.Debit),
+			formatOptionalAmount(line.Credit),
+			formatAmount(line.Balance),
+		})
+	}
+	cw.Write([]string{st.LastDay().Format(time.DateOnly), "", "", "Closing balance", formatAmount(st.TotalDebit), formatAmount(st.TotalCredit), formatAmount(st.Closing)})
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteStatementPDF renders the statement as a printable A4 document under
+// the company header.
+func WriteStatementPDF(w io.Writer, st *Statement) error {
+	doc := newPDFDocument()
+	doc.companyHeader(st.Company)
+
+	doc.text("B", 13, "Statement of Account")
+	doc.text("", 10, st.Partner.Name()+" "+st.Partner.Phone())
+	doc.text("", 10, fmt.Sprintf("%s to %s, amounts in %s",
+		st.From.Format(time.DateOnly), st.LastDay().Format(time.DateOnly), st.Currency))
+	doc.Ln(3)
+
+	rows := [][]string{{st.From.Format(time.DateOnly), "", "Opening balance", "", "", formatAmount(st.Opening)}}
+	for _, line := range st.Lines {
+		rows = append(rows, []string{
+			line.Date.Format(time.DateOnly),
+			line.Reference,
+			line.Description,
+			formatOptionalAmount(line.Debit),
+			formatOptionalAmount(line.Credit),
+			formatAmount(line.Balance),
+		})
+	}
+	rows = append(rows, []string{st.LastDay().Format(time.DateOnly), "", "Closing balance",
+		formatAmount(st.TotalDebit), formatAmount(st.TotalCredit), formatAmount(st.Closing)})
+	doc.table(
+		[]string{"Date", "Reference", "Description", "Debit", "Credit", "Balance"},
+		rows,
+		[]float64{22, 32, 56, 24, 24, 22},
+		map[int]bool{3: true, 4: true, 5: true},
+	)
+
+	doc.Ln(4)
+	doc.text("B", 11, fmt.Sprintf("Amount due: %s %s", st.Currency, formatAmount(st.Closing)))
+	return doc.Output(w)
+}
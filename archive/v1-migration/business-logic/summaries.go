@@ -0,0 +1,463 @@
+package lib
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// topProductsPerDay is how many products a daily summary lists.
+const topProductsPerDay = 10
+
+// summaryDateFields maps every collection a daily summary is computed from
+// to the field holding the record's business date.
+var summaryDateFields = map[string]string{
+	"sales_transactions": "transaction_date",
+	"purchases":          "date",
+	"expenses":           "created",
+}
+
+// TopProduct is one entry of a daily summary's top_selling_products, in the
+// base currency. Returns count against the product.
+type TopProduct struct {
+	Product  string  `json:"product"`
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// DaySummary holds the figures of a company's business day, midnight to
+// midnight in the company's timezone, in the base currency. Date is the
+// calendar day, at midnight UTC, as daily_summaries rows are keyed.
+// ProfitMargin is what is left of the sales after purchases and expenses,
+// as a percentage of sales.
+type DaySummary struct {
+	Date           time.Time    `json:"date"`
+	TotalSales     float64      `json:"total_sales"`
+	TotalPurchases float64      `json:"total_purchases"`
+	TotalExpenses  float64      `json:"total_expenses"`
+	ProfitMargin   float64      `json:"profit_margin"`
+	TopProducts    []TopProduct `json:"top_selling_products"`
+}
+
+// summaryDay is the calendar day, at midnight UTC, that t falls on where the
+// company trades.
+func summaryDay(company *core.Record, t time.Time) time.Time {
+	y, m, d := t.In(companyLocation(company)).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// computeDaySummary adds up the company's sales, purchases and expenses of
+// the calendar day, from midnight to midnight in the company's timezone.
+func computeDaySummary(app core.App, company *core.Record, day time.Time) (*DaySummary, error) {
+	y, m, d := day.Date()
+	from := time.Date(y, m, d, 0, 0, 0, 0, companyLocation(company))
+	summary, err := computeSummary(app, company.Id, DateRange{From: from, To: from.AddDate(0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	summary.Date = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return summary, nil
+}
+
+// computeSummary adds up the company's sales, purchases and expenses dated
+// in the range, which need not be whole days.
+func computeSummary(app core.App, companyID string, days DateRange) (*DaySummary, error) {
+	params := days.Params()
+	params["company"] = companyID
+	summary := &DaySummary{Date: days.From, TopProducts: []TopProduct{}}
+
+	sales, err := app.FindRecordsByFilter(
+		models.CName[models.SalesTransactions](),
+		"company = {:company} && transaction_date >= {:from} && transaction_date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if errs := app.ExpandRecords(sales, []string{"sales_details.product"}, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load sale lines: %v", errs)
+	}
+
+	products := map[string]*TopProduct{}
+	for _, sale := range sales {
+		sign := 1.0
+		if sale.GetString("transaction_type") == "return" {
+			sign = -1
+		}
+		rate := sale.GetFloat("exchange_rate")
+		if rate <= 0 {
+			rate = 1
+		}
+		summary.TotalSales += sign * math.Abs(BaseAmount(sale, "total_amount"))
+
+		for _, line := range sale.ExpandedAll("sales_details") {
+			productID := line.GetString("product")
+			if productID == "" {
+				continue
+			}
+			p, ok := products[productID]
+			if !ok {
+				p = &TopProduct{Product: productID}
+				if product := line.ExpandedOne("product"); product != nil {
+					p.Name = product.GetString("name")
+				}
+				products[productID] = p
+			}
+			quantity := math.Abs(line.GetFloat("quantity"))
+			p.Quantity += sign * quantity
+			p.Revenue += sign * quantity * line.GetFloat("unit_price") * rate
+		}
+	}
+
+	purchases, err := app.FindRecordsByFilter(
+		models.CName[models.Purchases](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// purchases booked without a unit cost, like those imported or entered
+	// by hand, are valued at what their invoice leaves for them or, without
+	// an invoice, at the inventory cost price
+	uncosted := map[string][]*core.Record{}
+	for _, purchase := range purchases {
+		if purchase.GetFloat("unit_cost") > 0 {
+			summary.TotalPurchases += purchase.GetFloat("quantity") * BaseAmount(purchase, "unit_cost")
+			continue
+		}
+		uncosted[purchase.GetString("invoice")] = append(uncosted[purchase.GetString("invoice")], purchase)
+	}
+	for invoiceID, lines := range uncosted {
+		if invoiceID != "" {
+			value, err := uncostedInvoiceValue(app, invoiceID)
+			if err != nil {
+				return nil, err
+			}
+			summary.TotalPurchases += value
+			continue
+		}
+		for _, purchase := range lines {
+			inventory, err := findInventory(app, companyID, purchase.GetString("product"), purchase.GetString("sku"))
+			if err != nil {
+				return nil, err
+			}
+			if inventory != nil {
+				summary.TotalPurchases += purchase.GetFloat("quantity") * inventory.CostPrice()
+			}
+		}
+	}
+
+	expenses, err := app.FindRecordsByFilter(
+		models.CName[models.Expenses](),
+		"company = {:company} && created >= {:from} && created < {:to}",
+		"",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, expense := range expenses {
+		// expenses are kept in the base currency
+		summary.TotalExpenses += expense.GetFloat("amount")
+	}
+
+	summary.TotalSales = roundMoney(summary.TotalSales)
+	summary.TotalPurchases = roundMoney(summary.TotalPurchases)
+	summary.TotalExpenses = roundMoney(summary.TotalExpenses)
+	if summary.TotalSales > 0 {
+		profit := summary.TotalSales - summary.TotalPurchases - summary.TotalExpenses
+		summary.ProfitMargin = roundMoney(profit / summary.TotalSales * 100)
+	}
+
+	for _, p := range products {
+		p.Quantity = roundMoney(p.Quantity)
+		p.Revenue = roundMoney(p.Revenue)
+		summary.TopProducts = append(summary.TopProducts, *p)
+	}
+	sort.Slice(summary.TopProducts, func(i, j int) bool {
+		a, b := summary.TopProducts[i], summary.TopProducts[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.Product < b.Product
+	})
+	if len(summary.TopProducts) > topProductsPerDay {
+		summary.TopProducts = summary.TopProducts[:topProductsPerDay]
+	}
+	return summary, nil
+}
+
+// uncostedInvoiceValue is the part of a purchase invoice, in the base
+// currency, not accounted for by the unit costs of its purchases.
+func uncostedInvoiceValue(app core.App, invoiceID string) (float64, error) {
+	invoice, err := app.FindRecordById(models.CName[models.Invoices](), invoiceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purchases, err := app.FindRecordsByFilter(
+		models.CName[models.Purchases](),
+		"invoice = {:invoice} && unit_cost > 0",
+		"",
+		0,
+		0,
+		dbx.Params{"invoice": invoiceID},
+	)
+	if err != nil {
+		return 0, err
+	}
+	value := BaseAmount(invoice, "amount")
+	for _, purchase := range purchases {
+		value -= purchase.GetFloat("quantity") * BaseAmount(purchase, "unit_cost")
+	}
+	return max(value, 0), nil
+}
+
+// findDailySummary returns the company's summary row of the calendar day,
+// or nil.
+func findDailySummary(app core.App, companyID string, day time.Time) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		models.CName[models.DailySummaries](),
+		"company = {:company} && date = {:date}",
+		dbx.Params{"company": companyID, "date": formatDate(day)},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// summarizeDay computes the company's summary of the calendar day and
+// stores it, replacing the one stored before.
+func summarizeDay(app core.App, company *core.Record, day time.Time) (*DaySummary, error) {
+	summary, err := computeDaySummary(app, company, day)
+	if err != nil {
+		return nil, err
+	}
+	record, err := findDailySummary(app, company.Id, summary.Date)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		proxy, err := models.NewProxy[models.DailySummaries](app)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Set("company", company.Id)
+		proxy.Set("date", summary.Date)
+		record = proxy.Record
+	}
+	top, err := json.Marshal(summary.TopProducts)
+	if err != nil {
+		return nil, err
+	}
+	record.Set("total_sales", summary.TotalSales)
+	record.Set("total_purchases", summary.TotalPurchases)
+	record.Set("total_expenses", summary.TotalExpenses)
+	record.Set("profit_margin", summary.ProfitMargin)
+	record.Set("top_selling_products", string(top))
+	if err := app.Save(record); err != nil {
+		return nil, fmt.Errorf("failed to save summary of %s: %w", summary.Date.Format(time.DateOnly), err)
+	}
+	return summary, nil
+}
+
+// summaryCompanies returns the company, or every company when companyID is
+// empty.
+func (helper *DbHelper) summaryCompanies(companyID string) ([]*core.Record, error) {
+	if companyID != "" {
+		company, err := helper.pb.FindRecordById(models.CName[models.Companies](), companyID)
+		if err != nil {
+			return nil, err
+		}
+		return []*core.Record{company}, nil
+	}
+	return helper.pb.FindRecordsByFilter(models.CName[models.Companies](), "", "created", 0, 0)
+}
+
+// SummarizeDays computes and stores the daily summaries of every calendar
+// day in the range, for one company or, when companyID is empty, for every
+// company. It returns the number of summaries written.
+func (helper *DbHelper) SummarizeDays(companyID string, days DateRange) (int, error) {
+	companies, err := helper.summaryCompanies(companyID)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, company := range companies {
+		for day := days.From; day.Before(days.To); day = day.AddDate(0, 0, 1) {
+			if _, err := summarizeDay(helper.pb, company, day); err != nil {
+				return n, fmt.Errorf("company %s: %w", company.Id, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// summarizeYesterday stores each company's summary of the day before now,
+// in its own timezone, unless it is stored already.
+func (helper *DbHelper) summarizeYesterday(now time.Time) error {
+	companies, err := helper.summaryCompanies("")
+	if err != nil {
+		return err
+	}
+	for _, company := range companies {
+		yesterday := summaryDay(company, now).AddDate(0, 0, -1)
+		existing, err := findDailySummary(helper.pb, company.Id, yesterday)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		if _, err := summarizeDay(helper.pb, company, yesterday); err != nil {
+			return fmt.Errorf("company %s: %w", company.Id, err)
+		}
+	}
+	return nil
+}
+
+// BindDailySummaries schedules the summary of the day before, taken hourly
+// so each company's is stored soon after its own midnight, and registers the
+// record hooks that recompute a day already summarised when a sale, purchase
+// or expense of that day is added, changed or removed later.
+func (helper *DbHelper) BindDailySummaries() {
+	helper.pb.Cron().MustAdd("dailySummaries", "20 * * * *", func() {
+		if err := helper.summarizeYesterday(time.Now()); err != nil {
+			helper.Logger.Printf("Error summarising yesterday: %v", err)
+		}
+	})
+
+	collections := make([]string, 0, len(summaryDateFields))
+	for name := range summaryDateFields {
+		collections = append(collections, name)
+	}
+
+	// the summary is recomputed with the change's app, so it is saved or
+	// rolled back together with the change
+	helper.pb.OnRecordCreate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		helper.resummarizeRecord(e.App, e.Record)
+		return nil
+	})
+
+	helper.pb.OnRecordUpdate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		// the record may have been moved out of another day
+		original := e.Record.Original()
+		if err := e.Next(); err != nil {
+			return err
+		}
+		helper.resummarizeRecord(e.App, e.Record)
+		field := summaryDateFields[e.Record.Collection().Name]
+		if original.GetString("company") != e.Record.GetString("company") ||
+			!original.GetDateTime(field).Time().Equal(e.Record.GetDateTime(field).Time()) {
+			helper.resummarizeRecord(e.App, original)
+		}
+		return nil
+	})
+
+	helper.pb.OnRecordDelete(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		helper.resummarizeRecord(e.App, e.Record)
+		return nil
+	})
+
+	// a changed sale line changes its sale's day
+	helper.pb.OnRecordUpdate(models.CName[models.SalesDetails]()).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		sales, err := e.App.FindRecordsByFilter(
+			models.CName[models.SalesTransactions](),
+			"sales_details ~ {:line}",
+			"",
+			0,
+			0,
+			dbx.Params{"line": e.Record.Id},
+		)
+		if err != nil {
+			helper.Logger.Printf("Error finding the sale of line %s: %v", e.Record.Id, err)
+		}
+		for _, sale := range sales {
+			helper.resummarizeRecord(e.App, sale)
+		}
+		return nil
+	})
+}
+
+// resummarizeRecord recomputes the summary of the record's day if that day
+// has been summarised already; days not yet summarised are left to the
+// nightly job. A failure is logged rather than returned, so a summary never
+// stops a sale from being recorded.
+func (helper *DbHelper) resummarizeRecord(app core.App, record *core.Record) {
+	companyID := record.GetString("company")
+	date := record.GetDateTime(summaryDateFields[record.Collection().Name])
+	if companyID == "" || date.IsZero() {
+		return
+	}
+	company, err := app.FindRecordById(models.CName[models.Companies](), companyID)
+	if err == nil {
+		day := summaryDay(company, date.Time())
+		var existing *core.Record
+		existing, err = findDailySummary(app, companyID, day)
+		if err == nil && existing != nil {
+			_, err = summarizeDay(app, company, day)
+		}
+	}
+	if err != nil {
+		helper.Logger.Printf("Error recomputing the summary of %s: %v", date.Time().Format(time.DateOnly), err)
+	}
+}
+
+// FetchDailySummaries returns the company's stored summaries of the days in
+// the range, oldest first.
+func (helper *DbHelper) FetchDailySummaries(companyID string, days DateRange) ([]*models.DailySummaries, error) {
+	params := days.Params()
+	params["company"] = companyID
+	records, err := helper.pb.FindRecordsByFilter(
+		models.CName[models.DailySummaries](),
+		"company = {:company} && date >= {:from} && date < {:to}",
+		"date",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]*models.DailySummaries, 0, len(records))
+	for _, record := range records {
+		summary, err := models.WrapRecord[models.DailySummaries](record)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kisinga/dukahub/models"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// TransferRequest moves Amount from one company account to another, e.g.
+// from the till to the bank. Amount and Fee are in the source account's
+// currency; a non-zero Fee is charged to the source account and recorded as
+// an expense. Between accounts in different currencies ToAmount is what the
+// destination received, or zero to convert at the exchange-rate table.
+type TransferRequest struct {
+	CompanyID     string
+	FromAccountID string
+	ToAccountID   string
+	Amount        float64
+	ToAmount      float64
+	Fee           float64
+	Date          types.DateTime
+	Notes         string
+	AuthorID      string
+}
+
+// Transfer holds the records written for one transfer. All of them share
+// Reference as their transaction_id.
+type Transfer struct {
+	Reference string               `json:"reference"`
+	Debit     *models.Transactions `json:"debit"`
+	Credit    *models.Transactions `json:"credit"`
+	Fee       *models.Transactions `json:"fee,omitempty"`
+	Expense   *models.Expenses     `json:"expense,omitempty"`
+}
+
+// TransferBetweenAccounts atomically credits the source account, debits the
+// destination account and books the fee, updating both account balances.
+func (helper *DbHelper) TransferBetweenAccounts(req TransferRequest) (*Transfer, error) {
+	req.Amount = roundMoney(req.Amount)
+	req.Fee = roundMoney(req.Fee)
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("transfer amount must be positive")
+	}
+	if req.Fee < 0 {
+		return nil, fmt.Errorf("transfer fee cannot be negative")
+	}
+	if req.FromAccountID == req.ToAccountID {
+		return nil, fmt.Errorf("cannot transfer to the same account")
+	}
+	if req.Date.IsZero() {
+		req.Date = types.NowDateTime()
+	}
+
+	transfer := &Transfer{Reference: newDocumentReference("TRF")}
+
+	err := helper.pb.RunInTransaction(func(txApp core.App) error {
+		from, err := findCompanyAccount(txApp, req.CompanyID, req.FromAccountID)
+		if err != nil {
+			return err
+		}
+		to, err := findCompanyAccount(txApp, req.CompanyID, req.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		toAmount, err := transferredAmount(txApp, req, from, to)
+		if err != nil {
+			return err
+		}
+
+		narration := fmt.Sprintf("Transfer %s to %s", from.Name(), to.Name())
+		if notes := strings.TrimSpace(req.Notes); notes != "" {
+			narration += ": " + notes
+		}
+
+		transfer.Credit, err = saveTransferSide(txApp, req, from, models.Credit, req.Amount, models.Transfer, transfer.Reference)
+		if err != nil {
+			return err
+		}
+		transfer.Debit, err = saveTransferSide(txApp, req, to, models.Debit, toAmount, models.Transfer, transfer.Reference)
+		if err != nil {
+			return err
+		}
+
+		if req.Fee > 0 {
+			transfer.Fee, err = saveTransferSide(txApp, req, from, models.Credit, req.Fee, models.Expense, transfer.Reference)
+			if err != nil {
+				return err
+			}
+
+			expense, err := models.NewProxy[models.Expenses](txApp)
+			if err != nil {
+				return err
+			}
+			expense.Set("company", req.CompanyID)
+			// expenses are kept in the base currency
+			expense.SetAmount(transfer.Fee.Amount())
+			expense.SetPurpose("Transfer fee: " + narration)
+			expense.SetTransaction(transfer.Fee)
+			if err := txApp.Save(expense); err != nil {
+				return err
+			}
+			transfer.Expense = expense
+
+			transfer.Fee.SetReferenceId(expense.Id)
+			if err := txApp.Save(transfer.Fee); err != nil {
+				return err
+			}
+		}
+
+		from.SetBal(roundMoney(from.Bal() - req.Amount - req.Fee))
+		if err := txApp.Save(from); err != nil {
+			return err
+		}
+		to.SetBal(roundMoney(to.Bal() + toAmount))
+		return txApp.Save(to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func saveTransferSide(app core.App, req TransferRequest, account *models.CompanyAccounts, side models.TypeSelectType2, amount float64, referenceType models.ReferenceTypeSelectType, reference string) (*models.Transactions, error) {
+	tx, err := models.NewProxy[models.Transactions](app)
+	if err != nil {
+		return nil, err
+	}
+	tx.Set("company", req.CompanyID)
+	tx.SetAccount(account)
+	tx.SetType(side)
+	tx.SetAmount(amount)
+	tx.SetTransactionId(reference)
+	tx.SetDate(req.Date)
+	tx.Set("author", req.AuthorID)
+	tx.SetReferenceType(referenceType)
+	tx.SetReferenceId(reference)
+	if err := app.Save(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// transferredAmount returns the amount credited to the destination account
+// in its own currency.
+func transferredAmount(app core.App, req TransferRequest, from, to *models.CompanyAccounts) (float64, error) {
+	base, err := companyBaseCurrency(app, req.CompanyID)
+	if err != nil {
+		return 0, err
+	}
+	fromCurrency, toCurrency := from.Currency(), to.Currency()
+	if fromCurrency == "" {
+		fromCurrency = base
+	}
+	if toCurrency == "" {
+		toCurrency = base
+	}
+	if fromCurrency == toCurrency {
+		return req.Amount, nil
+	}
+	if req.ToAmount > 0 {
+		return roundMoney(req.ToAmount), nil
+	}
+
+	fromRate, err := FindExchangeRate(app, req.CompanyID, fromCurrency, req.Date.Time())
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := FindExchangeRate(app, req.CompanyID, toCurrency, req.Date.Time())
+	if err != nil {
+		return 0, err
+	}
+	return roundMoney(req.Amount * fromRate / toRate), nil
+}
+
+func findCompanyAccount(app core.App, companyID, accountID string) (*models.CompanyAccounts, error) {
+	record, err := app.FindRecordById(models.CName[models.CompanyAccounts](), accountID)
+	if err != nil {
+		return nil, fmt.Errorf("account %s not found: %w", accountID, err)
+	}
+	if record.GetString("company") != companyID {
+		return nil, fmt.Errorf("account %s does not belong to the company", accountID)
+	}
+	return models.WrapRecord[models.CompanyAccounts](record)
+}
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/kisinga/dukahub/models"
 	"github.com/pocketbase/pocketbase/core"
@@ -28,3 +29,12 @@ func (helper *DbHelper) FetchExpandedUserById(id string) (*models.Users, error)
 	return user, nil
 
 }
+
+// UserHasCompany reports whether the user is a member of the company.
+func (helper *DbHelper) UserHasCompany(userID, companyID string) (bool, error) {
+	record, err := helper.pb.FindRecordById(models.CName[models.Users](), userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(record.GetStringSlice("company"), companyID), nil
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"sync"
+
+	"github.com/pocketbase/pocketbase/tools/security"
 )
 
 type ThumnailSize struct {
@@ -77,3 +79,9 @@ func createZip(files []FileInfo, logger *log.Logger) (*bytes.Buffer, error) {
 
 	return buf, nil
 }
+
+// newDocumentReference returns a random, human-readable document reference
+// such as "TRF-7KQ2MZ9XHC".
+func newDocumentReference(prefix string) string {
+	return prefix + "-" + security.RandomStringWithAlphabet(10, "ABCDEFGHJKLMNPQRSTUVWXYZ23456789")
+}
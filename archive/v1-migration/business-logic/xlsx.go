@@ -0,0 +1,342 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxSheet is a workbook of one sheet laid out like the bookkeepers' legacy
+// spreadsheets: a bold, bordered header row followed by the rows. A cell is
+// a time.Time (written as an Excel date), a string, a number, or nil for a
+// blank cell.
+type xlsxSheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// xlsxEpoch is day zero of Excel's date serials.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const (
+	xlsxStyleHeader = "1"
+	xlsxStyleDate   = "2"
+)
+
+const xlsxXMLHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xlsxStyles are the legacy spreadsheets' styles: the default, the header
+// and the date format.
+const xlsxStyles = xlsxXMLHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="YYYY-MM-DD HH:MM:SS"/></numFmts><fonts count="2"><font><sz val="11"/><color theme="1"/><name val="Calibri"/><family val="2"/><scheme val="minor"/></font><font><b/><sz val="11"/><color theme="1"/><name val="Calibri"/><family val="2"/><scheme val="minor"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="2"><border><left/><right/><top/><bottom/><diagonal/></border><border><left style="thin"><color auto="1"/></left><right style="thin"><color auto="1"/></right><top style="thin"><color auto="1"/></top><bottom style="thin"><color auto="1"/></bottom><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="1" xfId="0" applyFont="1" applyBorder="1" applyAlignment="1"><alignment horizontal="center" vertical="top"/></xf><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs><cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles><dxfs count="0"/><tableStyles count="0" defaultTableStyle="TableStyleMedium9" defaultPivotStyle="PivotStyleLight16"/></styleSheet>`
+
+const xlsxContentTypes = xlsxXMLHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/><Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/sharedStrings.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sharedStrings+xml"/></Types>`
+
+const xlsxRootRels = xlsxXMLHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/></Relationships>`
+
+const xlsxWorkbookRels = xlsxXMLHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/></Relationships>`
+
+// writeXLSX writes the sheet as an .xlsx workbook created at now.
+func writeXLSX(w io.Writer, sheet xlsxSheet, now time.Time) error {
+	name := escapeXML(sheet.Name)
+
+	// strings are stored once in the shared string table and referenced by
+	// index from the cells
+	var strs []string
+	index := map[string]int{}
+	refs := 0
+	sharedString := func(s string) int {
+		refs++
+		i, ok := index[s]
+		if !ok {
+			i = len(strs)
+			index[s] = i
+			strs = append(strs, s)
+		}
+		return i
+	}
+
+	cols := len(sheet.Header)
+	for _, row := range sheet.Rows {
+		cols = max(cols, len(row))
+	}
+	rows := append([][]any{}, sheet.Rows...)
+	header := make([]any, len(sheet.Header))
+	for i, h := range sheet.Header {
+		header[i] = h
+	}
+	rows = append([][]any{header}, rows...)
+
+	data := new(bytes.Buffer)
+	for r, row := range rows {
+		fmt.Fprintf(data, `<row r="%d" spans="1:%d">`, r+1, cols)
+		for c, value := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			style := ""
+			if r == 0 {
+				style = ` s="` + xlsxStyleHeader + `"`
+			}
+			switch v := value.(type) {
+			case nil:
+				continue
+			case string:
+				fmt.Fprintf(data, `<c r="%s"%s t="s"><v>%d</v></c>`, ref, style, sharedString(v))
+			case time.Time:
+				fmt.Fprintf(data, `<c r="%s" s="%s"><v>%s</v></c>`, ref, xlsxStyleDate, xlsxNumber(xlsxSerial(v)))
+			case float64:
+				fmt.Fprintf(data, `<c r="%s"%s><v>%s</v></c>`, ref, style, xlsxNumber(v))
+			case int:
+				fmt.Fprintf(data, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			default:
+				return fmt.Errorf("cannot write a %T to cell %s", value, ref)
+			}
+		}
+		data.WriteString(`</row>`)
+	}
+
+	worksheet := xlsxXMLHeader + fmt.Sprintf(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><dimension ref="A1:%s%d"/><sheetViews><sheetView tabSelected="1" workbookViewId="0"/></sheetViews><sheetFormatPr defaultRowHeight="15"/><sheetData>%s</sheetData><pageMargins left="0.7" right="0.7" top="0.75" bottom="0.75" header="0.3" footer="0.3"/></worksheet>`,
+		xlsxColumn(max(cols, 1)-1), len(rows), data.String())
+
+	sst := new(bytes.Buffer)
+	fmt.Fprintf(sst, `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="%d" uniqueCount="%d">`, refs, len(strs))
+	for _, s := range strs {
+		sst.WriteString(`<si><t xml:space="preserve">` + escapeXML(s) + `</t></si>`)
+	}
+	sst.WriteString(`</sst>`)
+
+	workbook := xlsxXMLHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><fileVersion appName="xl" lastEdited="4" lowestEdited="4" rupBuild="4505"/><workbookPr defaultThemeVersion="124226"/><bookViews><workbookView xWindow="240" yWindow="15" windowWidth="16095" windowHeight="9660"/></bookViews><sheets><sheet name="` +
+		name + `" sheetId="1" r:id="rId1"/></sheets><calcPr calcId="124519" fullCalcOnLoad="1"/></workbook>`
+
+	created := now.UTC().Format("2006-01-02T15:04:05Z")
+	core := xlsxXMLHeader + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><dc:creator>Dukahub</dc:creator><cp:lastModifiedBy>Dukahub</cp:lastModifiedBy><dcterms:created xsi:type="dcterms:W3CDTF">` +
+		created + `</dcterms:created><dcterms:modified xsi:type="dcterms:W3CDTF">` + created + `</dcterms:modified></cp:coreProperties>`
+
+	app := xlsxXMLHeader + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"><Application>Dukahub</Application><DocSecurity>0</DocSecurity><ScaleCrop>false</ScaleCrop><HeadingPairs><vt:vector size="2" baseType="variant"><vt:variant><vt:lpstr>Worksheets</vt:lpstr></vt:variant><vt:variant><vt:i4>1</vt:i4></vt:variant></vt:vector></HeadingPairs><TitlesOfParts><vt:vector size="1" baseType="lpstr"><vt:lpstr>` +
+		name + `</vt:lpstr></vt:vector></TitlesOfParts><LinksUpToDate>false</LinksUpToDate><SharedDoc>false</SharedDoc><HyperlinksChanged>false</HyperlinksChanged><AppVersion>12.0000</AppVersion></Properties>`
+
+	zw := zip.NewWriter(w)
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/worksheets/sheet1.xml", worksheet},
+		{"xl/workbook.xml", workbook},
+		{"xl/sharedStrings.xml", xlsxXMLHeader + sst.String()},
+		{"xl/styles.xml", xlsxStyles},
+		{"docProps/core.xml", core},
+		{"docProps/app.xml", app},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// xlsxColumn returns the letters of the zero-based column i: A, B, ... Z, AA.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSerial returns t's wall-clock time as an Excel date serial.
+func xlsxSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(xlsxEpoch).Hours() / 24
+}
+
+func xlsxNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xlsxRow is a row read from a worksheet, numbered as in the spreadsheet.
+// Cells are strings, float64 numbers (dates among them, as serials) or nil.
+type xlsxRow struct {
+	Number int
+	Cells  []any
+}
+
+// xlsxReadSheet is a worksheet read from a workbook.
+type xlsxReadSheet struct {
+	Name string
+	Rows []xlsxRow
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	s := t.T
+	for _, run := range t.Runs {
+		s += run.T
+	}
+	return s
+}
+
+// readXLSX reads the sheets of an .xlsx workbook in workbook order.
+func readXLSX(r io.ReaderAt, size int64) ([]xlsxReadSheet, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an .xlsx workbook: %w", err)
+	}
+	parts := map[string]*zip.File{}
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	decode := func(name string, v any) error {
+		f, ok := parts[name]
+		if !ok {
+			return fmt.Errorf("workbook has no %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(rc).Decode(v); err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return nil
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = "xl/" + rel.Target
+		}
+	}
+
+	var sst struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+	}
+
+	sheets := make([]xlsxReadSheet, 0, len(workbook.Sheets))
+	for _, s := range workbook.Sheets {
+		var worksheet struct {
+			Rows []struct {
+				R     int `xml:"r,attr"`
+				Cells []struct {
+					R  string    `xml:"r,attr"`
+					T  string    `xml:"t,attr"`
+					V  string    `xml:"v"`
+					Is *xlsxText `xml:"is"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err := decode(targets[s.ID], &worksheet); err != nil {
+			return nil, fmt.Errorf("sheet %q: %w", s.Name, err)
+		}
+		sheet := xlsxReadSheet{Name: s.Name}
+		for i, row := range worksheet.Rows {
+			read := xlsxRow{Number: row.R}
+			if read.Number == 0 {
+				read.Number = i + 1
+			}
+			for j, c := range row.Cells {
+				col := j
+				if c.R != "" {
+					col = xlsxColumnIndex(c.R)
+				}
+				var value any
+				switch c.T {
+				case "s":
+					i, err := strconv.Atoi(c.V)
+					if err != nil || i < 0 || i >= len(sst.Items) {
+						return nil, fmt.Errorf("sheet %q cell %s: bad shared string %q", s.Name, c.R, c.V)
+					}
+					value = sst.Items[i].String()
+				case "inlineStr":
+					if c.Is != nil {
+						value = c.Is.String()
+					}
+				case "str", "e":
+					value = c.V
+				case "b":
+					value = map[string]string{"1": "TRUE", "0": "FALSE"}[c.V]
+				default:
+					if c.V == "" {
+						break
+					}
+					v, err := strconv.ParseFloat(c.V, 64)
+					if err != nil {
+						return nil, fmt.Errorf("sheet %q cell %s: bad number %q", s.Name, c.R, c.V)
+					}
+					value = v
+				}
+				for len(read.Cells) <= col {
+					read.Cells = append(read.Cells, nil)
+				}
+				read.Cells[col] = value
+			}
+			sheet.Rows = append(sheet.Rows, read)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// xlsxColumnIndex returns the zero-based column of a cell reference like
+// "AB12".
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// xlsxDate returns the day of an Excel date serial, ignoring its time.
+func xlsxDate(serial float64) time.Time {
+	return xlsxEpoch.AddDate(0, 0, int(math.Floor(serial+1e-9)))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kisinga/dukahub/lib"
+	"github.com/spf13/cobra"
+)
+
+// newSummariesCommand adds "summaries backfill", which recomputes the daily
+// summaries and product analytics of past days, e.g. after importing old
+// sales:
+//
+//	dukahub summaries backfill --from 2024-07-01 --to 2024-07-31 [--company ID]
+func newSummariesCommand(helper *lib.DbHelper) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "summaries",
+		Short: "Manage the daily summaries",
+	}
+
+	var from, to, companyID string
+	backfill := &cobra.Command{
+		Use:          "backfill",
+		Short:        "Recompute the daily summaries and product analytics of a range of days",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, err := time.Parse(time.DateOnly, from)
+			if err != nil {
+				return fmt.Errorf("invalid --from date: %w", err)
+			}
+			end := time.Now().UTC().AddDate(0, 0, -1)
+			if to != "" {
+				if end, err = time.Parse(time.DateOnly, to); err != nil {
+					return fmt.Errorf("invalid --to date: %w", err)
+				}
+			}
+			if end.Before(start) {
+				return fmt.Errorf("--from is after --to")
+			}
+
+			days := lib.NewDateRange(start, end)
+			n, err := helper.SummarizeDays(companyID, days)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %d daily summaries\n", n)
+
+			if n, err = helper.RebuildProductAnalytics(companyID, days); err != nil {
+				return err
+			}
+			fmt.Printf("Rebuilt the product analytics of %d days\n", n)
+			return nil
+		},
+	}
+	backfill.Flags().StringVar(&from, "from", "", "first day to summarise (YYYY-MM-DD)")
+	backfill.Flags().StringVar(&to, "to", "", "last day to summarise (YYYY-MM-DD, default yesterday)")
+	backfill.Flags().StringVar(&companyID, "company", "", "only summarise this company")
+	backfill.MarkFlagRequired("from")
+
+	command.AddCommand(backfill)
+	return command
+}
+
+// newImportCommand adds "import legacy", which imports the daily financial
+// and inventory logs the bookkeepers kept in spreadsheets:
+//
+//	dukahub import legacy --company ID --user EMAIL [--dry-run] [--report errors.csv] FILE...
+func newImportCommand(helper *lib.DbHelper) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "import",
+		Short: "Import data from outside Dukahub",
+	}
+
+	var opts lib.LegacyImportOptions
+	var report string
+	legacy := &cobra.Command{
+		Use:          "legacy FILE...",
+		Short:        "Import daily financial and inventory log workbooks (.xlsx)",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var results []*lib.LegacyImportResult
+			failed := 0
+			for _, path := range args {
+				result, err := importLegacyFile(helper, path, opts)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				results = append(results, result)
+				failed += len(result.Errors)
+
+				verb := "Imported"
+				if result.DryRun {
+					verb = "Would import"
+				}
+				fmt.Printf("%s: %s %d of %d rows: %d products created, %d daily accounts, %d stock takes, %d sales, %d purchases, %d stock movements\n",
+					result.File, verb, result.Imported, result.Rows, result.Products, result.DailyAccounts, result.StockTakes, result.Sales, result.Purchases, result.Movements)
+				for _, e := range result.Errors {
+					fmt.Printf("  %s row %d: %s\n", e.Sheet, e.Row, e.Message)
+				}
+			}
+
+			if report != "" {
+				if err := writeImportReport(report, results); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote the row errors to %s\n", report)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d rows could not be imported", failed)
+			}
+			return nil
+		},
+	}
+	legacy.Flags().StringVar(&opts.CompanyID, "company", "", "company to import into")
+	legacy.Flags().StringVar(&opts.UserID, "user", "", "ID or email of the member recorded as entering the rows")
+	legacy.Flags().StringVar(&opts.CashAccountID, "cash-account", "", "account of the cash columns (default: found by type or name)")
+	legacy.Flags().StringVar(&opts.MpesaAccountID, "mpesa-account", "", "account of the M-Pesa columns (default: found by type or name)")
+	legacy.Flags().StringVar(&opts.SkuID, "sku", "", "SKU of the products the import creates (default: that of the company's first product)")
+	legacy.Flags().BoolVar(&opts.DryRun, "dry-run", false, "check every row and report what would be imported, without saving")
+	legacy.Flags().StringVar(&report, "report", "", "also write the row errors to this CSV file")
+	legacy.MarkFlagRequired("company")
+	legacy.MarkFlagRequired("user")
+
+	command.AddCommand(legacy)
+	return command
+}
+
+func importLegacyFile(helper *lib.DbHelper, path string, opts lib.LegacyImportOptions) (*lib.LegacyImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return helper.ImportLegacyLog(filepath.Base(path), f, info.Size(), opts)
+}
+
+// writeImportReport writes one CSV line per row that was not imported.
+func writeImportReport(path string, results []*lib.LegacyImportResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"File", "Sheet", "Row", "Error"})
+	for _, result := range results {
+		for _, e := range result.Errors {
+			w.Write([]string{result.File, e.Sheet, strconv.Itoa(e.Row), e.Message})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
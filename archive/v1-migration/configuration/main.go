@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"io/fs"
 	"log"
 	"net/http"
+	_ "time/tzdata" // company timezones on hosts without zoneinfo
 
 	"github.com/kisinga/dukahub/lib"
 	"github.com/kisinga/dukahub/models"
 	"github.com/kisinga/dukahub/resolvers"
 	"github.com/kisinga/dukahub/views/pages"
+	"github.com/kisinga/dukahub/views/pages/dashboard"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
@@ -24,6 +28,45 @@ func main() {
 	app := pocketbase.New()
 	helper := lib.NewDbHelper(app, log.Default())
 
+	// Reject edits to sales, purchases, expenses and transactions in closed periods
+	helper.BindPeriodLocks()
+
+	// Keep document currencies, invoice and partner balances, document
+	// numbers, loyalty points and sale voids consistent as records change
+	helper.BindCurrencyStamps()
+	helper.BindInvoiceLifecycle()
+	helper.BindPartnerRules()
+	helper.BindDocumentNumbering()
+	helper.BindLoyalty()
+	helper.BindSaleVoids()
+
+	// Deliver queued SMS and email notifications
+	smsSender, err := lib.SMSSenderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	helper.SMSSender = smsSender
+	helper.BindNotifications()
+
+	// Queue sale receipts, low-stock alerts and payment reminders
+	helper.BindAlerts()
+
+	// Queue the daily and weekly digest emails as they fall due
+	helper.BindDigests(func(d *lib.Digest) (string, error) {
+		buf := new(bytes.Buffer)
+		err := dashboard.DigestEmail(d).Render(context.Background(), buf)
+		return buf.String(), err
+	})
+
+	// Keep daily_summaries and product_analytics up to date, and allow
+	// backfilling them on demand
+	helper.BindDailySummaries()
+	helper.BindProductAnalytics()
+	app.RootCmd.AddCommand(newSummariesCommand(helper))
+
+	// Import the bookkeepers' legacy daily log spreadsheets
+	app.RootCmd.AddCommand(newImportCommand(helper))
+
 	app.OnRecordAuthRequest("users").BindFunc(func(e *core.RecordAuthRequestEvent) error {
 		// Set HTTP-Only Auth Cookie
 		e.RequestEvent.SetCookie(&http.Cookie{
@@ -75,6 +118,11 @@ func main() {
 
 		adminDashboardGroup.GET("/export/{companyID}", resolvers.Admin.Export)
 
+		adminDashboardGroup.GET("/companies/{companyID}/periods", resolvers.Admin.ListPeriods)
+		adminDashboardGroup.POST("/companies/{companyID}/periods", resolvers.Admin.CreatePeriod)
+		adminDashboardGroup.POST("/periods/{periodID}/close", resolvers.Admin.ClosePeriod)
+		adminDashboardGroup.POST("/periods/{periodID}/reopen", resolvers.Admin.ReopenPeriod)
+
 		// route for when someone navigates to dashboard without a company
 		se.Router.GET("/dashboard/{$}", resolvers.Dashboard.Root)
 
@@ -82,6 +130,7 @@ func main() {
 
 		// For every dashboard route, check if user is logged in and forward the userID through the context
 		dashboardGroup.BindFunc(resolvers.Dashboard.AuthCheck)
+		dashboardGroup.BindFunc(resolvers.Dashboard.CompanyCheck)
 
 		// root dashboard route with a valid companyID
 		dashboardGroup.GET("/", resolvers.Dashboard.Home)
@@ -92,6 +141,63 @@ func main() {
 
 		dashboardGroup.GET("/cash-register", resolvers.Dashboard.Register)
 
+		dashboardGroup.GET("/ledger-export", resolvers.Dashboard.LedgerExport)
+		dashboardGroup.GET("/ledger-mappings", resolvers.Dashboard.LedgerMappings)
+		dashboardGroup.POST("/ledger-mappings", resolvers.Dashboard.SaveLedgerMapping)
+		dashboardGroup.PUT("/ledger-mappings/{mappingID}", resolvers.Dashboard.SaveLedgerMapping)
+		dashboardGroup.DELETE("/ledger-mappings/{mappingID}", resolvers.Dashboard.DeleteLedgerMapping)
+		dashboardGroup.GET("/daily-log-export", resolvers.Dashboard.DailyLogExport)
+
+		dashboardGroup.POST("/transfers", resolvers.Dashboard.CreateTransfer)
+
+		dashboardGroup.GET("/document-sequences", resolvers.Dashboard.DocumentSequences)
+		dashboardGroup.PUT("/document-sequences/{documentType}", resolvers.Dashboard.UpdateDocumentSequence)
+
+		dashboardGroup.POST("/payments", resolvers.Dashboard.CreatePayment)
+		dashboardGroup.POST("/credit-overrides", resolvers.Dashboard.ApproveCreditOverride)
+
+		dashboardGroup.GET("/invoices/{invoiceID}", resolvers.Dashboard.InvoiceDocument)
+		dashboardGroup.POST("/invoices/{invoiceID}/send", resolvers.Dashboard.SendInvoice)
+
+		dashboardGroup.GET("/digest-subscription", resolvers.Dashboard.DigestSubscription)
+		dashboardGroup.PUT("/digest-subscription", resolvers.Dashboard.UpdateDigestSubscription)
+		dashboardGroup.GET("/digest/preview", resolvers.Dashboard.DigestPreview)
+
+		dashboardGroup.GET("/notifications", resolvers.Dashboard.Notifications)
+		dashboardGroup.POST("/notifications/{notificationID}/retry", resolvers.Dashboard.RetryNotification)
+
+		dashboardGroup.GET("/reports/fx-gains", resolvers.Dashboard.FXGains)
+		dashboardGroup.GET("/reports/daily-summaries", resolvers.Dashboard.DailySummaries)
+		dashboardGroup.GET("/reports/products", resolvers.Dashboard.ProductAnalytics)
+		dashboardGroup.GET("/reports/sales-heatmap", resolvers.Dashboard.SalesHeatmap)
+		dashboardGroup.GET("/reports/abc", resolvers.Dashboard.ABCClassification)
+		dashboardGroup.GET("/reports/dead-stock", resolvers.Dashboard.DeadStock)
+		dashboardGroup.GET("/reports/demand-forecast", resolvers.Dashboard.DemandForecast)
+		dashboardGroup.GET("/reports/salespeople", resolvers.Dashboard.SalespeoplePerformance)
+
+		dashboardGroup.POST("/reorder-points", resolvers.Dashboard.ApplyReorderPoints)
+
+		dashboardGroup.GET("/commission-rules", resolvers.Dashboard.CommissionRules)
+		dashboardGroup.POST("/commission-rules", resolvers.Dashboard.SaveCommissionRule)
+		dashboardGroup.PUT("/commission-rules/{ruleID}", resolvers.Dashboard.SaveCommissionRule)
+		dashboardGroup.DELETE("/commission-rules/{ruleID}", resolvers.Dashboard.DeleteCommissionRule)
+		dashboardGroup.GET("/commission-statements", resolvers.Dashboard.CommissionStatements)
+		dashboardGroup.POST("/commission-statements", resolvers.Dashboard.IssueCommissionStatements)
+		dashboardGroup.POST("/commission-statements/{statementID}/pay", resolvers.Dashboard.PayCommissionStatement)
+
+		dashboardGroup.GET("/aging", resolvers.Dashboard.Aging)
+		dashboardGroup.GET("/partners/{partnerID}/statement", resolvers.Dashboard.PartnerStatement)
+		dashboardGroup.GET("/partners/duplicates", resolvers.Dashboard.DuplicatePartners)
+		dashboardGroup.GET("/partners/matches", resolvers.Dashboard.MatchPartner)
+		dashboardGroup.POST("/partners/{partnerID}/merge", resolvers.Dashboard.MergePartners)
+		dashboardGroup.GET("/partners/{partnerID}/loyalty", resolvers.Dashboard.LoyaltyAccount)
+		dashboardGroup.PUT("/loyalty-program", resolvers.Dashboard.UpdateLoyaltyProgram)
+
+		dashboardGroup.POST("/purchase-orders", resolvers.Dashboard.CreatePurchaseOrder)
+		dashboardGroup.GET("/purchase-orders/{orderID}", resolvers.Dashboard.GetPurchaseOrder)
+		dashboardGroup.POST("/purchase-orders/{orderID}/cancel", resolvers.Dashboard.CancelPurchaseOrder)
+		dashboardGroup.POST("/purchase-orders/{orderID}/receipts", resolvers.Dashboard.ReceiveGoods)
+
 		return se.Next()
 	})
 
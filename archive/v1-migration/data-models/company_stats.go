@@ -1,6 +1,38 @@
 package models
 
+import "time"
+
+// CompanyStats are the headline figures of a company's home page, in its
+// base currency. "Today" and the weeks follow the company's timezone.
 type CompanyStats struct {
-	CompanyID string `json:"company_id"`
-	Total     int    `json:"total"`
+	CompanyID       string           `json:"company_id"`
+	Currency        string           `json:"currency"`
+	TodaySales      float64          `json:"today_sales"`
+	TodayPurchases  float64          `json:"today_purchases"`
+	TodayExpenses   float64          `json:"today_expenses"`
+	WeekToDate      float64          `json:"week_to_date"`
+	LastWeekToDate  float64          `json:"last_week_to_date"`
+	WeekChange      *float64         `json:"week_change"`
+	TopProducts     []ProductStat    `json:"top_products"`
+	LowStock        int              `json:"low_stock"`
+	OpenReceivables float64          `json:"open_receivables"`
+	Accounts        []AccountBalance `json:"accounts"`
+	CashBalance     float64          `json:"cash_balance"`
+	GeneratedAt     time.Time        `json:"generated_at"`
+}
+
+// ProductStat is a product's sales over the week so far.
+type ProductStat struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Revenue   float64 `json:"revenue"`
+}
+
+// AccountBalance is a company account's balance in its own currency.
+type AccountBalance struct {
+	AccountID string  `json:"account_id"`
+	Name      string  `json:"name"`
+	Currency  string  `json:"currency"`
+	Balance   float64 `json:"balance"`
 }
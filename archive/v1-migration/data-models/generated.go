@@ -643,6 +643,25 @@ func (p *Products) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+type PartnerRoleSelectType int
+
+const (
+	PartnerCustomer PartnerRoleSelectType = iota
+	PartnerSupplier
+	PartnerBoth
+)
+
+var zzPartnerRoleSelectTypeSelectNameMap = map[string]PartnerRoleSelectType{
+	"customer": 0,
+	"supplier": 1,
+	"both":     2,
+}
+var zzPartnerRoleSelectTypeSelectIotaMap = map[PartnerRoleSelectType]string{
+	0: "customer",
+	1: "supplier",
+	2: "both",
+}
+
 type Partners struct {
 	core.BaseRecordProxy
 }
@@ -715,6 +734,55 @@ func (p *Partners) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *Partners) Credit() float64 {
+	return p.GetFloat("credit")
+}
+
+func (p *Partners) SetCredit(credit float64) {
+	p.Set("credit", credit)
+}
+
+func (p *Partners) Role() PartnerRoleSelectType {
+	option := p.GetString("role")
+	i, ok := zzPartnerRoleSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *Partners) SetRole(role PartnerRoleSelectType) {
+	i, ok := zzPartnerRoleSelectTypeSelectIotaMap[role]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("role", i)
+}
+
+func (p *Partners) CreditLimit() float64 {
+	return p.GetFloat("credit_limit")
+}
+
+func (p *Partners) SetCreditLimit(creditLimit float64) {
+	p.Set("credit_limit", creditLimit)
+}
+
+func (p *Partners) PaymentTermsDays() float64 {
+	return p.GetFloat("payment_terms_days")
+}
+
+func (p *Partners) SetPaymentTermsDays(paymentTermsDays float64) {
+	p.Set("payment_terms_days", paymentTermsDays)
+}
+
+func (p *Partners) LoyaltyPoints() float64 {
+	return p.GetFloat("loyalty_points")
+}
+
+func (p *Partners) SetLoyaltyPoints(loyaltyPoints float64) {
+	p.Set("loyalty_points", loyaltyPoints)
+}
+
 type StatusSelectType int
 
 const (
@@ -927,6 +995,77 @@ func (p *Invoices) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *Invoices) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *Invoices) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *Invoices) ExchangeRate() float64 {
+	return p.GetFloat("exchange_rate")
+}
+
+func (p *Invoices) SetExchangeRate(exchangeRate float64) {
+	p.Set("exchange_rate", exchangeRate)
+}
+
+func (p *Invoices) RealisedFx() float64 {
+	return p.GetFloat("realised_fx")
+}
+
+func (p *Invoices) SetRealisedFx(realisedFx float64) {
+	p.Set("realised_fx", realisedFx)
+}
+
+func (p *Invoices) DueDate() types.DateTime {
+	return p.GetDateTime("due_date")
+}
+
+func (p *Invoices) SetDueDate(dueDate types.DateTime) {
+	p.Set("due_date", dueDate)
+}
+
+func (p *Invoices) Number() string {
+	return p.GetString("number")
+}
+
+func (p *Invoices) SetNumber(number string) {
+	p.Set("number", number)
+}
+
+func (p *Invoices) Sales() []*SalesTransactions {
+	rels := p.ExpandedAll("sales")
+	proxies := make([]*SalesTransactions, len(rels))
+	for i := range len(rels) {
+		proxies[i] = &SalesTransactions{}
+		proxies[i].Record = rels[i]
+	}
+	return proxies
+}
+
+func (p *Invoices) SetSales(sales []*SalesTransactions) {
+	records := make([]*core.Record, len(sales))
+	ids := make([]string, len(sales))
+	for i, r := range sales {
+		records[i] = r.Record
+		ids[i] = r.Record.Id
+	}
+	p.Record.Set("sales", ids)
+	e := p.Expand()
+	e["sales"] = records
+	p.SetExpand(e)
+}
+
+func (p *Invoices) SentAt() types.DateTime {
+	return p.GetDateTime("sent_at")
+}
+
+func (p *Invoices) SetSentAt(sentAt types.DateTime) {
+	p.Set("sent_at", sentAt)
+}
+
 type Purchases struct {
 	core.BaseRecordProxy
 }
@@ -1111,6 +1250,30 @@ func (p *Purchases) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *Purchases) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *Purchases) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *Purchases) ExchangeRate() float64 {
+	return p.GetFloat("exchange_rate")
+}
+
+func (p *Purchases) SetExchangeRate(exchangeRate float64) {
+	p.Set("exchange_rate", exchangeRate)
+}
+
+func (p *Purchases) UnitCost() float64 {
+	return p.GetFloat("unit_cost")
+}
+
+func (p *Purchases) SetUnitCost(unitCost float64) {
+	p.Set("unit_cost", unitCost)
+}
+
 type CompanyTypeSelectType int
 
 const (
@@ -1283,6 +1446,22 @@ func (p *Companies) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *Companies) BaseCurrency() string {
+	return p.GetString("base_currency")
+}
+
+func (p *Companies) SetBaseCurrency(baseCurrency string) {
+	p.Set("base_currency", baseCurrency)
+}
+
+func (p *Companies) Timezone() string {
+	return p.GetString("timezone")
+}
+
+func (p *Companies) SetTimezone(timezone string) {
+	p.Set("timezone", timezone)
+}
+
 type CompanyAccounts struct {
 	core.BaseRecordProxy
 }
@@ -1419,6 +1598,14 @@ func (p *CompanyAccounts) SetDeletedAt(deletedAt types.DateTime) {
 	p.Set("deleted_at", deletedAt)
 }
 
+func (p *CompanyAccounts) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *CompanyAccounts) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
 type TypeSelectType2 int
 
 const (
@@ -1442,6 +1629,7 @@ const (
 	Purchase2
 	Expense
 	Adjustment
+	Transfer
 )
 
 var zzReferenceTypeSelectTypeSelectNameMap = map[string]ReferenceTypeSelectType{
@@ -1449,12 +1637,14 @@ var zzReferenceTypeSelectTypeSelectNameMap = map[string]ReferenceTypeSelectType{
 	"purchase":   1,
 	"expense":    2,
 	"adjustment": 3,
+	"transfer":   4,
 }
 var zzReferenceTypeSelectTypeSelectIotaMap = map[ReferenceTypeSelectType]string{
 	0: "sale",
 	1: "purchase",
 	2: "expense",
 	3: "adjustment",
+	4: "transfer",
 }
 
 type Transactions struct {
@@ -1635,6 +1825,38 @@ func (p *Transactions) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *Transactions) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *Transactions) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *Transactions) OriginalAmount() float64 {
+	return p.GetFloat("original_amount")
+}
+
+func (p *Transactions) SetOriginalAmount(originalAmount float64) {
+	p.Set("original_amount", originalAmount)
+}
+
+func (p *Transactions) OriginalTaxAmount() float64 {
+	return p.GetFloat("original_tax_amount")
+}
+
+func (p *Transactions) SetOriginalTaxAmount(originalTaxAmount float64) {
+	p.Set("original_tax_amount", originalTaxAmount)
+}
+
+func (p *Transactions) ExchangeRate() float64 {
+	return p.GetFloat("exchange_rate")
+}
+
+func (p *Transactions) SetExchangeRate(exchangeRate float64) {
+	p.Set("exchange_rate", exchangeRate)
+}
+
 type SalesDetails struct {
 	core.BaseRecordProxy
 }
@@ -2370,6 +2592,94 @@ func (p *SalesTransactions) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *SalesTransactions) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *SalesTransactions) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *SalesTransactions) ExchangeRate() float64 {
+	return p.GetFloat("exchange_rate")
+}
+
+func (p *SalesTransactions) SetExchangeRate(exchangeRate float64) {
+	p.Set("exchange_rate", exchangeRate)
+}
+
+func (p *SalesTransactions) CreditOverride() *CreditOverrides {
+	var proxy *CreditOverrides
+	if rel := p.ExpandedOne("credit_override"); rel != nil {
+		proxy = &CreditOverrides{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *SalesTransactions) SetCreditOverride(creditOverride *CreditOverrides) {
+	var id string
+	if creditOverride != nil {
+		id = creditOverride.Id
+	}
+	p.Record.Set("credit_override", id)
+	e := p.Expand()
+	if creditOverride != nil {
+		e["credit_override"] = creditOverride.Record
+	} else {
+		delete(e, "credit_override")
+	}
+	p.SetExpand(e)
+}
+
+func (p *SalesTransactions) Number() string {
+	return p.GetString("number")
+}
+
+func (p *SalesTransactions) SetNumber(number string) {
+	p.Set("number", number)
+}
+
+func (p *SalesTransactions) LoyaltyPointsRedeemed() float64 {
+	return p.GetFloat("loyalty_points_redeemed")
+}
+
+func (p *SalesTransactions) SetLoyaltyPointsRedeemed(loyaltyPointsRedeemed float64) {
+	p.Set("loyalty_points_redeemed", loyaltyPointsRedeemed)
+}
+
+func (p *SalesTransactions) LoyaltyAmount() float64 {
+	return p.GetFloat("loyalty_amount")
+}
+
+func (p *SalesTransactions) SetLoyaltyAmount(loyaltyAmount float64) {
+	p.Set("loyalty_amount", loyaltyAmount)
+}
+
+func (p *SalesTransactions) ReturnOf() *SalesTransactions {
+	var proxy *SalesTransactions
+	if rel := p.ExpandedOne("return_of"); rel != nil {
+		proxy = &SalesTransactions{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *SalesTransactions) SetReturnOf(returnOf *SalesTransactions) {
+	var id string
+	if returnOf != nil {
+		id = returnOf.Id
+	}
+	p.Record.Set("return_of", id)
+	e := p.Expand()
+	if returnOf != nil {
+		e["return_of"] = returnOf.Record
+	} else {
+		delete(e, "return_of")
+	}
+	p.SetExpand(e)
+}
+
 type Admins struct {
 	core.BaseRecordProxy
 }
@@ -2919,6 +3229,49 @@ func (p *InventoryTransactions) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
 
+func (p *InventoryTransactions) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *InventoryTransactions) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+type AnalyticsPeriodTypeSelectType int
+
+const (
+	AnalyticsDay AnalyticsPeriodTypeSelectType = iota
+	AnalyticsWeek
+	AnalyticsMonth
+)
+
+var zzAnalyticsPeriodTypeSelectTypeSelectNameMap = map[string]AnalyticsPeriodTypeSelectType{
+	"day":   0,
+	"week":  1,
+	"month": 2,
+}
+var zzAnalyticsPeriodTypeSelectTypeSelectIotaMap = map[AnalyticsPeriodTypeSelectType]string{
+	0: "day",
+	1: "week",
+	2: "month",
+}
+
 type ProductAnalytics struct {
 	core.BaseRecordProxy
 }
@@ -3038,3 +3391,2505 @@ func (p *ProductAnalytics) Updated() types.DateTime {
 func (p *ProductAnalytics) SetUpdated(updated types.DateTime) {
 	p.Set("updated", updated)
 }
+
+func (p *ProductAnalytics) PeriodType() AnalyticsPeriodTypeSelectType {
+	option := p.GetString("period_type")
+	i, ok := zzAnalyticsPeriodTypeSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *ProductAnalytics) SetPeriodType(periodType AnalyticsPeriodTypeSelectType) {
+	i, ok := zzAnalyticsPeriodTypeSelectTypeSelectIotaMap[periodType]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("period_type", i)
+}
+
+func (p *ProductAnalytics) PeriodStart() types.DateTime {
+	return p.GetDateTime("period_start")
+}
+
+func (p *ProductAnalytics) SetPeriodStart(periodStart types.DateTime) {
+	p.Set("period_start", periodStart)
+}
+
+type PeriodStatusSelectType int
+
+const (
+	PeriodOpen PeriodStatusSelectType = iota
+	PeriodClosed
+)
+
+var zzPeriodStatusSelectTypeSelectNameMap = map[string]PeriodStatusSelectType{
+	"open":   0,
+	"closed": 1,
+}
+var zzPeriodStatusSelectTypeSelectIotaMap = map[PeriodStatusSelectType]string{
+	0: "open",
+	1: "closed",
+}
+
+type AccountingPeriods struct {
+	core.BaseRecordProxy
+}
+
+func (p *AccountingPeriods) CollectionName() string {
+	return "accounting_periods"
+}
+
+func (p *AccountingPeriods) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *AccountingPeriods) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *AccountingPeriods) Name() string {
+	return p.GetString("name")
+}
+
+func (p *AccountingPeriods) SetName(name string) {
+	p.Set("name", name)
+}
+
+func (p *AccountingPeriods) StartDate() types.DateTime {
+	return p.GetDateTime("start_date")
+}
+
+func (p *AccountingPeriods) SetStartDate(startDate types.DateTime) {
+	p.Set("start_date", startDate)
+}
+
+func (p *AccountingPeriods) EndDate() types.DateTime {
+	return p.GetDateTime("end_date")
+}
+
+func (p *AccountingPeriods) SetEndDate(endDate types.DateTime) {
+	p.Set("end_date", endDate)
+}
+
+func (p *AccountingPeriods) Status() PeriodStatusSelectType {
+	option := p.GetString("status")
+	i, ok := zzPeriodStatusSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *AccountingPeriods) SetStatus(status PeriodStatusSelectType) {
+	i, ok := zzPeriodStatusSelectTypeSelectIotaMap[status]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("status", i)
+}
+
+func (p *AccountingPeriods) ClosedAt() types.DateTime {
+	return p.GetDateTime("closed_at")
+}
+
+func (p *AccountingPeriods) SetClosedAt(closedAt types.DateTime) {
+	p.Set("closed_at", closedAt)
+}
+
+func (p *AccountingPeriods) ClosedBy() *Admins {
+	var proxy *Admins
+	if rel := p.ExpandedOne("closed_by"); rel != nil {
+		proxy = &Admins{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *AccountingPeriods) SetClosedBy(closedBy *Admins) {
+	var id string
+	if closedBy != nil {
+		id = closedBy.Id
+	}
+	p.Record.Set("closed_by", id)
+	e := p.Expand()
+	if closedBy != nil {
+		e["closed_by"] = closedBy.Record
+	} else {
+		delete(e, "closed_by")
+	}
+	p.SetExpand(e)
+}
+
+func (p *AccountingPeriods) CloseReason() string {
+	return p.GetString("close_reason")
+}
+
+func (p *AccountingPeriods) SetCloseReason(closeReason string) {
+	p.Set("close_reason", closeReason)
+}
+
+func (p *AccountingPeriods) ReopenedAt() types.DateTime {
+	return p.GetDateTime("reopened_at")
+}
+
+func (p *AccountingPeriods) SetReopenedAt(reopenedAt types.DateTime) {
+	p.Set("reopened_at", reopenedAt)
+}
+
+func (p *AccountingPeriods) ReopenedBy() *Admins {
+	var proxy *Admins
+	if rel := p.ExpandedOne("reopened_by"); rel != nil {
+		proxy = &Admins{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *AccountingPeriods) SetReopenedBy(reopenedBy *Admins) {
+	var id string
+	if reopenedBy != nil {
+		id = reopenedBy.Id
+	}
+	p.Record.Set("reopened_by", id)
+	e := p.Expand()
+	if reopenedBy != nil {
+		e["reopened_by"] = reopenedBy.Record
+	} else {
+		delete(e, "reopened_by")
+	}
+	p.SetExpand(e)
+}
+
+func (p *AccountingPeriods) ReopenReason() string {
+	return p.GetString("reopen_reason")
+}
+
+func (p *AccountingPeriods) SetReopenReason(reopenReason string) {
+	p.Set("reopen_reason", reopenReason)
+}
+
+func (p *AccountingPeriods) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *AccountingPeriods) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *AccountingPeriods) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *AccountingPeriods) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type LedgerSystemSelectType int
+
+const (
+	LedgerGeneric LedgerSystemSelectType = iota
+	LedgerQuickBooks
+	LedgerXero
+)
+
+var zzLedgerSystemSelectTypeSelectNameMap = map[string]LedgerSystemSelectType{
+	"generic":    0,
+	"quickbooks": 1,
+	"xero":       2,
+}
+var zzLedgerSystemSelectTypeSelectIotaMap = map[LedgerSystemSelectType]string{
+	0: "generic",
+	1: "quickbooks",
+	2: "xero",
+}
+
+type ContraTypeSelectType int
+
+const (
+	ContraSale ContraTypeSelectType = iota
+	ContraPurchase
+	ContraExpense
+	ContraAdjustment
+	ContraTax
+	ContraTransfer
+)
+
+var zzContraTypeSelectTypeSelectNameMap = map[string]ContraTypeSelectType{
+	"sale":       0,
+	"purchase":   1,
+	"expense":    2,
+	"adjustment": 3,
+	"tax":        4,
+	"transfer":   5,
+}
+var zzContraTypeSelectTypeSelectIotaMap = map[ContraTypeSelectType]string{
+	0: "sale",
+	1: "purchase",
+	2: "expense",
+	3: "adjustment",
+	4: "tax",
+	5: "transfer",
+}
+
+type LedgerAccountMappings struct {
+	core.BaseRecordProxy
+}
+
+func (p *LedgerAccountMappings) CollectionName() string {
+	return "ledger_account_mappings"
+}
+
+func (p *LedgerAccountMappings) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LedgerAccountMappings) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LedgerAccountMappings) System() LedgerSystemSelectType {
+	option := p.GetString("system")
+	i, ok := zzLedgerSystemSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *LedgerAccountMappings) SetSystem(system LedgerSystemSelectType) {
+	i, ok := zzLedgerSystemSelectTypeSelectIotaMap[system]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("system", i)
+}
+
+func (p *LedgerAccountMappings) CompanyAccount() *CompanyAccounts {
+	var proxy *CompanyAccounts
+	if rel := p.ExpandedOne("company_account"); rel != nil {
+		proxy = &CompanyAccounts{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LedgerAccountMappings) SetCompanyAccount(companyAccount *CompanyAccounts) {
+	var id string
+	if companyAccount != nil {
+		id = companyAccount.Id
+	}
+	p.Record.Set("company_account", id)
+	e := p.Expand()
+	if companyAccount != nil {
+		e["company_account"] = companyAccount.Record
+	} else {
+		delete(e, "company_account")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LedgerAccountMappings) AccountType() *AccountTypes {
+	var proxy *AccountTypes
+	if rel := p.ExpandedOne("account_type"); rel != nil {
+		proxy = &AccountTypes{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LedgerAccountMappings) SetAccountType(accountType *AccountTypes) {
+	var id string
+	if accountType != nil {
+		id = accountType.Id
+	}
+	p.Record.Set("account_type", id)
+	e := p.Expand()
+	if accountType != nil {
+		e["account_type"] = accountType.Record
+	} else {
+		delete(e, "account_type")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LedgerAccountMappings) ReferenceType() ContraTypeSelectType {
+	option := p.GetString("reference_type")
+	i, ok := zzContraTypeSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *LedgerAccountMappings) SetReferenceType(referenceType ContraTypeSelectType) {
+	i, ok := zzContraTypeSelectTypeSelectIotaMap[referenceType]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("reference_type", i)
+}
+
+func (p *LedgerAccountMappings) ExternalCode() string {
+	return p.GetString("external_code")
+}
+
+func (p *LedgerAccountMappings) SetExternalCode(externalCode string) {
+	p.Set("external_code", externalCode)
+}
+
+func (p *LedgerAccountMappings) ExternalName() string {
+	return p.GetString("external_name")
+}
+
+func (p *LedgerAccountMappings) SetExternalName(externalName string) {
+	p.Set("external_name", externalName)
+}
+
+func (p *LedgerAccountMappings) TaxCode() string {
+	return p.GetString("tax_code")
+}
+
+func (p *LedgerAccountMappings) SetTaxCode(taxCode string) {
+	p.Set("tax_code", taxCode)
+}
+
+func (p *LedgerAccountMappings) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *LedgerAccountMappings) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *LedgerAccountMappings) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *LedgerAccountMappings) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type ExchangeRates struct {
+	core.BaseRecordProxy
+}
+
+func (p *ExchangeRates) CollectionName() string {
+	return "exchange_rates"
+}
+
+func (p *ExchangeRates) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *ExchangeRates) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *ExchangeRates) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *ExchangeRates) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *ExchangeRates) Rate() float64 {
+	return p.GetFloat("rate")
+}
+
+func (p *ExchangeRates) SetRate(rate float64) {
+	p.Set("rate", rate)
+}
+
+func (p *ExchangeRates) EffectiveDate() types.DateTime {
+	return p.GetDateTime("effective_date")
+}
+
+func (p *ExchangeRates) SetEffectiveDate(effectiveDate types.DateTime) {
+	p.Set("effective_date", effectiveDate)
+}
+
+func (p *ExchangeRates) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *ExchangeRates) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *ExchangeRates) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *ExchangeRates) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type PurchaseOrderStatusSelectType int
+
+const (
+	OrderOpen PurchaseOrderStatusSelectType = iota
+	OrderPartial
+	OrderReceived
+	OrderCancelled
+)
+
+var zzPurchaseOrderStatusSelectTypeSelectNameMap = map[string]PurchaseOrderStatusSelectType{
+	"open":      0,
+	"partial":   1,
+	"received":  2,
+	"cancelled": 3,
+}
+var zzPurchaseOrderStatusSelectTypeSelectIotaMap = map[PurchaseOrderStatusSelectType]string{
+	0: "open",
+	1: "partial",
+	2: "received",
+	3: "cancelled",
+}
+
+type PurchaseOrders struct {
+	core.BaseRecordProxy
+}
+
+func (p *PurchaseOrders) CollectionName() string {
+	return "purchase_orders"
+}
+
+func (p *PurchaseOrders) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrders) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrders) Supplier() *Partners {
+	var proxy *Partners
+	if rel := p.ExpandedOne("supplier"); rel != nil {
+		proxy = &Partners{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrders) SetSupplier(supplier *Partners) {
+	var id string
+	if supplier != nil {
+		id = supplier.Id
+	}
+	p.Record.Set("supplier", id)
+	e := p.Expand()
+	if supplier != nil {
+		e["supplier"] = supplier.Record
+	} else {
+		delete(e, "supplier")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrders) Number() string {
+	return p.GetString("number")
+}
+
+func (p *PurchaseOrders) SetNumber(number string) {
+	p.Set("number", number)
+}
+
+func (p *PurchaseOrders) Status() PurchaseOrderStatusSelectType {
+	option := p.GetString("status")
+	i, ok := zzPurchaseOrderStatusSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *PurchaseOrders) SetStatus(status PurchaseOrderStatusSelectType) {
+	i, ok := zzPurchaseOrderStatusSelectTypeSelectIotaMap[status]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("status", i)
+}
+
+func (p *PurchaseOrders) OrderDate() types.DateTime {
+	return p.GetDateTime("order_date")
+}
+
+func (p *PurchaseOrders) SetOrderDate(orderDate types.DateTime) {
+	p.Set("order_date", orderDate)
+}
+
+func (p *PurchaseOrders) ExpectedDate() types.DateTime {
+	return p.GetDateTime("expected_date")
+}
+
+func (p *PurchaseOrders) SetExpectedDate(expectedDate types.DateTime) {
+	p.Set("expected_date", expectedDate)
+}
+
+func (p *PurchaseOrders) Currency() string {
+	return p.GetString("currency")
+}
+
+func (p *PurchaseOrders) SetCurrency(currency string) {
+	p.Set("currency", currency)
+}
+
+func (p *PurchaseOrders) Total() float64 {
+	return p.GetFloat("total")
+}
+
+func (p *PurchaseOrders) SetTotal(total float64) {
+	p.Set("total", total)
+}
+
+func (p *PurchaseOrders) Notes() string {
+	return p.GetString("notes")
+}
+
+func (p *PurchaseOrders) SetNotes(notes string) {
+	p.Set("notes", notes)
+}
+
+func (p *PurchaseOrders) User() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("user"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrders) SetUser(user *Users) {
+	var id string
+	if user != nil {
+		id = user.Id
+	}
+	p.Record.Set("user", id)
+	e := p.Expand()
+	if user != nil {
+		e["user"] = user.Record
+	} else {
+		delete(e, "user")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrders) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *PurchaseOrders) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *PurchaseOrders) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *PurchaseOrders) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type PurchaseOrderLines struct {
+	core.BaseRecordProxy
+}
+
+func (p *PurchaseOrderLines) CollectionName() string {
+	return "purchase_order_lines"
+}
+
+func (p *PurchaseOrderLines) PurchaseOrder() *PurchaseOrders {
+	var proxy *PurchaseOrders
+	if rel := p.ExpandedOne("purchase_order"); rel != nil {
+		proxy = &PurchaseOrders{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrderLines) SetPurchaseOrder(purchaseOrder *PurchaseOrders) {
+	var id string
+	if purchaseOrder != nil {
+		id = purchaseOrder.Id
+	}
+	p.Record.Set("purchase_order", id)
+	e := p.Expand()
+	if purchaseOrder != nil {
+		e["purchase_order"] = purchaseOrder.Record
+	} else {
+		delete(e, "purchase_order")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrderLines) Product() *Products {
+	var proxy *Products
+	if rel := p.ExpandedOne("product"); rel != nil {
+		proxy = &Products{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrderLines) SetProduct(product *Products) {
+	var id string
+	if product != nil {
+		id = product.Id
+	}
+	p.Record.Set("product", id)
+	e := p.Expand()
+	if product != nil {
+		e["product"] = product.Record
+	} else {
+		delete(e, "product")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrderLines) Sku() *Skus {
+	var proxy *Skus
+	if rel := p.ExpandedOne("sku"); rel != nil {
+		proxy = &Skus{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *PurchaseOrderLines) SetSku(sku *Skus) {
+	var id string
+	if sku != nil {
+		id = sku.Id
+	}
+	p.Record.Set("sku", id)
+	e := p.Expand()
+	if sku != nil {
+		e["sku"] = sku.Record
+	} else {
+		delete(e, "sku")
+	}
+	p.SetExpand(e)
+}
+
+func (p *PurchaseOrderLines) Quantity() float64 {
+	return p.GetFloat("quantity")
+}
+
+func (p *PurchaseOrderLines) SetQuantity(quantity float64) {
+	p.Set("quantity", quantity)
+}
+
+func (p *PurchaseOrderLines) UnitPrice() float64 {
+	return p.GetFloat("unit_price")
+}
+
+func (p *PurchaseOrderLines) SetUnitPrice(unitPrice float64) {
+	p.Set("unit_price", unitPrice)
+}
+
+func (p *PurchaseOrderLines) ReceivedQuantity() float64 {
+	return p.GetFloat("received_quantity")
+}
+
+func (p *PurchaseOrderLines) SetReceivedQuantity(receivedQuantity float64) {
+	p.Set("received_quantity", receivedQuantity)
+}
+
+func (p *PurchaseOrderLines) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *PurchaseOrderLines) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *PurchaseOrderLines) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *PurchaseOrderLines) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type GoodsReceivedNotes struct {
+	core.BaseRecordProxy
+}
+
+func (p *GoodsReceivedNotes) CollectionName() string {
+	return "goods_received_notes"
+}
+
+func (p *GoodsReceivedNotes) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedNotes) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedNotes) PurchaseOrder() *PurchaseOrders {
+	var proxy *PurchaseOrders
+	if rel := p.ExpandedOne("purchase_order"); rel != nil {
+		proxy = &PurchaseOrders{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedNotes) SetPurchaseOrder(purchaseOrder *PurchaseOrders) {
+	var id string
+	if purchaseOrder != nil {
+		id = purchaseOrder.Id
+	}
+	p.Record.Set("purchase_order", id)
+	e := p.Expand()
+	if purchaseOrder != nil {
+		e["purchase_order"] = purchaseOrder.Record
+	} else {
+		delete(e, "purchase_order")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedNotes) Supplier() *Partners {
+	var proxy *Partners
+	if rel := p.ExpandedOne("supplier"); rel != nil {
+		proxy = &Partners{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedNotes) SetSupplier(supplier *Partners) {
+	var id string
+	if supplier != nil {
+		id = supplier.Id
+	}
+	p.Record.Set("supplier", id)
+	e := p.Expand()
+	if supplier != nil {
+		e["supplier"] = supplier.Record
+	} else {
+		delete(e, "supplier")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedNotes) Number() string {
+	return p.GetString("number")
+}
+
+func (p *GoodsReceivedNotes) SetNumber(number string) {
+	p.Set("number", number)
+}
+
+func (p *GoodsReceivedNotes) Date() types.DateTime {
+	return p.GetDateTime("date")
+}
+
+func (p *GoodsReceivedNotes) SetDate(date types.DateTime) {
+	p.Set("date", date)
+}
+
+func (p *GoodsReceivedNotes) Invoice() *Invoices {
+	var proxy *Invoices
+	if rel := p.ExpandedOne("invoice"); rel != nil {
+		proxy = &Invoices{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedNotes) SetInvoice(invoice *Invoices) {
+	var id string
+	if invoice != nil {
+		id = invoice.Id
+	}
+	p.Record.Set("invoice", id)
+	e := p.Expand()
+	if invoice != nil {
+		e["invoice"] = invoice.Record
+	} else {
+		delete(e, "invoice")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedNotes) HasVariance() bool {
+	return p.GetBool("has_variance")
+}
+
+func (p *GoodsReceivedNotes) SetHasVariance(hasVariance bool) {
+	p.Set("has_variance", hasVariance)
+}
+
+func (p *GoodsReceivedNotes) Notes() string {
+	return p.GetString("notes")
+}
+
+func (p *GoodsReceivedNotes) SetNotes(notes string) {
+	p.Set("notes", notes)
+}
+
+func (p *GoodsReceivedNotes) User() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("user"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedNotes) SetUser(user *Users) {
+	var id string
+	if user != nil {
+		id = user.Id
+	}
+	p.Record.Set("user", id)
+	e := p.Expand()
+	if user != nil {
+		e["user"] = user.Record
+	} else {
+		delete(e, "user")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedNotes) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *GoodsReceivedNotes) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *GoodsReceivedNotes) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *GoodsReceivedNotes) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type GoodsReceivedLines struct {
+	core.BaseRecordProxy
+}
+
+func (p *GoodsReceivedLines) CollectionName() string {
+	return "goods_received_lines"
+}
+
+func (p *GoodsReceivedLines) GoodsReceivedNote() *GoodsReceivedNotes {
+	var proxy *GoodsReceivedNotes
+	if rel := p.ExpandedOne("goods_received_note"); rel != nil {
+		proxy = &GoodsReceivedNotes{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedLines) SetGoodsReceivedNote(goodsReceivedNote *GoodsReceivedNotes) {
+	var id string
+	if goodsReceivedNote != nil {
+		id = goodsReceivedNote.Id
+	}
+	p.Record.Set("goods_received_note", id)
+	e := p.Expand()
+	if goodsReceivedNote != nil {
+		e["goods_received_note"] = goodsReceivedNote.Record
+	} else {
+		delete(e, "goods_received_note")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedLines) PurchaseOrderLine() *PurchaseOrderLines {
+	var proxy *PurchaseOrderLines
+	if rel := p.ExpandedOne("purchase_order_line"); rel != nil {
+		proxy = &PurchaseOrderLines{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedLines) SetPurchaseOrderLine(purchaseOrderLine *PurchaseOrderLines) {
+	var id string
+	if purchaseOrderLine != nil {
+		id = purchaseOrderLine.Id
+	}
+	p.Record.Set("purchase_order_line", id)
+	e := p.Expand()
+	if purchaseOrderLine != nil {
+		e["purchase_order_line"] = purchaseOrderLine.Record
+	} else {
+		delete(e, "purchase_order_line")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedLines) Product() *Products {
+	var proxy *Products
+	if rel := p.ExpandedOne("product"); rel != nil {
+		proxy = &Products{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedLines) SetProduct(product *Products) {
+	var id string
+	if product != nil {
+		id = product.Id
+	}
+	p.Record.Set("product", id)
+	e := p.Expand()
+	if product != nil {
+		e["product"] = product.Record
+	} else {
+		delete(e, "product")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedLines) Sku() *Skus {
+	var proxy *Skus
+	if rel := p.ExpandedOne("sku"); rel != nil {
+		proxy = &Skus{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedLines) SetSku(sku *Skus) {
+	var id string
+	if sku != nil {
+		id = sku.Id
+	}
+	p.Record.Set("sku", id)
+	e := p.Expand()
+	if sku != nil {
+		e["sku"] = sku.Record
+	} else {
+		delete(e, "sku")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedLines) Quantity() float64 {
+	return p.GetFloat("quantity")
+}
+
+func (p *GoodsReceivedLines) SetQuantity(quantity float64) {
+	p.Set("quantity", quantity)
+}
+
+func (p *GoodsReceivedLines) ExpectedPrice() float64 {
+	return p.GetFloat("expected_price")
+}
+
+func (p *GoodsReceivedLines) SetExpectedPrice(expectedPrice float64) {
+	p.Set("expected_price", expectedPrice)
+}
+
+func (p *GoodsReceivedLines) UnitPrice() float64 {
+	return p.GetFloat("unit_price")
+}
+
+func (p *GoodsReceivedLines) SetUnitPrice(unitPrice float64) {
+	p.Set("unit_price", unitPrice)
+}
+
+func (p *GoodsReceivedLines) PriceVariance() float64 {
+	return p.GetFloat("price_variance")
+}
+
+func (p *GoodsReceivedLines) SetPriceVariance(priceVariance float64) {
+	p.Set("price_variance", priceVariance)
+}
+
+func (p *GoodsReceivedLines) VarianceFlag() bool {
+	return p.GetBool("variance_flag")
+}
+
+func (p *GoodsReceivedLines) SetVarianceFlag(varianceFlag bool) {
+	p.Set("variance_flag", varianceFlag)
+}
+
+func (p *GoodsReceivedLines) Purchase() *Purchases {
+	var proxy *Purchases
+	if rel := p.ExpandedOne("purchase"); rel != nil {
+		proxy = &Purchases{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *GoodsReceivedLines) SetPurchase(purchase *Purchases) {
+	var id string
+	if purchase != nil {
+		id = purchase.Id
+	}
+	p.Record.Set("purchase", id)
+	e := p.Expand()
+	if purchase != nil {
+		e["purchase"] = purchase.Record
+	} else {
+		delete(e, "purchase")
+	}
+	p.SetExpand(e)
+}
+
+func (p *GoodsReceivedLines) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *GoodsReceivedLines) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *GoodsReceivedLines) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *GoodsReceivedLines) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type CreditOverrides struct {
+	core.BaseRecordProxy
+}
+
+func (p *CreditOverrides) CollectionName() string {
+	return "credit_overrides"
+}
+
+func (p *CreditOverrides) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CreditOverrides) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CreditOverrides) Partner() *Partners {
+	var proxy *Partners
+	if rel := p.ExpandedOne("partner"); rel != nil {
+		proxy = &Partners{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CreditOverrides) SetPartner(partner *Partners) {
+	var id string
+	if partner != nil {
+		id = partner.Id
+	}
+	p.Record.Set("partner", id)
+	e := p.Expand()
+	if partner != nil {
+		e["partner"] = partner.Record
+	} else {
+		delete(e, "partner")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CreditOverrides) ApprovedBy() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("approved_by"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CreditOverrides) SetApprovedBy(approvedBy *Users) {
+	var id string
+	if approvedBy != nil {
+		id = approvedBy.Id
+	}
+	p.Record.Set("approved_by", id)
+	e := p.Expand()
+	if approvedBy != nil {
+		e["approved_by"] = approvedBy.Record
+	} else {
+		delete(e, "approved_by")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CreditOverrides) Amount() float64 {
+	return p.GetFloat("amount")
+}
+
+func (p *CreditOverrides) SetAmount(amount float64) {
+	p.Set("amount", amount)
+}
+
+func (p *CreditOverrides) Reason() string {
+	return p.GetString("reason")
+}
+
+func (p *CreditOverrides) SetReason(reason string) {
+	p.Set("reason", reason)
+}
+
+func (p *CreditOverrides) ExpiresAt() types.DateTime {
+	return p.GetDateTime("expires_at")
+}
+
+func (p *CreditOverrides) SetExpiresAt(expiresAt types.DateTime) {
+	p.Set("expires_at", expiresAt)
+}
+
+func (p *CreditOverrides) UsedAt() types.DateTime {
+	return p.GetDateTime("used_at")
+}
+
+func (p *CreditOverrides) SetUsedAt(usedAt types.DateTime) {
+	p.Set("used_at", usedAt)
+}
+
+func (p *CreditOverrides) Sale() *SalesTransactions {
+	var proxy *SalesTransactions
+	if rel := p.ExpandedOne("sale"); rel != nil {
+		proxy = &SalesTransactions{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CreditOverrides) SetSale(sale *SalesTransactions) {
+	var id string
+	if sale != nil {
+		id = sale.Id
+	}
+	p.Record.Set("sale", id)
+	e := p.Expand()
+	if sale != nil {
+		e["sale"] = sale.Record
+	} else {
+		delete(e, "sale")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CreditOverrides) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *CreditOverrides) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *CreditOverrides) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *CreditOverrides) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type DocumentTypeSelectType int
+
+const (
+	DocumentSale DocumentTypeSelectType = iota
+	DocumentInvoice
+	DocumentPurchaseOrder
+	DocumentGoodsReceivedNote
+	DocumentCreditNote
+)
+
+var zzDocumentTypeSelectTypeSelectNameMap = map[string]DocumentTypeSelectType{
+	"sale":                0,
+	"invoice":             1,
+	"purchase_order":      2,
+	"goods_received_note": 3,
+	"credit_note":         4,
+}
+var zzDocumentTypeSelectTypeSelectIotaMap = map[DocumentTypeSelectType]string{
+	0: "sale",
+	1: "invoice",
+	2: "purchase_order",
+	3: "goods_received_note",
+	4: "credit_note",
+}
+
+type DocumentSequences struct {
+	core.BaseRecordProxy
+}
+
+func (p *DocumentSequences) CollectionName() string {
+	return "document_sequences"
+}
+
+func (p *DocumentSequences) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *DocumentSequences) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *DocumentSequences) DocumentType() DocumentTypeSelectType {
+	option := p.GetString("document_type")
+	i, ok := zzDocumentTypeSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *DocumentSequences) SetDocumentType(documentType DocumentTypeSelectType) {
+	i, ok := zzDocumentTypeSelectTypeSelectIotaMap[documentType]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("document_type", i)
+}
+
+func (p *DocumentSequences) Prefix() string {
+	return p.GetString("prefix")
+}
+
+func (p *DocumentSequences) SetPrefix(prefix string) {
+	p.Set("prefix", prefix)
+}
+
+func (p *DocumentSequences) ResetYearly() bool {
+	return p.GetBool("reset_yearly")
+}
+
+func (p *DocumentSequences) SetResetYearly(resetYearly bool) {
+	p.Set("reset_yearly", resetYearly)
+}
+
+func (p *DocumentSequences) Padding() float64 {
+	return p.GetFloat("padding")
+}
+
+func (p *DocumentSequences) SetPadding(padding float64) {
+	p.Set("padding", padding)
+}
+
+func (p *DocumentSequences) Year() float64 {
+	return p.GetFloat("year")
+}
+
+func (p *DocumentSequences) SetYear(year float64) {
+	p.Set("year", year)
+}
+
+func (p *DocumentSequences) NextNumber() float64 {
+	return p.GetFloat("next_number")
+}
+
+func (p *DocumentSequences) SetNextNumber(nextNumber float64) {
+	p.Set("next_number", nextNumber)
+}
+
+func (p *DocumentSequences) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *DocumentSequences) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *DocumentSequences) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *DocumentSequences) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type AuditLogs struct {
+	core.BaseRecordProxy
+}
+
+func (p *AuditLogs) CollectionName() string {
+	return "audit_logs"
+}
+
+func (p *AuditLogs) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *AuditLogs) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *AuditLogs) User() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("user"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *AuditLogs) SetUser(user *Users) {
+	var id string
+	if user != nil {
+		id = user.Id
+	}
+	p.Record.Set("user", id)
+	e := p.Expand()
+	if user != nil {
+		e["user"] = user.Record
+	} else {
+		delete(e, "user")
+	}
+	p.SetExpand(e)
+}
+
+func (p *AuditLogs) Action() string {
+	return p.GetString("action")
+}
+
+func (p *AuditLogs) SetAction(action string) {
+	p.Set("action", action)
+}
+
+func (p *AuditLogs) RecordCollection() string {
+	return p.GetString("record_collection")
+}
+
+func (p *AuditLogs) SetRecordCollection(recordCollection string) {
+	p.Set("record_collection", recordCollection)
+}
+
+func (p *AuditLogs) RecordId() string {
+	return p.GetString("record_id")
+}
+
+func (p *AuditLogs) SetRecordId(recordId string) {
+	p.Set("record_id", recordId)
+}
+
+func (p *AuditLogs) Details() string {
+	return p.GetString("details")
+}
+
+func (p *AuditLogs) SetDetails(details string) {
+	p.Set("details", details)
+}
+
+func (p *AuditLogs) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *AuditLogs) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *AuditLogs) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *AuditLogs) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type LoyaltyPrograms struct {
+	core.BaseRecordProxy
+}
+
+func (p *LoyaltyPrograms) CollectionName() string {
+	return "loyalty_programs"
+}
+
+func (p *LoyaltyPrograms) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LoyaltyPrograms) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LoyaltyPrograms) Active() bool {
+	return p.GetBool("active")
+}
+
+func (p *LoyaltyPrograms) SetActive(active bool) {
+	p.Set("active", active)
+}
+
+func (p *LoyaltyPrograms) EarnRate() float64 {
+	return p.GetFloat("earn_rate")
+}
+
+func (p *LoyaltyPrograms) SetEarnRate(earnRate float64) {
+	p.Set("earn_rate", earnRate)
+}
+
+func (p *LoyaltyPrograms) PointValue() float64 {
+	return p.GetFloat("point_value")
+}
+
+func (p *LoyaltyPrograms) SetPointValue(pointValue float64) {
+	p.Set("point_value", pointValue)
+}
+
+func (p *LoyaltyPrograms) ExpiryDays() float64 {
+	return p.GetFloat("expiry_days")
+}
+
+func (p *LoyaltyPrograms) SetExpiryDays(expiryDays float64) {
+	p.Set("expiry_days", expiryDays)
+}
+
+func (p *LoyaltyPrograms) MinRedeemPoints() float64 {
+	return p.GetFloat("min_redeem_points")
+}
+
+func (p *LoyaltyPrograms) SetMinRedeemPoints(minRedeemPoints float64) {
+	p.Set("min_redeem_points", minRedeemPoints)
+}
+
+func (p *LoyaltyPrograms) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *LoyaltyPrograms) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *LoyaltyPrograms) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *LoyaltyPrograms) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type LoyaltyEntryTypeSelectType int
+
+const (
+	LoyaltyEarn LoyaltyEntryTypeSelectType = iota
+	LoyaltyRedeem
+	LoyaltyExpire
+	LoyaltyClawback
+)
+
+var zzLoyaltyEntryTypeSelectTypeSelectNameMap = map[string]LoyaltyEntryTypeSelectType{
+	"earn":     0,
+	"redeem":   1,
+	"expire":   2,
+	"clawback": 3,
+}
+var zzLoyaltyEntryTypeSelectTypeSelectIotaMap = map[LoyaltyEntryTypeSelectType]string{
+	0: "earn",
+	1: "redeem",
+	2: "expire",
+	3: "clawback",
+}
+
+type LoyaltyEntries struct {
+	core.BaseRecordProxy
+}
+
+func (p *LoyaltyEntries) CollectionName() string {
+	return "loyalty_entries"
+}
+
+func (p *LoyaltyEntries) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LoyaltyEntries) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LoyaltyEntries) Partner() *Partners {
+	var proxy *Partners
+	if rel := p.ExpandedOne("partner"); rel != nil {
+		proxy = &Partners{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LoyaltyEntries) SetPartner(partner *Partners) {
+	var id string
+	if partner != nil {
+		id = partner.Id
+	}
+	p.Record.Set("partner", id)
+	e := p.Expand()
+	if partner != nil {
+		e["partner"] = partner.Record
+	} else {
+		delete(e, "partner")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LoyaltyEntries) Sale() *SalesTransactions {
+	var proxy *SalesTransactions
+	if rel := p.ExpandedOne("sale"); rel != nil {
+		proxy = &SalesTransactions{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LoyaltyEntries) SetSale(sale *SalesTransactions) {
+	var id string
+	if sale != nil {
+		id = sale.Id
+	}
+	p.Record.Set("sale", id)
+	e := p.Expand()
+	if sale != nil {
+		e["sale"] = sale.Record
+	} else {
+		delete(e, "sale")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LoyaltyEntries) Type() LoyaltyEntryTypeSelectType {
+	option := p.GetString("type")
+	i, ok := zzLoyaltyEntryTypeSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *LoyaltyEntries) SetType(type_ LoyaltyEntryTypeSelectType) {
+	i, ok := zzLoyaltyEntryTypeSelectTypeSelectIotaMap[type_]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("type", i)
+}
+
+func (p *LoyaltyEntries) Points() float64 {
+	return p.GetFloat("points")
+}
+
+func (p *LoyaltyEntries) SetPoints(points float64) {
+	p.Set("points", points)
+}
+
+func (p *LoyaltyEntries) Amount() float64 {
+	return p.GetFloat("amount")
+}
+
+func (p *LoyaltyEntries) SetAmount(amount float64) {
+	p.Set("amount", amount)
+}
+
+func (p *LoyaltyEntries) Remaining() float64 {
+	return p.GetFloat("remaining")
+}
+
+func (p *LoyaltyEntries) SetRemaining(remaining float64) {
+	p.Set("remaining", remaining)
+}
+
+func (p *LoyaltyEntries) ExpiresAt() types.DateTime {
+	return p.GetDateTime("expires_at")
+}
+
+func (p *LoyaltyEntries) SetExpiresAt(expiresAt types.DateTime) {
+	p.Set("expires_at", expiresAt)
+}
+
+func (p *LoyaltyEntries) Lot() *LoyaltyEntries {
+	var proxy *LoyaltyEntries
+	if rel := p.ExpandedOne("lot"); rel != nil {
+		proxy = &LoyaltyEntries{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *LoyaltyEntries) SetLot(lot *LoyaltyEntries) {
+	var id string
+	if lot != nil {
+		id = lot.Id
+	}
+	p.Record.Set("lot", id)
+	e := p.Expand()
+	if lot != nil {
+		e["lot"] = lot.Record
+	} else {
+		delete(e, "lot")
+	}
+	p.SetExpand(e)
+}
+
+func (p *LoyaltyEntries) Date() types.DateTime {
+	return p.GetDateTime("date")
+}
+
+func (p *LoyaltyEntries) SetDate(date types.DateTime) {
+	p.Set("date", date)
+}
+
+func (p *LoyaltyEntries) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *LoyaltyEntries) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *LoyaltyEntries) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *LoyaltyEntries) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type NotificationChannelSelectType int
+
+const (
+	NotificationSMS NotificationChannelSelectType = iota
+	NotificationEmail
+)
+
+var zzNotificationChannelSelectTypeSelectNameMap = map[string]NotificationChannelSelectType{
+	"sms":   0,
+	"email": 1,
+}
+var zzNotificationChannelSelectTypeSelectIotaMap = map[NotificationChannelSelectType]string{
+	0: "sms",
+	1: "email",
+}
+
+type NotificationStatusSelectType int
+
+const (
+	NotificationPending NotificationStatusSelectType = iota
+	NotificationSending
+	NotificationSent
+	NotificationFailed
+)
+
+var zzNotificationStatusSelectTypeSelectNameMap = map[string]NotificationStatusSelectType{
+	"pending": 0,
+	"sending": 1,
+	"sent":    2,
+	"failed":  3,
+}
+var zzNotificationStatusSelectTypeSelectIotaMap = map[NotificationStatusSelectType]string{
+	0: "pending",
+	1: "sending",
+	2: "sent",
+	3: "failed",
+}
+
+type Notifications struct {
+	core.BaseRecordProxy
+}
+
+func (p *Notifications) CollectionName() string {
+	return "notifications"
+}
+
+func (p *Notifications) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *Notifications) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *Notifications) Channel() NotificationChannelSelectType {
+	option := p.GetString("channel")
+	i, ok := zzNotificationChannelSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *Notifications) SetChannel(channel NotificationChannelSelectType) {
+	i, ok := zzNotificationChannelSelectTypeSelectIotaMap[channel]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("channel", i)
+}
+
+func (p *Notifications) Recipient() string {
+	return p.GetString("recipient")
+}
+
+func (p *Notifications) SetRecipient(recipient string) {
+	p.Set("recipient", recipient)
+}
+
+func (p *Notifications) Subject() string {
+	return p.GetString("subject")
+}
+
+func (p *Notifications) SetSubject(subject string) {
+	p.Set("subject", subject)
+}
+
+func (p *Notifications) Body() string {
+	return p.GetString("body")
+}
+
+func (p *Notifications) SetBody(body string) {
+	p.Set("body", body)
+}
+
+func (p *Notifications) Html() bool {
+	return p.GetBool("html")
+}
+
+func (p *Notifications) SetHtml(html bool) {
+	p.Set("html", html)
+}
+
+func (p *Notifications) Kind() string {
+	return p.GetString("kind")
+}
+
+func (p *Notifications) SetKind(kind string) {
+	p.Set("kind", kind)
+}
+
+func (p *Notifications) ReferenceId() string {
+	return p.GetString("reference_id")
+}
+
+func (p *Notifications) SetReferenceId(referenceId string) {
+	p.Set("reference_id", referenceId)
+}
+
+func (p *Notifications) Status() NotificationStatusSelectType {
+	option := p.GetString("status")
+	i, ok := zzNotificationStatusSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *Notifications) SetStatus(status NotificationStatusSelectType) {
+	i, ok := zzNotificationStatusSelectTypeSelectIotaMap[status]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("status", i)
+}
+
+func (p *Notifications) Attempts() float64 {
+	return p.GetFloat("attempts")
+}
+
+func (p *Notifications) SetAttempts(attempts float64) {
+	p.Set("attempts", attempts)
+}
+
+func (p *Notifications) NextAttemptAt() types.DateTime {
+	return p.GetDateTime("next_attempt_at")
+}
+
+func (p *Notifications) SetNextAttemptAt(nextAttemptAt types.DateTime) {
+	p.Set("next_attempt_at", nextAttemptAt)
+}
+
+func (p *Notifications) LastError() string {
+	return p.GetString("last_error")
+}
+
+func (p *Notifications) SetLastError(lastError string) {
+	p.Set("last_error", lastError)
+}
+
+func (p *Notifications) Provider() string {
+	return p.GetString("provider")
+}
+
+func (p *Notifications) SetProvider(provider string) {
+	p.Set("provider", provider)
+}
+
+func (p *Notifications) ProviderMessageId() string {
+	return p.GetString("provider_message_id")
+}
+
+func (p *Notifications) SetProviderMessageId(providerMessageId string) {
+	p.Set("provider_message_id", providerMessageId)
+}
+
+func (p *Notifications) SentAt() types.DateTime {
+	return p.GetDateTime("sent_at")
+}
+
+func (p *Notifications) SetSentAt(sentAt types.DateTime) {
+	p.Set("sent_at", sentAt)
+}
+
+func (p *Notifications) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *Notifications) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *Notifications) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *Notifications) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type CommissionTypeSelectType int
+
+const (
+	CommissionPercentage CommissionTypeSelectType = iota
+	CommissionFlat
+)
+
+var zzCommissionTypeSelectTypeSelectNameMap = map[string]CommissionTypeSelectType{
+	"percentage": 0,
+	"flat":       1,
+}
+var zzCommissionTypeSelectTypeSelectIotaMap = map[CommissionTypeSelectType]string{
+	0: "percentage",
+	1: "flat",
+}
+
+type CommissionRules struct {
+	core.BaseRecordProxy
+}
+
+func (p *CommissionRules) CollectionName() string {
+	return "commission_rules"
+}
+
+func (p *CommissionRules) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionRules) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionRules) Name() string {
+	return p.GetString("name")
+}
+
+func (p *CommissionRules) SetName(name string) {
+	p.Set("name", name)
+}
+
+func (p *CommissionRules) Product() *Products {
+	var proxy *Products
+	if rel := p.ExpandedOne("product"); rel != nil {
+		proxy = &Products{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionRules) SetProduct(product *Products) {
+	var id string
+	if product != nil {
+		id = product.Id
+	}
+	p.Record.Set("product", id)
+	e := p.Expand()
+	if product != nil {
+		e["product"] = product.Record
+	} else {
+		delete(e, "product")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionRules) Category() *ProductCategories {
+	var proxy *ProductCategories
+	if rel := p.ExpandedOne("category"); rel != nil {
+		proxy = &ProductCategories{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionRules) SetCategory(category *ProductCategories) {
+	var id string
+	if category != nil {
+		id = category.Id
+	}
+	p.Record.Set("category", id)
+	e := p.Expand()
+	if category != nil {
+		e["category"] = category.Record
+	} else {
+		delete(e, "category")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionRules) Type() CommissionTypeSelectType {
+	option := p.GetString("type")
+	i, ok := zzCommissionTypeSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *CommissionRules) SetType(type_ CommissionTypeSelectType) {
+	i, ok := zzCommissionTypeSelectTypeSelectIotaMap[type_]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("type", i)
+}
+
+func (p *CommissionRules) Rate() float64 {
+	return p.GetFloat("rate")
+}
+
+func (p *CommissionRules) SetRate(rate float64) {
+	p.Set("rate", rate)
+}
+
+func (p *CommissionRules) Active() bool {
+	return p.GetBool("active")
+}
+
+func (p *CommissionRules) SetActive(active bool) {
+	p.Set("active", active)
+}
+
+func (p *CommissionRules) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *CommissionRules) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *CommissionRules) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *CommissionRules) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type CommissionStatementStatusSelectType int
+
+const (
+	CommissionPayable CommissionStatementStatusSelectType = iota
+	CommissionPaid
+)
+
+var zzCommissionStatementStatusSelectTypeSelectNameMap = map[string]CommissionStatementStatusSelectType{
+	"payable": 0,
+	"paid":    1,
+}
+var zzCommissionStatementStatusSelectTypeSelectIotaMap = map[CommissionStatementStatusSelectType]string{
+	0: "payable",
+	1: "paid",
+}
+
+type CommissionStatements struct {
+	core.BaseRecordProxy
+}
+
+func (p *CommissionStatements) CollectionName() string {
+	return "commission_statements"
+}
+
+func (p *CommissionStatements) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionStatements) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionStatements) Salesperson() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("salesperson"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionStatements) SetSalesperson(salesperson *Users) {
+	var id string
+	if salesperson != nil {
+		id = salesperson.Id
+	}
+	p.Record.Set("salesperson", id)
+	e := p.Expand()
+	if salesperson != nil {
+		e["salesperson"] = salesperson.Record
+	} else {
+		delete(e, "salesperson")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionStatements) PeriodStart() types.DateTime {
+	return p.GetDateTime("period_start")
+}
+
+func (p *CommissionStatements) SetPeriodStart(periodStart types.DateTime) {
+	p.Set("period_start", periodStart)
+}
+
+func (p *CommissionStatements) PeriodEnd() types.DateTime {
+	return p.GetDateTime("period_end")
+}
+
+func (p *CommissionStatements) SetPeriodEnd(periodEnd types.DateTime) {
+	p.Set("period_end", periodEnd)
+}
+
+func (p *CommissionStatements) Sales() float64 {
+	return p.GetFloat("sales")
+}
+
+func (p *CommissionStatements) SetSales(sales float64) {
+	p.Set("sales", sales)
+}
+
+func (p *CommissionStatements) Revenue() float64 {
+	return p.GetFloat("revenue")
+}
+
+func (p *CommissionStatements) SetRevenue(revenue float64) {
+	p.Set("revenue", revenue)
+}
+
+func (p *CommissionStatements) Commission() float64 {
+	return p.GetFloat("commission")
+}
+
+func (p *CommissionStatements) SetCommission(commission float64) {
+	p.Set("commission", commission)
+}
+
+func (p *CommissionStatements) Lines() string {
+	return p.GetString("lines")
+}
+
+func (p *CommissionStatements) SetLines(lines string) {
+	p.Set("lines", lines)
+}
+
+func (p *CommissionStatements) Status() CommissionStatementStatusSelectType {
+	option := p.GetString("status")
+	i, ok := zzCommissionStatementStatusSelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *CommissionStatements) SetStatus(status CommissionStatementStatusSelectType) {
+	i, ok := zzCommissionStatementStatusSelectTypeSelectIotaMap[status]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("status", i)
+}
+
+func (p *CommissionStatements) PaidAt() types.DateTime {
+	return p.GetDateTime("paid_at")
+}
+
+func (p *CommissionStatements) SetPaidAt(paidAt types.DateTime) {
+	p.Set("paid_at", paidAt)
+}
+
+func (p *CommissionStatements) PaidBy() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("paid_by"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *CommissionStatements) SetPaidBy(paidBy *Users) {
+	var id string
+	if paidBy != nil {
+		id = paidBy.Id
+	}
+	p.Record.Set("paid_by", id)
+	e := p.Expand()
+	if paidBy != nil {
+		e["paid_by"] = paidBy.Record
+	} else {
+		delete(e, "paid_by")
+	}
+	p.SetExpand(e)
+}
+
+func (p *CommissionStatements) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *CommissionStatements) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *CommissionStatements) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *CommissionStatements) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
+
+type DigestFrequencySelectType int
+
+const (
+	DigestDaily DigestFrequencySelectType = iota
+	DigestWeekly
+)
+
+var zzDigestFrequencySelectTypeSelectNameMap = map[string]DigestFrequencySelectType{
+	"daily":  0,
+	"weekly": 1,
+}
+var zzDigestFrequencySelectTypeSelectIotaMap = map[DigestFrequencySelectType]string{
+	0: "daily",
+	1: "weekly",
+}
+
+type DigestSubscriptions struct {
+	core.BaseRecordProxy
+}
+
+func (p *DigestSubscriptions) CollectionName() string {
+	return "digest_subscriptions"
+}
+
+func (p *DigestSubscriptions) User() *Users {
+	var proxy *Users
+	if rel := p.ExpandedOne("user"); rel != nil {
+		proxy = &Users{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *DigestSubscriptions) SetUser(user *Users) {
+	var id string
+	if user != nil {
+		id = user.Id
+	}
+	p.Record.Set("user", id)
+	e := p.Expand()
+	if user != nil {
+		e["user"] = user.Record
+	} else {
+		delete(e, "user")
+	}
+	p.SetExpand(e)
+}
+
+func (p *DigestSubscriptions) Company() *Companies {
+	var proxy *Companies
+	if rel := p.ExpandedOne("company"); rel != nil {
+		proxy = &Companies{}
+		proxy.Record = rel
+	}
+	return proxy
+}
+
+func (p *DigestSubscriptions) SetCompany(company *Companies) {
+	var id string
+	if company != nil {
+		id = company.Id
+	}
+	p.Record.Set("company", id)
+	e := p.Expand()
+	if company != nil {
+		e["company"] = company.Record
+	} else {
+		delete(e, "company")
+	}
+	p.SetExpand(e)
+}
+
+func (p *DigestSubscriptions) Frequency() DigestFrequencySelectType {
+	option := p.GetString("frequency")
+	i, ok := zzDigestFrequencySelectTypeSelectNameMap[option]
+	if !ok {
+		panic("Unknown select value")
+	}
+	return i
+}
+
+func (p *DigestSubscriptions) SetFrequency(frequency DigestFrequencySelectType) {
+	i, ok := zzDigestFrequencySelectTypeSelectIotaMap[frequency]
+	if !ok {
+		panic("Unknown select value")
+	}
+	p.Set("frequency", i)
+}
+
+func (p *DigestSubscriptions) Email() string {
+	return p.GetString("email")
+}
+
+func (p *DigestSubscriptions) SetEmail(email string) {
+	p.Set("email", email)
+}
+
+func (p *DigestSubscriptions) Active() bool {
+	return p.GetBool("active")
+}
+
+func (p *DigestSubscriptions) SetActive(active bool) {
+	p.Set("active", active)
+}
+
+func (p *DigestSubscriptions) LastSentAt() types.DateTime {
+	return p.GetDateTime("last_sent_at")
+}
+
+func (p *DigestSubscriptions) SetLastSentAt(lastSentAt types.DateTime) {
+	p.Set("last_sent_at", lastSentAt)
+}
+
+func (p *DigestSubscriptions) Created() types.DateTime {
+	return p.GetDateTime("created")
+}
+
+func (p *DigestSubscriptions) SetCreated(created types.DateTime) {
+	p.Set("created", created)
+}
+
+func (p *DigestSubscriptions) Updated() types.DateTime {
+	return p.GetDateTime("updated")
+}
+
+func (p *DigestSubscriptions) SetUpdated(updated types.DateTime) {
+	p.Set("updated", updated)
+}
@@ -142,6 +142,12 @@ type Partners struct {
 	balance float64
 	created types.DateTime
 	updated types.DateTime
+	credit  float64
+	// select: PartnerRoleSelectType(customer, supplier, both)[PartnerCustomer, PartnerSupplier, PartnerBoth]
+	role               int
+	credit_limit       float64
+	payment_terms_days float64
+	loyalty_points     float64
 }
 
 type Invoices struct {
@@ -156,27 +162,37 @@ type Invoices struct {
 	company *Companies
 	user    *Users
 	// select: TypeSelectType(sale, purchase)
-	type_        int
-	transactions []*Transactions
-	date         types.DateTime
-	created      types.DateTime
-	updated      types.DateTime
+	type_         int
+	transactions  []*Transactions
+	date          types.DateTime
+	created       types.DateTime
+	updated       types.DateTime
+	currency      string
+	exchange_rate float64
+	realised_fx   float64
+	due_date      types.DateTime
+	number        string
+	sales         []*SalesTransactions
+	sent_at       types.DateTime
 }
 
 type Purchases struct {
 	// collection-name: purchases
 	// system: id
-	Id          string
-	product     *Products
-	quantity    float64
-	sku         *Skus
-	company     *Companies
-	user        *Users
-	invoice     *Invoices
-	transaction *Transactions
-	date        types.DateTime
-	created     types.DateTime
-	updated     types.DateTime
+	Id            string
+	product       *Products
+	quantity      float64
+	sku           *Skus
+	company       *Companies
+	user          *Users
+	invoice       *Invoices
+	transaction   *Transactions
+	date          types.DateTime
+	created       types.DateTime
+	updated       types.DateTime
+	currency      string
+	exchange_rate float64
+	unit_cost     float64
 }
 
 type Companies struct {
@@ -199,6 +215,8 @@ type Companies struct {
 	deleted_at           types.DateTime
 	created              types.DateTime
 	updated              types.DateTime
+	base_currency        string
+	timezone             string
 }
 
 type CompanyAccounts struct {
@@ -217,6 +235,7 @@ type CompanyAccounts struct {
 	total_expenses float64
 	net_profit     float64
 	deleted_at     types.DateTime
+	currency       string
 }
 
 type Transactions struct {
@@ -231,13 +250,17 @@ type Transactions struct {
 	transaction_id string
 	date           types.DateTime
 	author         *Users
-	// select: ReferenceTypeSelectType(sale, purchase, expense, adjustment)
-	reference_type int
-	reference_id   string
-	tax_rate       float64
-	tax_amount     float64
-	created        types.DateTime
-	updated        types.DateTime
+	// select: ReferenceTypeSelectType(sale, purchase, expense, adjustment, transfer)
+	reference_type      int
+	reference_id        string
+	tax_rate            float64
+	tax_amount          float64
+	created             types.DateTime
+	updated             types.DateTime
+	currency            string
+	original_amount     float64
+	original_tax_amount float64
+	exchange_rate       float64
 }
 
 type SalesDetails struct {
@@ -321,11 +344,18 @@ type SalesTransactions struct {
 	tax_amount       float64
 	discount_amount  float64
 	// select: PaymentMethodSelectType(cash, card, mobile_money, bank_transfer)
-	payment_method   int
-	shipping_address string
-	deleted_at       types.DateTime
-	created          types.DateTime
-	updated          types.DateTime
+	payment_method          int
+	shipping_address        string
+	deleted_at              types.DateTime
+	created                 types.DateTime
+	updated                 types.DateTime
+	currency                string
+	exchange_rate           float64
+	credit_override         *CreditOverrides
+	number                  string
+	loyalty_points_redeemed float64
+	loyalty_amount          float64
+	return_of               *SalesTransactions
 }
 
 type Admins struct {
@@ -407,6 +437,7 @@ type InventoryTransactions struct {
 	user           *Users
 	created        types.DateTime
 	updated        types.DateTime
+	company        *Companies
 }
 
 type ProductAnalytics struct {
@@ -423,4 +454,280 @@ type ProductAnalytics struct {
 	avg_selling_price float64
 	created           types.DateTime
 	updated           types.DateTime
+	// select: AnalyticsPeriodTypeSelectType(day, week, month)[AnalyticsDay, AnalyticsWeek, AnalyticsMonth]
+	period_type  int
+	period_start types.DateTime
+}
+
+type AccountingPeriods struct {
+	// collection-name: accounting_periods
+	// system: id
+	Id         string
+	company    *Companies
+	name       string
+	start_date types.DateTime
+	end_date   types.DateTime
+	// select: PeriodStatusSelectType(open, closed)[PeriodOpen, PeriodClosed]
+	status        int
+	closed_at     types.DateTime
+	closed_by     *Admins
+	close_reason  string
+	reopened_at   types.DateTime
+	reopened_by   *Admins
+	reopen_reason string
+	created       types.DateTime
+	updated       types.DateTime
+}
+
+type LedgerAccountMappings struct {
+	// collection-name: ledger_account_mappings
+	// system: id
+	Id      string
+	company *Companies
+	// select: LedgerSystemSelectType(generic, quickbooks, xero)[LedgerGeneric, LedgerQuickBooks, LedgerXero]
+	system          int
+	company_account *CompanyAccounts
+	account_type    *AccountTypes
+	// select: ContraTypeSelectType(sale, purchase, expense, adjustment, tax, transfer)[ContraSale, ContraPurchase, ContraExpense, ContraAdjustment, ContraTax, ContraTransfer]
+	reference_type int
+	external_code  string
+	external_name  string
+	tax_code       string
+	created        types.DateTime
+	updated        types.DateTime
+}
+
+type ExchangeRates struct {
+	// collection-name: exchange_rates
+	// system: id
+	Id             string
+	company        *Companies
+	currency       string
+	rate           float64
+	effective_date types.DateTime
+	created        types.DateTime
+	updated        types.DateTime
+}
+
+type PurchaseOrders struct {
+	// collection-name: purchase_orders
+	// system: id
+	Id       string
+	company  *Companies
+	supplier *Partners
+	number   string
+	// select: PurchaseOrderStatusSelectType(open, partial, received, cancelled)[OrderOpen, OrderPartial, OrderReceived, OrderCancelled]
+	status        int
+	order_date    types.DateTime
+	expected_date types.DateTime
+	currency      string
+	total         float64
+	notes         string
+	user          *Users
+	created       types.DateTime
+	updated       types.DateTime
+}
+
+type PurchaseOrderLines struct {
+	// collection-name: purchase_order_lines
+	// system: id
+	Id                string
+	purchase_order    *PurchaseOrders
+	product           *Products
+	sku               *Skus
+	quantity          float64
+	unit_price        float64
+	received_quantity float64
+	created           types.DateTime
+	updated           types.DateTime
+}
+
+type GoodsReceivedNotes struct {
+	// collection-name: goods_received_notes
+	// system: id
+	Id             string
+	company        *Companies
+	purchase_order *PurchaseOrders
+	supplier       *Partners
+	number         string
+	date           types.DateTime
+	invoice        *Invoices
+	has_variance   bool
+	notes          string
+	user           *Users
+	created        types.DateTime
+	updated        types.DateTime
+}
+
+type GoodsReceivedLines struct {
+	// collection-name: goods_received_lines
+	// system: id
+	Id                  string
+	goods_received_note *GoodsReceivedNotes
+	purchase_order_line *PurchaseOrderLines
+	product             *Products
+	sku                 *Skus
+	quantity            float64
+	expected_price      float64
+	unit_price          float64
+	price_variance      float64
+	variance_flag       bool
+	purchase            *Purchases
+	created             types.DateTime
+	updated             types.DateTime
+}
+
+type CreditOverrides struct {
+	// collection-name: credit_overrides
+	// system: id
+	Id          string
+	company     *Companies
+	partner     *Partners
+	approved_by *Users
+	amount      float64
+	reason      string
+	expires_at  types.DateTime
+	used_at     types.DateTime
+	sale        *SalesTransactions
+	created     types.DateTime
+	updated     types.DateTime
+}
+
+type DocumentSequences struct {
+	// collection-name: document_sequences
+	// system: id
+	Id      string
+	company *Companies
+	// select: DocumentTypeSelectType(sale, invoice, purchase_order, goods_received_note, credit_note)[DocumentSale, DocumentInvoice, DocumentPurchaseOrder, DocumentGoodsReceivedNote, DocumentCreditNote]
+	document_type int
+	prefix        string
+	reset_yearly  bool
+	padding       float64
+	year          float64
+	next_number   float64
+	created       types.DateTime
+	updated       types.DateTime
+}
+
+type AuditLogs struct {
+	// collection-name: audit_logs
+	// system: id
+	Id                string
+	company           *Companies
+	user              *Users
+	action            string
+	record_collection string
+	record_id         string
+	details           string
+	created           types.DateTime
+	updated           types.DateTime
+}
+
+type LoyaltyPrograms struct {
+	// collection-name: loyalty_programs
+	// system: id
+	Id                string
+	company           *Companies
+	active            bool
+	earn_rate         float64
+	point_value       float64
+	expiry_days       float64
+	min_redeem_points float64
+	created           types.DateTime
+	updated           types.DateTime
+}
+
+type LoyaltyEntries struct {
+	// collection-name: loyalty_entries
+	// system: id
+	Id      string
+	company *Companies
+	partner *Partners
+	sale    *SalesTransactions
+	// select: LoyaltyEntryTypeSelectType(earn, redeem, expire, clawback)[LoyaltyEarn, LoyaltyRedeem, LoyaltyExpire, LoyaltyClawback]
+	type_      int
+	points     float64
+	amount     float64
+	remaining  float64
+	expires_at types.DateTime
+	lot        *LoyaltyEntries
+	date       types.DateTime
+	created    types.DateTime
+	updated    types.DateTime
+}
+
+type Notifications struct {
+	// collection-name: notifications
+	// system: id
+	Id      string
+	company *Companies
+	// select: NotificationChannelSelectType(sms, email)[NotificationSMS, NotificationEmail]
+	channel      int
+	recipient    string
+	subject      string
+	body         string
+	html         bool
+	kind         string
+	reference_id string
+	// select: NotificationStatusSelectType(pending, sending, sent, failed)[NotificationPending, NotificationSending, NotificationSent, NotificationFailed]
+	status              int
+	attempts            float64
+	next_attempt_at     types.DateTime
+	last_error          string
+	provider            string
+	provider_message_id string
+	sent_at             types.DateTime
+	created             types.DateTime
+	updated             types.DateTime
+}
+
+type CommissionRules struct {
+	// collection-name: commission_rules
+	// system: id
+	Id       string
+	company  *Companies
+	name     string
+	product  *Products
+	category *ProductCategories
+	// select: CommissionTypeSelectType(percentage, flat)[CommissionPercentage, CommissionFlat]
+	type_   int
+	rate    float64
+	active  bool
+	created types.DateTime
+	updated types.DateTime
+}
+
+type CommissionStatements struct {
+	// collection-name: commission_statements
+	// system: id
+	Id           string
+	company      *Companies
+	salesperson  *Users
+	period_start types.DateTime
+	period_end   types.DateTime
+	sales        float64
+	revenue      float64
+	commission   float64
+	lines        string
+	// select: CommissionStatementStatusSelectType(payable, paid)[CommissionPayable, CommissionPaid]
+	status  int
+	paid_at types.DateTime
+	paid_by *Users
+	created types.DateTime
+	updated types.DateTime
+}
+
+type DigestSubscriptions struct {
+	// collection-name: digest_subscriptions
+	// system: id
+	Id      string
+	user    *Users
+	company *Companies
+	// select: DigestFrequencySelectType(daily, weekly)[DigestDaily, DigestWeekly]
+	frequency    int
+	email        string
+	active       bool
+	last_sent_at types.DateTime
+	created      types.DateTime
+	updated      types.DateTime
 }
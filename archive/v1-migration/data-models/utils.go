@@ -8,7 +8,7 @@ import (
 )
 
 type Proxy interface {
-	Users | DailyStockTakes | DailyAccounts | AccountTypes | Skus | Products | Partners | Invoices | Purchases | Companies | CompanyAccounts | Transactions | SalesDetails | Expenses | OpenCloseDetails | Models | ProductCategories | SalesTransactions | Admins | JobQueue | DailySummaries | Inventory | InventoryTransactions | ProductAnalytics
+	Users | DailyStockTakes | DailyAccounts | AccountTypes | Skus | Products | Partners | Invoices | Purchases | Companies | CompanyAccounts | Transactions | SalesDetails | Expenses | OpenCloseDetails | Models | ProductCategories | SalesTransactions | Admins | JobQueue | DailySummaries | Inventory | InventoryTransactions | ProductAnalytics | AccountingPeriods | LedgerAccountMappings | ExchangeRates | PurchaseOrders | PurchaseOrderLines | GoodsReceivedNotes | GoodsReceivedLines | CreditOverrides | DocumentSequences | AuditLogs | LoyaltyPrograms | LoyaltyEntries | Notifications | CommissionRules | CommissionStatements | DigestSubscriptions
 }
 
 // This interface constrains a type parameter of
@@ -162,6 +162,9 @@ var Relations = map[string]map[string][]RelationField{
 		"transactions": {
 			{"transactions", true},
 		},
+		"sales_transactions": {
+			{"sales", true},
+		},
 	},
 	"purchases": {
 		"users": {
@@ -254,6 +257,12 @@ var Relations = map[string]map[string][]RelationField{
 		"sales_details": {
 			{"sales_details", true},
 		},
+		"sales_transactions": {
+			{"return_of", false},
+		},
+		"credit_overrides": {
+			{"credit_override", false},
+		},
 	},
 	"job_queue": {
 		"users": {
@@ -289,6 +298,9 @@ var Relations = map[string]map[string][]RelationField{
 		"products": {
 			{"product", false},
 		},
+		"companies": {
+			{"company", false},
+		},
 	},
 	"product_analytics": {
 		"products": {
@@ -298,4 +310,164 @@ var Relations = map[string]map[string][]RelationField{
 			{"company", false},
 		},
 	},
+	"accounting_periods": {
+		"companies": {
+			{"company", false},
+		},
+		"admins": {
+			{"closed_by", false},
+			{"reopened_by", false},
+		},
+	},
+	"ledger_account_mappings": {
+		"account_types": {
+			{"account_type", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+		"company_accounts": {
+			{"company_account", false},
+		},
+	},
+	"exchange_rates": {
+		"companies": {
+			{"company", false},
+		},
+	},
+	"purchase_orders": {
+		"users": {
+			{"user", false},
+		},
+		"partners": {
+			{"supplier", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+	},
+	"purchase_order_lines": {
+		"skus": {
+			{"sku", false},
+		},
+		"products": {
+			{"product", false},
+		},
+		"purchase_orders": {
+			{"purchase_order", false},
+		},
+	},
+	"goods_received_notes": {
+		"users": {
+			{"user", false},
+		},
+		"partners": {
+			{"supplier", false},
+		},
+		"invoices": {
+			{"invoice", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+		"purchase_orders": {
+			{"purchase_order", false},
+		},
+	},
+	"goods_received_lines": {
+		"skus": {
+			{"sku", false},
+		},
+		"products": {
+			{"product", false},
+		},
+		"purchases": {
+			{"purchase", false},
+		},
+		"purchase_order_lines": {
+			{"purchase_order_line", false},
+		},
+		"goods_received_notes": {
+			{"goods_received_note", false},
+		},
+	},
+	"credit_overrides": {
+		"users": {
+			{"approved_by", false},
+		},
+		"partners": {
+			{"partner", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+		"sales_transactions": {
+			{"sale", false},
+		},
+	},
+	"document_sequences": {
+		"companies": {
+			{"company", false},
+		},
+	},
+	"audit_logs": {
+		"users": {
+			{"user", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+	},
+	"loyalty_programs": {
+		"companies": {
+			{"company", false},
+		},
+	},
+	"loyalty_entries": {
+		"partners": {
+			{"partner", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+		"sales_transactions": {
+			{"sale", false},
+		},
+		"loyalty_entries": {
+			{"lot", false},
+		},
+	},
+	"notifications": {
+		"companies": {
+			{"company", false},
+		},
+	},
+	"commission_rules": {
+		"products": {
+			{"product", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+		"product_categories": {
+			{"category", false},
+		},
+	},
+	"commission_statements": {
+		"users": {
+			{"salesperson", false},
+			{"paid_by", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+	},
+	"digest_subscriptions": {
+		"users": {
+			{"user", false},
+		},
+		"companies": {
+			{"company", false},
+		},
+	},
 }